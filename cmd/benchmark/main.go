@@ -0,0 +1,403 @@
+// benchmark writes worst-case suits per op type for gas profiling, one file per op type holding a suit per block shape.
+// A suit submits one block of full miniblocks then accuses its last miniblock, which reverts after the contract
+// verified the whole execution proof. The state is dense around every account, token and LOO of the block, so every
+// sibling of their merkle proofs is non-zero, and tokens and ids are the largest ones.
+//
+//	go run ./cmd/benchmark -ops settlement1,withdraw -txs 1,2,4,8 -miniblocks 1,16,255 -out /tmp/benchmark
+//
+// The contract must be deployed with NumTxPerBlock of the suit.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+// BenchmarkSuit is a suit of one block of NumMiniBlocks miniblocks of NumTxPerBlock ops
+type BenchmarkSuit struct {
+	Op            string
+	NumTxPerBlock int
+	NumMiniBlocks int
+	*test.Suit
+}
+
+const (
+	timestamp      = uint32(1600661872)
+	maxTokenID     = 1<<types.TokenIDBits - 1
+	maxValidPeriod = 1<<types.ValidPeriodBits - 1
+	// maxMiniBlocks is the max number of miniblocks of a block, NumMiniBlocks of the block header is an uint8
+	maxMiniBlocks = 255
+)
+
+var (
+	// token1 and token2 are the traded tokens, the fee token is the third token of the accounts
+	token1 = uint16(maxTokenID)
+	token2 = uint16(maxTokenID - 1)
+	// balance covers every op of a benchmark, its bytes are non-zero
+	balance, _ = new(big.Int).SetString("0x0101010101010101010101010101010101010101010101010101010101", 0)
+	// amount is the amount of an op, the largest packed mantisa
+	amount = types.PackedAmount{Mantisa: 1<<types.AmountMantisaBits - 1, Exp: 18}
+	// partAmount is the amount of the order left over by a settlement
+	partAmount = types.PackedAmount{Mantisa: 1<<types.AmountMantisaBits - 1, Exp: 17}
+	oneRate    = types.PackedAmount{Mantisa: 1, Exp: 18}
+	maxFee     = types.PackedFee{Mantisa: 1<<types.FeeMantisaBits - 1, Exp: 10}
+)
+
+// denseGenesis builds a genesis where every sibling of the proofs of the chosen accounts and LOOs is non-empty
+type denseGenesis struct {
+	params  *blockchain.Params
+	genesis *blockchain.Genesis
+}
+
+func newDenseGenesis(params *blockchain.Params, accountMax uint32, looMax uint64) *denseGenesis {
+	return &denseGenesis{
+		params: params,
+		genesis: &blockchain.Genesis{
+			AccountAlloc: map[uint32]blockchain.GenesisAccount{
+				params.AdminIndex: {
+					Tokens:  map[uint16]*big.Int{params.FeeTokenIndex: new(big.Int).Set(balance)},
+					Pubkey:  testsample.PublicKeys[0],
+					Address: testsample.Accounts[0],
+				},
+			},
+			AccountMax: accountMax,
+			LooAlloc:   map[uint64]*types.LeftOverOrder{},
+			LooMax:     looMax,
+		},
+	}
+}
+
+// addAccount adds a trader holding every token of the benchmark and their sibling tokens,
+// and an account at each of its siblings up to AccountMax
+func (d *denseGenesis) addAccount(accountID uint32) {
+	tokens := make(map[uint16]*big.Int)
+	for _, tokenID := range []uint16{d.params.FeeTokenIndex, token1, token2} {
+		for k := uint(0); k < d.params.AccountTreeDeep-1; k++ {
+			tokens[tokenID^1<<k] = new(big.Int).Set(balance)
+		}
+		tokens[tokenID] = new(big.Int).Set(balance)
+	}
+	d.genesis.AccountAlloc[accountID] = blockchain.GenesisAccount{
+		Tokens:  tokens,
+		Pubkey:  pubKey(uint64(accountID)),
+		Address: address(uint64(accountID)),
+	}
+	d.addSiblingAccounts(accountID)
+}
+
+// addSiblingAccounts adds an account at every sibling of accountID up to AccountMax
+func (d *denseGenesis) addSiblingAccounts(accountID uint32) {
+	for k := uint(0); k < d.params.StateTreeDeep-1; k++ {
+		sibling := accountID ^ 1<<k
+		if _, ok := d.genesis.AccountAlloc[sibling]; ok || sibling > d.genesis.AccountMax {
+			continue
+		}
+		d.genesis.AccountAlloc[sibling] = blockchain.GenesisAccount{
+			Tokens:  map[uint16]*big.Int{d.params.FeeTokenIndex: big.NewInt(1)},
+			Pubkey:  pubKey(uint64(sibling)),
+			Address: address(uint64(sibling)),
+		}
+	}
+}
+
+// addLOO adds loo and a LOO at every sibling of looID up to LooMax
+func (d *denseGenesis) addLOO(looID uint64, loo *types.LeftOverOrder) {
+	d.genesis.LooAlloc[looID] = loo
+	d.addSiblingLOOs(looID)
+}
+
+// addSiblingLOOs adds a LOO at every sibling of looID up to LooMax
+func (d *denseGenesis) addSiblingLOOs(looID uint64) {
+	for k := uint(0); k < d.params.LOOTreeDeep-1; k++ {
+		sibling := looID ^ 1<<k
+		if _, ok := d.genesis.LooAlloc[sibling]; ok || sibling > d.genesis.LooMax {
+			continue
+		}
+		d.genesis.LooAlloc[sibling] = &types.LeftOverOrder{
+			AccountID:   d.params.AdminIndex,
+			SrcToken:    token1,
+			DestToken:   token2,
+			Amount:      big.NewInt(1),
+			Fee:         big.NewInt(0),
+			Rate:        oneRate.Big(),
+			ValidSince:  timestamp,
+			ValidPeriod: maxValidPeriod,
+		}
+	}
+}
+
+func pubKey(seed uint64) hexutil.Bytes {
+	key := make(hexutil.Bytes, types.PubKeyLength)
+	for i := range key {
+		key[i] = byte(seed>>(8*(i%8))) | 0x80
+	}
+	return key
+}
+
+func address(seed uint64) common.Address {
+	return common.BytesToAddress(pubKey(seed)[:common.AddressLength])
+}
+
+func loo(accountID uint32, src, dst uint16, validSince uint32) *types.LeftOverOrder {
+	return &types.LeftOverOrder{
+		AccountID:   accountID,
+		SrcToken:    src,
+		DestToken:   dst,
+		Amount:      amount.Big(),
+		Fee:         maxFee.Big(),
+		Rate:        oneRate.Big(),
+		ValidSince:  validSince,
+		ValidPeriod: maxValidPeriod,
+	}
+}
+
+// opBuilder returns the genesis and the txs of a benchmark of numTxs ops
+type opBuilder func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction)
+
+// The ids of the traders and LOOs are the largest ones, below the ids taken by the new accounts and LOOs of the block
+var opBuilders = map[string]opBuilder{
+	"deposit": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1, 0)
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			accountID := d.genesis.AccountMax - uint32(i)
+			d.addAccount(accountID)
+			txs = append(txs, &types.DepositOp{AccountID: accountID, TokenID: token1, Amount: new(big.Int).Set(balance)})
+		}
+		return d.genesis, txs
+	},
+	"depositToNew": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1-uint32(numTxs), 0)
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			accountID := d.genesis.AccountMax + 1 + uint32(i)
+			d.addSiblingAccounts(accountID)
+			txs = append(txs, &types.DepositToNewOp{
+				PubKey:     pubKey(uint64(accountID)),
+				WithdrawTo: address(uint64(accountID)),
+				TokenID:    token1,
+				Amount:     new(big.Int).Set(balance),
+			})
+		}
+		return d.genesis, txs
+	},
+	"settlement1": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1, 1<<(params.LOOTreeDeep-1)-1-uint64(numTxs))
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			account1, account2 := d.genesis.AccountMax-uint32(2*i), d.genesis.AccountMax-uint32(2*i+1)
+			d.addAccount(account1)
+			d.addAccount(account2)
+			d.addSiblingLOOs(d.genesis.LooMax + 1 + uint64(i))
+			// order 1 is the maker, it is left over
+			txs = append(txs, &types.Settlement1{
+				OpType:       types.SettlementOp12,
+				Token1:       token1,
+				Token2:       token2,
+				Account1:     account1,
+				Account2:     account2,
+				Rate1:        oneRate,
+				Rate2:        oneRate,
+				Amount1:      amount,
+				Amount2:      partAmount,
+				Fee1:         maxFee,
+				Fee2:         maxFee,
+				ValidSince1:  timestamp - 2*uint32(i) - 1,
+				ValidSince2:  timestamp,
+				ValidPeriod1: maxValidPeriod,
+				ValidPeriod2: maxValidPeriod,
+			})
+		}
+		return d.genesis, txs
+	},
+	"settlement2": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		looMax := uint64(1<<(params.LOOTreeDeep-1) - 1 - numTxs)
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1, looMax)
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			account1, account2 := d.genesis.AccountMax-uint32(2*i), d.genesis.AccountMax-uint32(2*i+1)
+			d.addAccount(account1)
+			d.addAccount(account2)
+			looID := looMax - uint64(i)
+			d.addLOO(looID, loo(account1, token1, token2, timestamp-1))
+			d.addSiblingLOOs(looMax + 1 + uint64(i))
+			// the LOO is the maker, the order of account 2 is left over
+			txs = append(txs, &types.Settlement2{
+				OpType:       types.SettlementOp22,
+				LooID1:       looID,
+				AccountID2:   account2,
+				Amount2:      types.PackedAmount{Mantisa: amount.Mantisa, Exp: amount.Exp + 1},
+				Rate2:        oneRate,
+				Fee2:         maxFee,
+				ValidSince2:  timestamp - uint32(i),
+				ValidPeriod2: maxValidPeriod,
+			})
+		}
+		return d.genesis, txs
+	},
+	"settlement3": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		looMax := uint64(1<<(params.LOOTreeDeep-1) - 1)
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1, looMax)
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			account1, account2 := d.genesis.AccountMax-uint32(2*i), d.genesis.AccountMax-uint32(2*i+1)
+			d.addAccount(account1)
+			d.addAccount(account2)
+			looID1, looID2 := looMax-uint64(2*i), looMax-uint64(2*i+1)
+			d.addLOO(looID1, loo(account1, token1, token2, timestamp-1))
+			loo2 := loo(account2, token2, token1, timestamp)
+			// loo 2 is left over
+			loo2.Amount = new(big.Int).Mul(loo2.Amount, big.NewInt(10))
+			d.addLOO(looID2, loo2)
+			txs = append(txs, &types.Settlement3{LooID1: looID1, LooID2: looID2})
+		}
+		return d.genesis, txs
+	},
+	"withdraw": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1, 0)
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			accountID := d.genesis.AccountMax - uint32(i)
+			d.addAccount(accountID)
+			txs = append(txs, &types.WithdrawOp{
+				TokenID:    token1,
+				Amount:     amount,
+				DestAddr:   address(uint64(accountID)),
+				AccountID:  accountID,
+				ValidSince: timestamp,
+				Fee:        maxFee,
+			})
+		}
+		return d.genesis, txs
+	},
+	"exit": func(params *blockchain.Params, numTxs int) (*blockchain.Genesis, []types.Transaction) {
+		d := newDenseGenesis(params, 1<<(params.StateTreeDeep-1)-1, 0)
+		var txs []types.Transaction
+		for i := 0; i < numTxs; i++ {
+			accountID := d.genesis.AccountMax - uint32(i)
+			d.addAccount(accountID)
+			txs = append(txs, &types.ExitOp{AccountID: accountID})
+		}
+		return d.genesis, txs
+	},
+}
+
+var opNames = []string{"deposit", "depositToNew", "settlement1", "settlement2", "settlement3", "withdraw", "exit"}
+
+// buildSuit returns the benchmark of a block of numMiniBlocks miniblocks of numTxs ops
+func buildSuit(op string, numTxs, numMiniBlocks int, tracer blockchain.Tracer) *BenchmarkSuit {
+	params := blockchain.DefaultParams()
+	params.NumTxPerBlock = numTxs
+	genesis, txs := opBuilders[op](params, numTxs*numMiniBlocks)
+	rollup := blockchain.NewRollup(blockchain.NewBlockchain(genesis, params))
+	rollup.Blockchain().SetTracer(tracer)
+	suit := &test.Suit{
+		Msg:              fmt.Sprintf("benchmark of %s, %d miniblocks of %d txs", op, numMiniBlocks, numTxs),
+		GenesisStateHash: rollup.Blockchain().GetStateData().Hash(),
+		AccountMax:       genesis.AccountMax,
+	}
+
+	var miniBlocks []*types.MiniBlock
+	for i := 0; i < numMiniBlocks; i++ {
+		miniBlocks = append(miniBlocks, &types.MiniBlock{Txs: txs[i*numTxs : (i+1)*numTxs]})
+	}
+	blk, err := rollup.AddBlock(miniBlocks, timestamp)
+	if err != nil {
+		panic(fmt.Sprintf("%s: %v", suit.Msg, err))
+	}
+	for _, tx := range txs {
+		switch tx.(type) {
+		case *types.DepositOp:
+			suit.Steps = append(suit.Steps, test.Step{Action: test.SubmitDeposit, Data: tx})
+		case *types.DepositToNewOp:
+			suit.Steps = append(suit.Steps, test.Step{Action: test.SubmitDepositToNew, Data: tx})
+		}
+	}
+	accuse, err := test.NewAccuseBlockFraudProofStep(rollup, 1, uint(numMiniBlocks-1))
+	if err != nil {
+		panic(fmt.Sprintf("%s: %v", suit.Msg, err))
+	}
+	suit.Steps = append(suit.Steps,
+		test.Step{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(blk)},
+		// the block is honest, the accusation reverts after verifying the execution of the last miniblock
+		test.Step{Action: test.AccuseBlockFraudProof, Data: accuse, Revert: true},
+		test.Step{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(rollup)},
+	)
+	return &BenchmarkSuit{Op: op, NumTxPerBlock: numTxs, NumMiniBlocks: numMiniBlocks, Suit: suit}
+}
+
+func parseSizes(s string, max int) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(s, ",") {
+		size, err := strconv.Atoi(field)
+		if err != nil || size < 1 || size > max {
+			return nil, fmt.Errorf("invalid size %q, expect 1 to %d", field, max)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func main() {
+	var (
+		ops           = flag.String("ops", strings.Join(opNames, ","), "op types")
+		txsFlag       = flag.String("txs", "8", "NumTxPerBlock of the suits, a list to sweep")
+		miniBlockFlag = flag.String("miniblocks", "1,8", fmt.Sprintf("number of miniblocks of the block, a list to sweep, at most %d", maxMiniBlocks))
+		out           = flag.String("out", "benchmarkdata", "output directory, the suits of an op go to benchmark_<op>.json")
+	)
+	flag.Parse()
+	numTxs, err := parseSizes(*txsFlag, 1<<8)
+	if err != nil {
+		log.Fatal(err)
+	}
+	numMiniBlocks, err := parseSizes(*miniBlockFlag, maxMiniBlocks)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, op := range strings.Split(*ops, ",") {
+		if _, ok := opBuilders[op]; !ok {
+			log.Fatalf("unknown op %q, expect one of %s", op, strings.Join(opNames, ", "))
+		}
+		output := filepath.Join(*out, "benchmark_"+op+".json")
+		var suits []*BenchmarkSuit
+		if err := test.Trace(output, func(tracer blockchain.Tracer) {
+			for _, n := range numTxs {
+				for _, m := range numMiniBlocks {
+					suits = append(suits, buildSuit(op, n, m, tracer))
+				}
+			}
+		}); err != nil {
+			log.Fatal(err)
+		}
+		var testSuits []*test.Suit
+		for _, suit := range suits {
+			testSuits = append(testSuits, suit.Suit)
+		}
+		if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+			panic(err)
+		}
+		b, err := json.MarshalIndent(suits, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		if err := ioutil.WriteFile(output, b, 0644); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/runner"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+func TestBuildSuit(t *testing.T) {
+	for _, op := range opNames {
+		for _, numTxs := range []int{1, 3} {
+			require.NotPanics(t, func() { buildSuit(op, numTxs, 2, nil) }, "%s of %d txs", op, numTxs)
+		}
+	}
+}
+
+func TestContract(t *testing.T) {
+	// the contract is deployed with the default NumTxPerBlock
+	var suits []*test.Suit
+	for _, op := range opNames {
+		suits = append(suits, buildSuit(op, 8, 2, nil).Suit)
+	}
+	runner.RunSuitsFromEnv(t, suits...)
+}
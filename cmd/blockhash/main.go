@@ -9,6 +9,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 
 	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
 )
 
 const output = "testdata/submitBlock.json"
@@ -42,24 +43,20 @@ func main() {
 			miniBlockDataArr = append(miniBlockDataArr, miniBlockData)
 		}
 
-		blockInfoHash := util.GetMiniBlockHash(miniBlockHashes)
-		prevBlockRoot := common.HexToHash("0x0")
-		blockNumber := uint32(1)
-		blockTime := uint32(1600237638)
-		blockRoot := crypto.Keccak256(
-			prevBlockRoot.Bytes(),
-			blockInfoHash.Bytes(),
-			util.Uint32ToBytes(blockTime),
-			util.Uint32ToBytes(blockNumber),
-			[]byte{util.Uint8ToByte(uint8(miniBlockLen))},
-			miniBlocks[miniBlockLen-1].StateHash.Bytes(),
-		)
+		header := &blockchain.BlockHeader{
+			PrevBlockRoot: common.Hash{},
+			BlockInfoHash: util.GetMiniBlockHash(miniBlockHashes),
+			Timestamp:     1600237638,
+			BlockNumber:   1,
+			NumMiniBlocks: uint8(miniBlockLen),
+			StateHash:     miniBlocks[miniBlockLen-1].StateHash,
+		}
 
 		testSuit := SubmitBlockTestSuit{
-			BlockNumber:          blockNumber,
-			TimeStamp:            blockTime,
+			BlockNumber:          header.BlockNumber,
+			TimeStamp:            header.Timestamp,
 			MiniBlocks:           miniBlockDataArr,
-			ExpectedNewBlockRoot: blockRoot,
+			ExpectedNewBlockRoot: header.Root().Bytes(),
 		}
 		testSuits = append(testSuits, testSuit)
 	}
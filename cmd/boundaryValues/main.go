@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const output = "testdata/boundaryValues.json"
+
+// BoundaryTestSuit is an op with fields at or just above their max width.
+// Data is the pubdata of a valid op, Error tells why an op is invalid.
+// Calldata holds the calls submitting a valid op alone in block 1, see test.NewSubmitMiniBlockSteps.
+type BoundaryTestSuit struct {
+	Msg      string
+	Op       types.Transaction
+	Data     hexutil.Bytes   `json:",omitempty"`
+	Error    string          `json:",omitempty"`
+	Calldata []hexutil.Bytes `json:",omitempty"`
+}
+
+// timestamp is the timestamp of the block submitting a valid op
+const timestamp = 1600661872
+
+const (
+	maxTokenID     = 1<<types.TokenIDBits - 1
+	maxLooID       = 1<<types.LooIDBits - 1
+	maxDepositID   = 1<<types.DepositIDBits - 1
+	maxValidPeriod = 1<<types.ValidPeriodBits - 1
+)
+
+var (
+	maxAmount  = types.PackedAmount{Mantisa: math.MaxUint32, Exp: math.MaxUint8}
+	maxFee     = types.PackedFee{Mantisa: 1<<types.FeeMantisaBits - 1, Exp: 1<<types.FeeExpBits - 1}
+	maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+)
+
+func maxSettlement1() *types.Settlement1 {
+	return &types.Settlement1{
+		OpType:       types.SettlementOp13,
+		Token1:       maxTokenID,
+		Token2:       maxTokenID,
+		Account1:     math.MaxUint32,
+		Account2:     math.MaxUint32,
+		Rate1:        maxAmount,
+		Rate2:        maxAmount,
+		Amount1:      maxAmount,
+		Amount2:      maxAmount,
+		Fee1:         maxFee,
+		Fee2:         maxFee,
+		ValidSince1:  math.MaxUint32,
+		ValidSince2:  math.MaxUint32,
+		ValidPeriod1: maxValidPeriod,
+		ValidPeriod2: maxValidPeriod,
+	}
+}
+
+func maxSettlement2() *types.Settlement2 {
+	return &types.Settlement2{
+		OpType:       types.SettlementOp22,
+		LooID1:       maxLooID,
+		AccountID2:   math.MaxUint32,
+		Amount2:      maxAmount,
+		Rate2:        maxAmount,
+		Fee2:         maxFee,
+		ValidSince2:  math.MaxUint32,
+		ValidPeriod2: maxValidPeriod,
+	}
+}
+
+func maxSettlement3() *types.Settlement3 {
+	return &types.Settlement3{LooID1: maxLooID, LooID2: maxLooID}
+}
+
+func maxDeposit() *types.DepositOp {
+	return &types.DepositOp{
+		DepositID: maxDepositID,
+		AccountID: math.MaxUint32,
+		TokenID:   maxTokenID,
+		Amount:    maxUint256,
+	}
+}
+
+func maxDepositToNew() *types.DepositToNewOp {
+	pubKey := make(hexutil.Bytes, types.PubKeyLength)
+	for i := range pubKey {
+		pubKey[i] = 0xff
+	}
+	return &types.DepositToNewOp{
+		DepositID:  maxDepositID,
+		PubKey:     pubKey,
+		WithdrawTo: common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"),
+		TokenID:    maxTokenID,
+		Amount:     maxUint256,
+	}
+}
+
+func maxWithdraw() *types.WithdrawOp {
+	return &types.WithdrawOp{
+		TokenID:    maxTokenID,
+		Amount:     maxAmount,
+		DestAddr:   common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"),
+		AccountID:  math.MaxUint32,
+		ValidSince: math.MaxUint32,
+		Fee:        maxFee,
+	}
+}
+
+func maxExit() *types.ExitOp {
+	return &types.ExitOp{
+		AccountID:   math.MaxUint32,
+		AccountRoot: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+	}
+}
+
+func validSuit(msg string, op types.Transaction) BoundaryTestSuit {
+	if err := op.Validate(); err != nil {
+		panic(err)
+	}
+	calldata, err := test.CalldataFromEnv(test.NewSubmitMiniBlockSteps(&types.MiniBlock{Txs: []types.Transaction{op}}, 1, timestamp)...)
+	if err != nil {
+		panic(err)
+	}
+	return BoundaryTestSuit{Msg: msg, Op: op, Data: op.ToBytes(), Calldata: calldata}
+}
+
+func invalidSuit(msg string, op types.Transaction) BoundaryTestSuit {
+	err := op.Validate()
+	if err == nil {
+		panic("expect invalid op: " + msg)
+	}
+	return BoundaryTestSuit{Msg: msg, Op: op, Error: err.Error()}
+}
+
+func main() {
+	var testSuits []BoundaryTestSuit
+
+	testSuits = append(testSuits, validSuit("settlement1 with max fields", maxSettlement1()))
+	{
+		op := maxSettlement1()
+		op.Token1++
+		testSuits = append(testSuits, invalidSuit("settlement1 token1 overflows", op))
+		op = maxSettlement1()
+		op.Token2++
+		testSuits = append(testSuits, invalidSuit("settlement1 token2 overflows", op))
+		op = maxSettlement1()
+		op.Fee1.Mantisa++
+		testSuits = append(testSuits, invalidSuit("settlement1 fee1 mantisa overflows", op))
+		op = maxSettlement1()
+		op.Fee2.Exp++
+		testSuits = append(testSuits, invalidSuit("settlement1 fee2 exp overflows", op))
+		op = maxSettlement1()
+		op.ValidPeriod1++
+		testSuits = append(testSuits, invalidSuit("settlement1 validPeriod1 overflows", op))
+		op = maxSettlement1()
+		op.ValidPeriod2++
+		testSuits = append(testSuits, invalidSuit("settlement1 validPeriod2 overflows", op))
+	}
+
+	testSuits = append(testSuits, validSuit("settlement2 with max fields", maxSettlement2()))
+	{
+		op := maxSettlement2()
+		op.LooID1++
+		testSuits = append(testSuits, invalidSuit("settlement2 looID1 overflows", op))
+		op = maxSettlement2()
+		op.Fee2.Mantisa++
+		testSuits = append(testSuits, invalidSuit("settlement2 fee2 mantisa overflows", op))
+		op = maxSettlement2()
+		op.ValidPeriod2++
+		testSuits = append(testSuits, invalidSuit("settlement2 validPeriod2 overflows", op))
+	}
+
+	testSuits = append(testSuits, validSuit("settlement3 with max fields", maxSettlement3()))
+	{
+		op := maxSettlement3()
+		op.LooID1++
+		testSuits = append(testSuits, invalidSuit("settlement3 looID1 overflows", op))
+		op = maxSettlement3()
+		op.LooID2++
+		testSuits = append(testSuits, invalidSuit("settlement3 looID2 overflows", op))
+	}
+
+	testSuits = append(testSuits, validSuit("deposit with max fields", maxDeposit()))
+	{
+		op := maxDeposit()
+		op.DepositID++
+		testSuits = append(testSuits, invalidSuit("deposit depositID overflows", op))
+		op = maxDeposit()
+		op.TokenID++
+		testSuits = append(testSuits, invalidSuit("deposit tokenID overflows", op))
+		op = maxDeposit()
+		op.Amount = new(big.Int).Add(maxUint256, big.NewInt(1))
+		testSuits = append(testSuits, invalidSuit("deposit amount overflows", op))
+	}
+
+	testSuits = append(testSuits, validSuit("deposit to new with max fields", maxDepositToNew()))
+	{
+		op := maxDepositToNew()
+		op.DepositID++
+		testSuits = append(testSuits, invalidSuit("deposit to new depositID overflows", op))
+		op = maxDepositToNew()
+		op.PubKey = append(op.PubKey, 0xff)
+		testSuits = append(testSuits, invalidSuit("deposit to new pubkey too long", op))
+	}
+
+	testSuits = append(testSuits, validSuit("withdraw with max fields", maxWithdraw()))
+	{
+		op := maxWithdraw()
+		op.TokenID++
+		testSuits = append(testSuits, invalidSuit("withdraw tokenID overflows", op))
+		op = maxWithdraw()
+		op.Fee.Exp++
+		testSuits = append(testSuits, invalidSuit("withdraw fee exp overflows", op))
+	}
+
+	testSuits = append(testSuits, validSuit("exit with max fields", maxExit()))
+
+	b, err := json.MarshalIndent(testSuits, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(output, b, 0644); err != nil {
+		panic(err)
+	}
+}
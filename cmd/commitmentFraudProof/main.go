@@ -186,8 +186,9 @@ var genesis = &blockchain.Genesis{
 	LooMax:     0,
 }
 
-func buildCommitmentFraudProofTest1() {
-	bc := blockchain.NewBlockchain(genesis)
+func buildCommitmentFraudProofTest1(tracer blockchain.Tracer) {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	miniBlock1 := &types.MiniBlock{
 		Txs: []types.Transaction{
@@ -228,7 +229,9 @@ func buildCommitmentFraudProofTest1() {
 			},
 		},
 	}
-	bc.AddMiniBlock(miniBlock1)
+	if _, err := bc.AddMiniBlock(miniBlock1); err != nil {
+		panic(err)
+	}
 
 	submitBlockStep := test.SubmitBlockStep{
 		MiniBlocks:  []*types.MiniBlock{miniBlock1},
@@ -253,6 +256,9 @@ func buildCommitmentFraudProofTest1() {
 			},
 		},
 	}
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
@@ -307,8 +313,9 @@ var genesis2 = &blockchain.Genesis{
 	},
 }
 
-func buildCommitmentFraudProofTest2() {
-	bc := blockchain.NewBlockchain(genesis2)
+func buildCommitmentFraudProofTest2(tracer blockchain.Tracer) {
+	bc := blockchain.NewBlockchain(genesis2, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 
 	miniBlock1 := &types.MiniBlock{
@@ -334,7 +341,9 @@ func buildCommitmentFraudProofTest2() {
 			},
 		},
 	}
-	bc.AddMiniBlock(miniBlock1)
+	if _, err := bc.AddMiniBlock(miniBlock1); err != nil {
+		panic(err)
+	}
 
 	submitBlockStep := test.SubmitBlockStep{
 		MiniBlocks:  []*types.MiniBlock{miniBlock1},
@@ -359,6 +368,9 @@ func buildCommitmentFraudProofTest2() {
 			},
 		},
 	}
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
@@ -416,8 +428,9 @@ var genesis3 = &blockchain.Genesis{
 	},
 }
 
-func buildCommitmentFraudProofTest3() {
-	bc := blockchain.NewBlockchain(genesis3)
+func buildCommitmentFraudProofTest3(tracer blockchain.Tracer) {
+	bc := blockchain.NewBlockchain(genesis3, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	// create an withdraw to another user
 	withdraw := &types.WithdrawOp{
@@ -433,7 +446,9 @@ func buildCommitmentFraudProofTest3() {
 			withdraw,
 		},
 	}
-	bc.AddMiniBlock(miniBlock)
+	if _, err := bc.AddMiniBlock(miniBlock); err != nil {
+		panic(err)
+	}
 	submitBlockStep := test.SubmitBlockStep{
 		BlockNumber: 1,
 		MiniBlocks:  []*types.MiniBlock{miniBlock},
@@ -458,6 +473,9 @@ func buildCommitmentFraudProofTest3() {
 			},
 		},
 	}
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
@@ -471,7 +489,13 @@ func main() {
 	testCommitmentBuilder1()
 	testCommitmentBuilder2()
 	testCommitmentBuilder3()
-	buildCommitmentFraudProofTest1()
-	buildCommitmentFraudProofTest2()
-	buildCommitmentFraudProofTest3()
+	if err := test.Trace(commitmentFraudProofTest1Output, buildCommitmentFraudProofTest1); err != nil {
+		panic(err)
+	}
+	if err := test.Trace(commitmentFraudProofTest2Output, buildCommitmentFraudProofTest2); err != nil {
+		panic(err)
+	}
+	if err := test.Trace(commitmentFraudProofTest3Output, buildCommitmentFraudProofTest3); err != nil {
+		panic(err)
+	}
 }
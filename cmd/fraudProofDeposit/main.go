@@ -7,7 +7,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 
-	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
 	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
@@ -51,36 +50,24 @@ var genesis = &blockchain.Genesis{
 	LooMax:     0,
 }
 
-func buildTest1() *test.Suit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *test.Suit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
-	preStateData := bc.GetStateData()
+	rollup := blockchain.NewRollup(bc)
 
 	deposit := &types.DepositOp{
 		AccountID: 8,
 		TokenID:   2,
 		Amount:    big.NewInt(45242000),
 	}
-
-	miniBlock1 := &types.MiniBlock{
-		Txs: []types.Transaction{deposit},
-	}
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-
-	submitBlockStep := test.SubmitBlockStep{
-		BlockNumber: 1,
-		MiniBlocks:  []*types.MiniBlock{miniBlock1},
-		Timestamp:   1600661872,
+	block1, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit}}}, 1600661872)
+	if err != nil {
+		panic(err)
 	}
-
-	accuseStep := test.AccuseBlockFraudProofStep{
-		BlockNumber:        1,
-		MiniBlockNumber:    0,
-		MiniBlock:          miniBlock1,
-		PrevStateData:      preStateData,
-		MiniBlockProof:     proof.BuildMiniBlockProof(submitBlockStep.MiniBlocks, uint(submitBlockStep.BlockNumber), submitBlockStep.Timestamp),
-		PrevStateHashProof: proof.BuildFinalStateHashProof(submitBlockStep.MiniBlocks, submitBlockStep.Timestamp),
-		ExecutionProof:     executionProofs,
+	accuse, err := test.NewAccuseBlockFraudProofStep(rollup, 1, 0)
+	if err != nil {
+		panic(err)
 	}
 
 	return &test.Suit{
@@ -89,16 +76,23 @@ func buildTest1() *test.Suit {
 		AccountMax:       genesis.AccountMax,
 		Steps: []test.Step{
 			{Action: test.SubmitDeposit, Data: deposit},
-			{Action: test.SubmitBlock, Data: submitBlockStep},
-			{Action: test.AccuseBlockFraudProof, Data: accuseStep},
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block1)},
+			{Action: test.AccuseBlockFraudProof, Data: accuse},
 		},
 	}
 }
 
 func main() {
 	var testSuits []*test.Suit
-	testSuits = append(testSuits, buildTest1())
+	if err := test.Trace(output, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+	}); err != nil {
+		panic(err)
+	}
 
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
@@ -6,11 +6,13 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 
 	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
 	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
 )
 
 var (
@@ -55,10 +57,13 @@ type DepositFraudProofTestSuit struct {
 	GenesisStateHash common.Hash
 	DepositOp        *types.DepositToNewOp
 	Blocks           []blockchain.BlockData
+	// Calldata holds the calls submitting DepositOp and Blocks and accusing the last block, see test.NewFraudProofSteps
+	Calldata []hexutil.Bytes `json:",omitempty"`
 }
 
-func buildTest1() *DepositFraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *DepositFraudProofTestSuit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	preStateData := bc.GetStateData()
 
@@ -73,7 +78,10 @@ func buildTest1() *DepositFraudProofTestSuit {
 		Txs: []types.Transaction{deposit},
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
 	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
@@ -84,7 +92,7 @@ func buildTest1() *DepositFraudProofTestSuit {
 			ExecutionProof:     executionProofs,
 		},
 	}
-	blockData.Proof.MiniBlockProof = proof.BuildMiniBlockProof(blockData.MiniBlocks, blockData.MiniBlockNumber, blockData.Timestamp)
+	blockData.Proof.MiniBlockProof = proof.BuildMiniBlockProof(blockData.MiniBlocks, uint(blockData.MiniBlockNumber), blockData.Timestamp)
 	return &DepositFraudProofTestSuit{
 		Msg:              "test case simple deposit",
 		GenesisStateHash: genesisHash,
@@ -97,7 +105,19 @@ func buildTest1() *DepositFraudProofTestSuit {
 
 func main() {
 	var testSuits []*DepositFraudProofTestSuit
-	testSuits = append(testSuits, buildTest1())
+	if err := test.Trace(output, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+	}); err != nil {
+		panic(err)
+	}
+	for _, suit := range testSuits {
+		deposit := test.Step{Action: test.SubmitDepositToNew, Data: suit.DepositOp}
+		calldata, err := test.CalldataFromEnv(append([]test.Step{deposit}, test.NewFraudProofSteps(suit.Blocks...)...)...)
+		if err != nil {
+			panic(err)
+		}
+		suit.Calldata = calldata
+	}
 
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
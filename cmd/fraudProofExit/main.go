@@ -7,8 +7,6 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 
-	util "github.com/KyberNetwork/l2-contract-test-suite/common"
-	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
 	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
@@ -64,8 +62,9 @@ var genesis = &blockchain.Genesis{
 	},
 }
 
-func buildTest1() *test.Suit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *test.Suit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	// create an deposit to user
 	deposit := &types.DepositOp{
@@ -79,58 +78,34 @@ func buildTest1() *test.Suit {
 		TokenID:   4,
 		Amount:    big.NewInt(135000),
 	}
+	rollup := blockchain.NewRollup(bc)
 	miniBlock1 := &types.MiniBlock{
 		Txs: []types.Transaction{deposit, deposit2},
 	}
-	bc.AddMiniBlock(miniBlock1)
-	submitBlockStep := test.SubmitBlockStep{
-		BlockNumber: 1,
-		MiniBlocks:  []*types.MiniBlock{miniBlock1},
-		Timestamp:   1600661872,
+	block1, err := rollup.AddBlock([]*types.MiniBlock{miniBlock1}, 1600661872)
+	if err != nil {
+		panic(err)
 	}
 	// create exit step
-	submitExitStep := test.SubmitExitStep{
-		AccountID:   36,
-		Timestamp:   submitBlockStep.Timestamp,
-		BlockNumber: submitBlockStep.BlockNumber,
+	submitExitStep, err := test.NewSubmitExitStep(rollup, block1.Header.BlockNumber, 36)
+	if err != nil {
+		panic(err)
 	}
-	var exitProof []byte
-	submitExitStep.BalanceRoot, exitProof = bc.BuildSubmitExitProof(submitExitStep.AccountID)
-	// due to this only have 1 block so blockDataHash = miniBlock1.Hash()
-	exitProof = append(exitProof, miniBlock1.Hash().Bytes()...)
-	exitProof = append(exitProof, util.Uint8ToByte(1))
-	submitExitStep.Proof = exitProof
 
 	// create an withdraw to another user
 	exit := &types.ExitOp{
 		AccountID: 36,
 	}
-	miniBlock2 := &types.MiniBlock{
-		Txs: []types.Transaction{exit},
-	}
-	prevStateData := bc.GetStateData()
-	executionProofs := bc.AddMiniBlock(miniBlock2)
-	submitBlockStep2 := test.SubmitBlockStep{
-		BlockNumber: 2,
-		MiniBlocks:  []*types.MiniBlock{miniBlock2},
-		Timestamp:   1600661872,
+	block2, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{exit}}}, 1600661872)
+	if err != nil {
+		panic(err)
 	}
-
-	accuseStep := test.AccuseBlockFraudProofStep{
-		BlockNumber:        2,
-		MiniBlockNumber:    0,
-		MiniBlock:          miniBlock2,
-		PrevStateData:      prevStateData,
-		MiniBlockProof:     proof.BuildMiniBlockProof(submitBlockStep2.MiniBlocks, 0, submitBlockStep2.Timestamp),
-		PrevStateHashProof: proof.BuildFinalStateHashProof(submitBlockStep.MiniBlocks, submitBlockStep.Timestamp),
-		ExecutionProof:     executionProofs,
+	accuse, err := test.NewAccuseBlockFraudProofStep(rollup, 2, 0)
+	if err != nil {
+		panic(err)
 	}
 	// create complete exit step
-	completeExitStep := test.CompleteExitStep{
-		AccountID: 36,
-		TokenIDs:  []uint16{2, 4},
-	}
-	completeExitStep.TokenAmounts, completeExitStep.Siblings = bc.BuildCompleteExit(completeExitStep.AccountID, completeExitStep.TokenIDs)
+	completeExitStep := test.NewCompleteExitStep(bc, 36, []uint16{2, 4})
 
 	return &test.Suit{
 		Msg:              "test case when exit with 2 tokens",
@@ -139,10 +114,10 @@ func buildTest1() *test.Suit {
 		Steps: []test.Step{
 			{Action: test.SubmitDeposit, Data: deposit},
 			{Action: test.SubmitDeposit, Data: deposit2},
-			{Action: test.SubmitBlock, Data: submitBlockStep},
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block1)},
 			{Action: test.SubmitExit, Data: submitExitStep},
-			{Action: test.SubmitBlock, Data: submitBlockStep2},
-			{Action: test.AccuseBlockFraudProof, Data: accuseStep},
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block2)},
+			{Action: test.AccuseBlockFraudProof, Data: accuse},
 			{Action: test.CompleteExit, Data: completeExitStep},
 		},
 	}
@@ -150,8 +125,15 @@ func buildTest1() *test.Suit {
 
 func main() {
 	var testSuits []*test.Suit
-	testSuits = append(testSuits, buildTest1())
+	if err := test.Trace(testOutput, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+	}); err != nil {
+		panic(err)
+	}
 
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
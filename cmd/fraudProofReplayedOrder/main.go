@@ -0,0 +1,171 @@
+// fraudProofReplayedOrder writes suits where a malicious operator settles an order twice. The settled orders are not
+// part of the state hash, so the contract can not prove a replayed order: the block is executed as submitted and
+// its accusation reverts. The suits pin that behaviour until the protocol verifies the settled orders.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const testOutput = "testdata/fraudProofReplayedOrder.json"
+
+var genesis = &blockchain.Genesis{
+	AccountAlloc: map[uint32]blockchain.GenesisAccount{
+		0: {
+			Tokens:  map[uint16]*big.Int{},
+			Pubkey:  testsample.PublicKeys[0],
+			Address: testsample.Accounts[0],
+		},
+		8: {
+			Tokens: map[uint16]*big.Int{
+				0: big.NewInt(50000),
+				1: big.NewInt(6000000),
+			},
+			Pubkey:  testsample.PublicKeys[1],
+			Address: testsample.Accounts[1],
+		},
+		12: {
+			Tokens: map[uint16]*big.Int{
+				0: big.NewInt(30000),
+				2: big.NewInt(5000000),
+			},
+			Pubkey:  testsample.PublicKeys[2],
+			Address: testsample.Accounts[2],
+		},
+	},
+	AccountMax: 18,
+}
+
+// settlement fully fills 2e6 of token 1 of account 8 against 2e6 of token 2 of account 12
+func settlement(validSince uint32) *types.Settlement1 {
+	return &types.Settlement1{
+		OpType:       types.SettlementOp11,
+		Token1:       1,
+		Token2:       2,
+		Account1:     8,
+		Account2:     12,
+		Rate1:        types.PackedAmount{Mantisa: 1, Exp: 18},
+		Rate2:        types.PackedAmount{Mantisa: 1, Exp: 18},
+		Amount1:      types.PackedAmount{Mantisa: 2, Exp: 6},
+		Amount2:      types.PackedAmount{Mantisa: 2, Exp: 6},
+		Fee1:         types.PackedFee{Mantisa: 7, Exp: 3},
+		Fee2:         types.PackedFee{Mantisa: 4, Exp: 2},
+		ValidSince1:  validSince,
+		ValidSince2:  validSince + 1,
+		ValidPeriod1: 86400,
+		ValidPeriod2: 86400,
+	}
+}
+
+// newOperator returns the rollup of an operator which does not check replayed orders
+func newOperator(tracer blockchain.Tracer) *blockchain.Rollup {
+	params := blockchain.DefaultParams()
+	params.AllowReplayedOrders = true
+	bc := blockchain.NewBlockchain(genesis, params)
+	bc.SetTracer(tracer)
+	return blockchain.NewRollup(bc)
+}
+
+// mustReject checks an honest operator executes every block but the last one, which replays an order
+func mustReject(blocks ...[]types.Transaction) {
+	honest := blockchain.NewRollup(blockchain.NewBlockchain(genesis, nil))
+	for i, txs := range blocks {
+		_, err := honest.AddBlock([]*types.MiniBlock{{Txs: txs}}, 0)
+		if i < len(blocks)-1 && err != nil {
+			panic(err)
+		}
+		if i == len(blocks)-1 && !errors.Is(err, blockchain.ErrReplayedOrder) {
+			panic("expect replayed order")
+		}
+	}
+}
+
+// buildTest1 replays the order of block 1 in block 2
+func buildTest1(tracer blockchain.Tracer) *test.Suit {
+	operator := newOperator(tracer)
+	genesisHash := operator.Blockchain().GetStateData().Hash()
+	timestamp := uint32(1600661872)
+
+	block1, err := operator.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{settlement(timestamp)}}}, timestamp)
+	if err != nil {
+		panic(err)
+	}
+	mustReject([]types.Transaction{settlement(timestamp)}, []types.Transaction{settlement(timestamp)})
+	badBlock2, err := operator.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{settlement(timestamp)}}}, timestamp+100)
+	if err != nil {
+		panic(err)
+	}
+	accuse, err := test.NewAccuseBlockFraudProofStep(operator, 2, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	return &test.Suit{
+		Msg:              "test case when block 2 replays the order of block 1, the contract keeps it",
+		GenesisStateHash: genesisHash,
+		AccountMax:       genesis.AccountMax,
+		Steps: []test.Step{
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block1)},
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(badBlock2)},
+			// the execution of the replayed order is valid
+			{Action: test.AccuseBlockFraudProof, Data: accuse, Revert: true},
+			{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(operator)},
+		},
+	}
+}
+
+// buildTest2 replays an order in the next tx of the same miniblock
+func buildTest2(tracer blockchain.Tracer) *test.Suit {
+	operator := newOperator(tracer)
+	genesisHash := operator.Blockchain().GetStateData().Hash()
+	timestamp := uint32(1600661872)
+
+	mustReject([]types.Transaction{settlement(timestamp), settlement(timestamp)})
+	badBlock1, err := operator.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{settlement(timestamp), settlement(timestamp)}}}, timestamp)
+	if err != nil {
+		panic(err)
+	}
+	accuse, err := test.NewAccuseBlockFraudProofStep(operator, 1, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	return &test.Suit{
+		Msg:              "test case when a miniblock settles the same order twice, the contract keeps it",
+		GenesisStateHash: genesisHash,
+		AccountMax:       genesis.AccountMax,
+		Steps: []test.Step{
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(badBlock1)},
+			{Action: test.AccuseBlockFraudProof, Data: accuse, Revert: true},
+			{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(operator)},
+		},
+	}
+}
+
+func main() {
+	var testSuits []*test.Suit
+	if err := test.Trace(testOutput, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer), buildTest2(tracer))
+	}); err != nil {
+		panic(err)
+	}
+
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
+	b, err := json.MarshalIndent(testSuits, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(testOutput, b, 0644); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/runner"
+)
+
+func TestContract(t *testing.T) {
+	runner.RunSuitsFromEnv(t, buildTest1(nil), buildTest2(nil))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const testOutput = "testdata/fraudProofRevert.json"
+
+var genesis = &blockchain.Genesis{
+	AccountAlloc: map[uint32]blockchain.GenesisAccount{
+		0: {
+			Tokens:  map[uint16]*big.Int{},
+			Pubkey:  testsample.PublicKeys[1],
+			Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea"),
+		},
+		23: {
+			Tokens: map[uint16]*big.Int{
+				0: big.NewInt(2000),
+			},
+			Pubkey:  testsample.PublicKeys[2],
+			Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8"),
+		},
+	},
+	AccountMax: 1000,
+}
+
+// buildTest1 submits 3 blocks, accuses block 2 which has a wrong state hash,
+// then resubmits block 2 and 3 on top of block 1
+func buildTest1(tracer blockchain.Tracer) *test.Suit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
+	genesisHash := bc.GetStateData().Hash()
+	rollup := blockchain.NewRollup(bc)
+
+	deposit := &types.DepositOp{
+		AccountID: 23,
+		TokenID:   2,
+		Amount:    big.NewInt(45242000),
+	}
+	deposit2 := &types.DepositOp{
+		AccountID: 23,
+		TokenID:   2,
+		Amount:    big.NewInt(1000),
+	}
+	withdraw := &types.WithdrawOp{
+		TokenID:   2,
+		Amount:    types.PackedAmount{Mantisa: 4, Exp: 7},
+		DestAddr:  common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8"),
+		AccountID: 23,
+		Fee:       types.PackedFee{Mantisa: 1, Exp: 2},
+	}
+
+	timestamp := uint32(1600661872)
+	block1, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit}}}, timestamp)
+	if err != nil {
+		panic(err)
+	}
+	// the operator submits a wrong state hash for block 2
+	badBlock2, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{withdraw}}}, timestamp)
+	if err != nil {
+		panic(err)
+	}
+	badBlock2.MiniBlocks[0].StateHash = common.HexToHash("0xbad")
+	badBlock2.Header = blockchain.NewBlockHeader(rollup.BlockRoot(1), 2, badBlock2.MiniBlocks, timestamp)
+	badBlock3, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit2}}}, timestamp)
+	if err != nil {
+		panic(err)
+	}
+	accuse, err := test.NewAccuseBlockFraudProofStep(rollup, 2, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	steps := []test.Step{
+		{Action: test.SubmitDeposit, Data: deposit},
+		{Action: test.SubmitDeposit, Data: deposit2},
+		{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block1)},
+		{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(badBlock2)},
+		{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(badBlock3)},
+		{Action: test.AccuseBlockFraudProof, Data: accuse},
+	}
+	// the contract reverts block 2 and 3
+	if err := rollup.RevertTo(1); err != nil {
+		panic(err)
+	}
+	steps = append(steps, test.Step{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(rollup)})
+
+	block2, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{withdraw}}}, timestamp+100)
+	if err != nil {
+		panic(err)
+	}
+	block3, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit2}}}, timestamp+100)
+	if err != nil {
+		panic(err)
+	}
+	steps = append(steps,
+		test.Step{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block2)},
+		test.Step{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block3)},
+		test.Step{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(rollup)},
+		test.Step{Action: test.CompleteWithdraw, Data: withdraw},
+	)
+
+	return &test.Suit{
+		Msg:              "test case when block 2 of 3 is reverted and resubmitted",
+		GenesisStateHash: genesisHash,
+		AccountMax:       genesis.AccountMax,
+		Steps:            steps,
+	}
+}
+
+func main() {
+	var testSuits []*test.Suit
+	if err := test.Trace(testOutput, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+	}); err != nil {
+		panic(err)
+	}
+
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
+	b, err := json.MarshalIndent(testSuits, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(testOutput, b, 0644); err != nil {
+		panic(err)
+	}
+}
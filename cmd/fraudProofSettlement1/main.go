@@ -11,28 +11,23 @@ import (
 	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
 )
 
 const output = "testdata/fraudProofSettlement1.json"
+
 const benchmarkOutput = "benchmarkdata/fraudProofSettlement1.json"
 
+// benchmarkNumTxs is the number of txs of the benchmark miniblock,
+// the benchmark contract is deployed with this many txs per block
+const benchmarkNumTxs = 15
+
 type FraudProofTestSuit struct {
 	Msg              string
 	GenesisStateHash common.Hash
-	Blocks           []BlockData
-}
-type BlockData struct {
-	MiniBlocks      []*types.MiniBlock
-	Timestamp       uint32
-	MiniBlockNumber int
-	Proof           *FraudProof
-}
-
-type FraudProof struct {
-	PrevStateData      *blockchain.StateData
-	PrevStateHashProof hexutil.Bytes
-	MiniBlockProof     hexutil.Bytes
-	ExecutionProof     []hexutil.Bytes
+	Blocks           []blockchain.BlockData
+	// Calldata holds the calls submitting Blocks and accusing the last one, see test.NewFraudProofSteps
+	Calldata []hexutil.Bytes `json:",omitempty"`
 }
 
 var (
@@ -74,8 +69,9 @@ var genesis = &blockchain.Genesis{
 	LooMax:     0,
 }
 
-func buildTest1() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *FraudProofTestSuit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 
 	preStateData := bc.GetStateData()
@@ -120,12 +116,15 @@ func buildTest1() *FraudProofTestSuit {
 		},
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-	blockData := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: []byte{},
 			ExecutionProof:     executionProofs,
@@ -135,21 +134,31 @@ func buildTest1() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case when left over order at order 2",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
 }
 
-func buildTestForSecondBlock() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
-	genesisHash := bc.GetStateData().Hash()
-	preStateData := bc.GetStateData()
+// buildEmptyMiniBlock returns the blockchain after an empty miniblock on genesis,
+// the prefix of the tests against the second block and the second miniblock
+func buildEmptyMiniBlock(tracer blockchain.Tracer) (bc *blockchain.Blockchain, genesisStateData *blockchain.StateData, miniBlock1 *types.MiniBlock) {
+	bc = blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
+	genesisStateData = bc.GetStateData()
+	miniBlock1 = &types.MiniBlock{Txs: nil}
+	if _, err := bc.AddMiniBlock(miniBlock1); err != nil {
+		panic(err)
+	}
+	return bc, genesisStateData, miniBlock1
+}
 
-	miniBlock1 := &types.MiniBlock{Txs: nil}
-	bc.AddMiniBlock(miniBlock1)
+func buildTestForSecondBlock(prefix *blockchain.Blockchain, genesisStateData *blockchain.StateData, miniBlock1 *types.MiniBlock) *FraudProofTestSuit {
+	bc := prefix.Clone()
+	genesisHash := genesisStateData.Hash()
+	preStateData := genesisStateData
 
-	blockData1 := BlockData{
+	blockData1 := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661870,
 		MiniBlockNumber: -1,
@@ -194,12 +203,15 @@ func buildTestForSecondBlock() *FraudProofTestSuit {
 			},
 		},
 	}
-	executionProofs := bc.AddMiniBlock(miniBlock2)
-	blockData2 := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock2)
+	if err != nil {
+		panic(err)
+	}
+	blockData2 := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock2},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: proof.BuildFinalStateHashProof(blockData1.MiniBlocks, blockData1.Timestamp),
 			ExecutionProof:     executionProofs,
@@ -209,16 +221,13 @@ func buildTestForSecondBlock() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case when miniBlock is at block number = 2",
 		GenesisStateHash: genesisHash,
-		Blocks:           []BlockData{blockData1, blockData2},
+		Blocks:           []blockchain.BlockData{blockData1, blockData2},
 	}
 }
 
-func buildTestForSecondMiniBlock() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
-	genesisHash := bc.GetStateData().Hash()
-
-	miniBlock1 := &types.MiniBlock{Txs: nil}
-	bc.AddMiniBlock(miniBlock1)
+func buildTestForSecondMiniBlock(prefix *blockchain.Blockchain, genesisStateData *blockchain.StateData, miniBlock1 *types.MiniBlock) *FraudProofTestSuit {
+	bc := prefix.Clone()
+	genesisHash := genesisStateData.Hash()
 
 	preStateData := bc.GetStateData()
 	miniBlock2 := &types.MiniBlock{
@@ -260,13 +269,16 @@ func buildTestForSecondMiniBlock() *FraudProofTestSuit {
 			},
 		},
 	}
-	executionProofs := bc.AddMiniBlock(miniBlock2)
+	executionProofs, err := bc.AddMiniBlock(miniBlock2)
+	if err != nil {
+		panic(err)
+	}
 
-	blockData := BlockData{
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1, miniBlock2},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 1,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:  preStateData,
 			ExecutionProof: executionProofs,
 		},
@@ -276,19 +288,24 @@ func buildTestForSecondMiniBlock() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case when mimiBlockNumber = 2",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
 }
 
-func buildTest2() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest2(tracer blockchain.Tracer) *FraudProofTestSuit {
+	params := blockchain.DefaultParams()
+	params.NumTxPerBlock = benchmarkNumTxs
+	// every tx settles the same order pair, the contract can not tell a replayed order
+	params.AllowReplayedOrders = true
+	bc := blockchain.NewBlockchain(genesis, params)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	preStateData := bc.GetStateData()
 
 	var txs []types.Transaction
-	for i := 0; i < 15; i++ {
+	for i := 0; i < benchmarkNumTxs; i++ {
 		txs = append(txs, &types.Settlement1{
 			OpType:   types.SettlementOp11,
 			Token1:   1,
@@ -330,12 +347,15 @@ func buildTest2() *FraudProofTestSuit {
 		Txs: txs,
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-	blockData := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: []byte{},
 			ExecutionProof:     executionProofs,
@@ -345,7 +365,7 @@ func buildTest2() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case with 15 orders",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
@@ -353,10 +373,21 @@ func buildTest2() *FraudProofTestSuit {
 
 func main() {
 	var testSuits []*FraudProofTestSuit
-	testSuits = append(testSuits, buildTest1())
-	testSuits = append(testSuits, buildTestForSecondBlock())
-	testSuits = append(testSuits, buildTestForSecondMiniBlock())
+	if err := test.Trace(output, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+		prefix, genesisStateData, miniBlock1 := buildEmptyMiniBlock(tracer)
+		testSuits = append(testSuits, buildTestForSecondBlock(prefix, genesisStateData, miniBlock1))
+		testSuits = append(testSuits, buildTestForSecondMiniBlock(prefix, genesisStateData, miniBlock1))
+	}); err != nil {
+		panic(err)
+	}
 
+	var err error
+	for _, suit := range testSuits {
+		if suit.Calldata, err = test.CalldataFromEnv(test.NewFraudProofSteps(suit.Blocks...)...); err != nil {
+			panic(err)
+		}
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
@@ -366,7 +397,16 @@ func main() {
 	}
 
 	var testSuits2 []*FraudProofTestSuit
-	testSuits2 = append(testSuits2, buildTest2())
+	if err := test.Trace(benchmarkOutput, func(tracer blockchain.Tracer) {
+		testSuits2 = append(testSuits2, buildTest2(tracer))
+	}); err != nil {
+		panic(err)
+	}
+	for _, suit := range testSuits2 {
+		if suit.Calldata, err = test.CalldataFromEnv(test.NewFraudProofSteps(suit.Blocks...)...); err != nil {
+			panic(err)
+		}
+	}
 	b, err = json.MarshalIndent(testSuits2, "", "  ")
 	if err != nil {
 		panic(err)
@@ -374,5 +414,4 @@ func main() {
 	if err := ioutil.WriteFile(benchmarkOutput, b, 0644); err != nil {
 		panic(err)
 	}
-
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
 )
 
 const output = "testdata/fraudProofSettlement2.json"
@@ -18,20 +19,9 @@ const output = "testdata/fraudProofSettlement2.json"
 type FraudProofTestSuit struct {
 	Msg              string
 	GenesisStateHash common.Hash
-	Blocks           []BlockData
-}
-type BlockData struct {
-	MiniBlocks      []*types.MiniBlock
-	Timestamp       uint32
-	MiniBlockNumber int
-	Proof           *FraudProof
-}
-
-type FraudProof struct {
-	PrevStateData      *blockchain.StateData
-	PrevStateHashProof hexutil.Bytes
-	MiniBlockProof     hexutil.Bytes
-	ExecutionProof     []hexutil.Bytes
+	Blocks           []blockchain.BlockData
+	// Calldata holds the calls submitting Blocks and accusing the last one, see test.NewFraudProofSteps
+	Calldata []hexutil.Bytes `json:",omitempty"`
 }
 
 var (
@@ -85,8 +75,9 @@ var genesis = &blockchain.Genesis{
 	},
 }
 
-func buildTest1() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *FraudProofTestSuit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	preStateData := bc.GetStateData()
 
@@ -114,12 +105,15 @@ func buildTest1() *FraudProofTestSuit {
 		},
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-	blockData := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: []byte{},
 			ExecutionProof:     executionProofs,
@@ -129,14 +123,15 @@ func buildTest1() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case when looID1 is fully filled and create new loo2",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
 }
 
-func buildTest2() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest2(tracer blockchain.Tracer) *FraudProofTestSuit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	preStateData := bc.GetStateData()
 
@@ -164,12 +159,15 @@ func buildTest2() *FraudProofTestSuit {
 		},
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-	blockData := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: []byte{},
 			ExecutionProof:     executionProofs,
@@ -179,7 +177,7 @@ func buildTest2() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case when looID1 continues to be partially filled",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
@@ -187,9 +185,19 @@ func buildTest2() *FraudProofTestSuit {
 
 func main() {
 	var testSuits []*FraudProofTestSuit
-	testSuits = append(testSuits, buildTest1())
-	testSuits = append(testSuits, buildTest2())
+	if err := test.Trace(output, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+		testSuits = append(testSuits, buildTest2(tracer))
+	}); err != nil {
+		panic(err)
+	}
 
+	var err error
+	for _, suit := range testSuits {
+		if suit.Calldata, err = test.CalldataFromEnv(test.NewFraudProofSteps(suit.Blocks...)...); err != nil {
+			panic(err)
+		}
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
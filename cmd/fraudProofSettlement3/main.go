@@ -13,28 +13,22 @@ import (
 	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
 )
 
 const testOutput = "testdata/fraudProofSettlement3.json"
 const benchmarkOutput = "benchmarkdata/fraudProofSettlement3.json"
 
+// benchmarkNumTxs is the number of txs of the benchmark miniblock,
+// the benchmark contract is deployed with this many txs per block
+const benchmarkNumTxs = 15
+
 type FraudProofTestSuit struct {
 	Msg              string
 	GenesisStateHash common.Hash
-	Blocks           []BlockData
-}
-type BlockData struct {
-	MiniBlocks      []*types.MiniBlock
-	Timestamp       uint32
-	MiniBlockNumber int
-	Proof           *FraudProof
-}
-
-type FraudProof struct {
-	PrevStateData      *blockchain.StateData
-	PrevStateHashProof hexutil.Bytes
-	MiniBlockProof     hexutil.Bytes
-	ExecutionProof     []hexutil.Bytes
+	Blocks           []blockchain.BlockData
+	// Calldata holds the calls submitting Blocks and accusing the last one, see test.NewFraudProofSteps
+	Calldata []hexutil.Bytes `json:",omitempty"`
 }
 
 var (
@@ -98,8 +92,9 @@ var genesis = &blockchain.Genesis{
 	},
 }
 
-func buildTest1() *FraudProofTestSuit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *FraudProofTestSuit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	preStateData := bc.GetStateData()
 
@@ -112,12 +107,15 @@ func buildTest1() *FraudProofTestSuit {
 		},
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-	blockData := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: []byte{},
 			ExecutionProof:     executionProofs,
@@ -127,13 +125,13 @@ func buildTest1() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "test case when looID1 is fully filled and loo2 is partially filled",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
 }
 
-func buildBenchmarkTest() *FraudProofTestSuit {
+func buildBenchmarkTest(tracer blockchain.Tracer) *FraudProofTestSuit {
 	var benchmarkGenesis = &blockchain.Genesis{
 		AccountAlloc: map[uint32]blockchain.GenesisAccount{
 			0: blockchain.GenesisAccount{
@@ -164,7 +162,7 @@ func buildBenchmarkTest() *FraudProofTestSuit {
 		LooMax:     10000,
 	}
 
-	for i := 0; i < 15; i++ {
+	for i := 0; i < benchmarkNumTxs; i++ {
 		benchmarkGenesis.LooAlloc[uint64(i*2)] = &types.LeftOverOrder{
 			AccountID:   1,
 			SrcToken:    1,
@@ -187,7 +185,10 @@ func buildBenchmarkTest() *FraudProofTestSuit {
 		}
 	}
 
-	bc := blockchain.NewBlockchain(benchmarkGenesis)
+	params := blockchain.DefaultParams()
+	params.NumTxPerBlock = benchmarkNumTxs
+	bc := blockchain.NewBlockchain(benchmarkGenesis, params)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 	preStateData := bc.GetStateData()
 
@@ -195,19 +196,22 @@ func buildBenchmarkTest() *FraudProofTestSuit {
 		Txs: []types.Transaction{},
 	}
 
-	for i := 0; i < 15; i++ {
+	for i := 0; i < benchmarkNumTxs; i++ {
 		miniBlock1.Txs = append(miniBlock1.Txs, &types.Settlement3{
 			LooID1: uint64(i * 2),
 			LooID2: uint64(i*2 + 1),
 		})
 	}
 
-	executionProofs := bc.AddMiniBlock(miniBlock1)
-	blockData := BlockData{
+	executionProofs, err := bc.AddMiniBlock(miniBlock1)
+	if err != nil {
+		panic(err)
+	}
+	blockData := blockchain.BlockData{
 		MiniBlocks:      []*types.MiniBlock{miniBlock1},
 		Timestamp:       1600661872,
 		MiniBlockNumber: 0,
-		Proof: &FraudProof{
+		Proof: &blockchain.FraudProof{
 			PrevStateData:      preStateData,
 			PrevStateHashProof: []byte{},
 			ExecutionProof:     executionProofs,
@@ -217,7 +221,7 @@ func buildBenchmarkTest() *FraudProofTestSuit {
 	return &FraudProofTestSuit{
 		Msg:              "benchmark to settlement 2 loo order",
 		GenesisStateHash: genesisHash,
-		Blocks: []BlockData{
+		Blocks: []blockchain.BlockData{
 			blockData,
 		},
 	}
@@ -226,7 +230,18 @@ func buildBenchmarkTest() *FraudProofTestSuit {
 
 func main() {
 	var testSuits []*FraudProofTestSuit
-	testSuits = append(testSuits, buildTest1())
+	if err := test.Trace(testOutput, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+	}); err != nil {
+		panic(err)
+	}
+
+	var err error
+	for _, suit := range testSuits {
+		if suit.Calldata, err = test.CalldataFromEnv(test.NewFraudProofSteps(suit.Blocks...)...); err != nil {
+			panic(err)
+		}
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
@@ -236,7 +251,16 @@ func main() {
 	}
 
 	var testSuits2 []*FraudProofTestSuit
-	testSuits2 = append(testSuits2, buildBenchmarkTest())
+	if err := test.Trace(benchmarkOutput, func(tracer blockchain.Tracer) {
+		testSuits2 = append(testSuits2, buildBenchmarkTest(tracer))
+	}); err != nil {
+		panic(err)
+	}
+	for _, suit := range testSuits2 {
+		if suit.Calldata, err = test.CalldataFromEnv(test.NewFraudProofSteps(suit.Blocks...)...); err != nil {
+			panic(err)
+		}
+	}
 	b, err = json.MarshalIndent(testSuits2, "", "  ")
 	if err != nil {
 		panic(err)
@@ -5,7 +5,6 @@ import (
 	"io/ioutil"
 	"math/big"
 
-	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
 	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
@@ -62,25 +61,20 @@ var genesis = &blockchain.Genesis{
 	},
 }
 
-func buildTest1() *test.Suit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *test.Suit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
+	rollup := blockchain.NewRollup(bc)
 	// create an deposit to user
 	deposit := &types.DepositOp{
 		AccountID: 23,
 		TokenID:   2,
 		Amount:    big.NewInt(45242000),
 	}
-	miniBlock1 := &types.MiniBlock{
-		Txs: []types.Transaction{
-			deposit,
-		},
-	}
-	bc.AddMiniBlock(miniBlock1)
-	submitBlockStep := test.SubmitBlockStep{
-		BlockNumber: 1,
-		MiniBlocks:  []*types.MiniBlock{miniBlock1},
-		Timestamp:   1600661872,
+	block1, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit}}}, 1600661872)
+	if err != nil {
+		panic(err)
 	}
 	// create an withdraw to another user
 	withdraw := &types.WithdrawOp{
@@ -91,40 +85,24 @@ func buildTest1() *test.Suit {
 		ValidSince: 0,
 		Fee:        types.PackedFee{Mantisa: 1, Exp: 2},
 	}
-	miniBlock2 := &types.MiniBlock{
-		Txs: []types.Transaction{
-			withdraw,
-		},
-	}
-	prevStateData := bc.GetStateData()
-	executionProofs := bc.AddMiniBlock(miniBlock2)
-	submitBlockStep2 := test.SubmitBlockStep{
-		BlockNumber: 2,
-		MiniBlocks:  []*types.MiniBlock{miniBlock2},
-		Timestamp:   1600661872,
+	block2, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{withdraw}}}, 1600661872)
+	if err != nil {
+		panic(err)
 	}
-
-	accuseStep := test.AccuseBlockFraudProofStep{
-		BlockNumber:        2,
-		MiniBlockNumber:    0,
-		MiniBlock:          miniBlock2,
-		PrevStateData:      prevStateData,
-		MiniBlockProof:     proof.BuildMiniBlockProof(submitBlockStep2.MiniBlocks, 0, submitBlockStep2.Timestamp),
-		PrevStateHashProof: proof.BuildFinalStateHashProof(submitBlockStep.MiniBlocks, submitBlockStep.Timestamp),
-		ExecutionProof:     executionProofs,
+	accuse, err := test.NewAccuseBlockFraudProofStep(rollup, 2, 0)
+	if err != nil {
+		panic(err)
 	}
 
-	//blockData2.Proof.MiniBlockProof = proof.BuildMiniBlockProof(blockData2.MiniBlocks, uint(blockData2.MiniBlockNumber), blockData2.Timestamp)
-
 	return &test.Suit{
 		Msg:              "test case when withdraw",
 		GenesisStateHash: genesisHash,
 		AccountMax:       genesis.AccountMax,
 		Steps: []test.Step{
 			{Action: test.SubmitDeposit, Data: deposit},
-			{Action: test.SubmitBlock, Data: submitBlockStep},
-			{Action: test.SubmitBlock, Data: submitBlockStep2},
-			{Action: test.AccuseBlockFraudProof, Data: accuseStep},
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block1)},
+			{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block2)},
+			{Action: test.AccuseBlockFraudProof, Data: accuse},
 			{Action: test.CompleteWithdraw, Data: withdraw},
 		},
 	}
@@ -132,8 +110,15 @@ func buildTest1() *test.Suit {
 
 func main() {
 	var testSuits []*test.Suit
-	testSuits = append(testSuits, buildTest1())
+	if err := test.Trace(testOutput, func(tracer blockchain.Tracer) {
+		testSuits = append(testSuits, buildTest1(tracer))
+	}); err != nil {
+		panic(err)
+	}
 
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
 	b, err := json.MarshalIndent(testSuits, "", "  ")
 	if err != nil {
 		panic(err)
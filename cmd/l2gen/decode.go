@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// decode prints the fields of miniblock data or of a zk message with their offsets:
+//
+//	l2gen decode 0x...
+//	l2gen decode -zk msg.hex
+func decode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	zk := fs.Bool("zk", false, "decode a zk message, by default a zk message is detected by its length and layout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: l2gen decode [-zk] <hex|file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	data, err := readData(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var sections []types.Section
+	if *zk || len(data) == types.ZkMsgLength {
+		if sections, err = types.DisassembleZkMsg(data); err != nil && *zk {
+			return err
+		}
+	}
+	if sections == nil {
+		if sections, err = types.DisassembleMiniBlock(data); err != nil {
+			return err
+		}
+	}
+	return printSections(os.Stdout, data, sections)
+}
+
+// readData reads the hex data of arg, or of the file named arg if it exists. A file not holding hex is read as raw bytes.
+func readData(arg string) ([]byte, error) {
+	content := []byte(arg)
+	isFile := false
+	if _, err := os.Stat(arg); err == nil {
+		if content, err = ioutil.ReadFile(arg); err != nil {
+			return nil, err
+		}
+		isFile = true
+	}
+	s := strings.TrimPrefix(strings.Join(strings.Fields(string(content)), ""), "0x")
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		if isFile {
+			return content, nil
+		}
+		return nil, fmt.Errorf("%q is neither a file nor hex data: %w", arg, err)
+	}
+	return data, nil
+}
+
+func printSections(out io.Writer, data []byte, sections []types.Section) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, section := range sections {
+		fmt.Fprintf(w, "%s\t[%d:%d]\n", section.Name, section.Offset, section.Offset+section.Size)
+		for _, field := range section.Fields {
+			raw := data[field.Offset : field.Offset+field.Size]
+			fmt.Fprintf(w, "  %s\t[%d:%d]\t%s\t%s\n", field.Name, field.Offset, field.Offset+field.Size,
+				hex.EncodeToString(raw), field.Value)
+		}
+	}
+	return w.Flush()
+}
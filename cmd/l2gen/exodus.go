@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+// exodus writes the submitExit and completeExit calls taking every fund out of the chain rebuilt from L1 data,
+// proven against a finalized block, see test.NewExodusSteps:
+//
+//	l2gen exodus -state genesis.json -deposits deposits.json -block 12 -out exodus.json calldata.txt
+func exodus(args []string) error {
+	fs := flag.NewFlagSet("exodus", flag.ExitOnError)
+	chain := addChainFlags(fs)
+	blockNumber := fs.Uint("block", 0, "last finalized block, the last block by default")
+	out := fs.String("out", "", "file to write the suit of exit steps with their calldata to, stdout by default")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: l2gen exodus -state <genesis> [-deposits <file>] [-block <number>] [-out <file>] <calldata file>")
+		fmt.Fprintln(fs.Output(), calldataUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || !chain.valid() {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	contract, genesis, r, err := chain.reconstruct(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *blockNumber == 0 {
+		*blockNumber = uint(r.BlockNumber())
+	}
+	if *blockNumber == 0 || *blockNumber > uint(r.BlockNumber()) {
+		return fmt.Errorf("block %d not found, the chain has %d blocks", *blockNumber, r.BlockNumber())
+	}
+	steps, err := test.NewExodusSteps(r, uint32(*blockNumber))
+	if err != nil {
+		return err
+	}
+	suit := &test.Suit{
+		Msg:              fmt.Sprintf("exodus after block %d", *blockNumber),
+		GenesisStateHash: genesis.StateHash,
+		AccountMax:       genesis.AccountMax,
+		Steps:            steps,
+	}
+	if err := suit.EncodeCalldata(contract); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(suit, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = fmt.Println(string(b))
+		return err
+	}
+	return ioutil.WriteFile(*out, b, 0644)
+}
@@ -0,0 +1,47 @@
+// l2gen gathers the tools working on the data of the contract:
+//
+//	go run ./cmd/l2gen decode <hex|file>
+//	go run ./cmd/l2gen reconstruct -state <genesis> -deposits <file> <calldata file>
+//	go run ./cmd/l2gen exodus -state <genesis> -deposits <file> -block <number> <calldata file>
+//	go run ./cmd/l2gen watch -state <genesis> -deposits <file> <calldata directory>
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// commands maps the name of a command to its function, called with the arguments after the name
+var commands = map[string]func(args []string) error{
+	"decode":      decode,
+	"exodus":      exodus,
+	"reconstruct": reconstruct,
+	"watch":       watch,
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: l2gen <%s> [flags] [args]\n", strings.Join(names, "|"))
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := command(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+// chainFlags are the flags of the commands working on the chain rebuilt from L1 data
+type chainFlags struct {
+	abiFile      *string
+	stateFile    *string
+	depositsFile *string
+}
+
+func addChainFlags(fs *flag.FlagSet) *chainFlags {
+	return &chainFlags{
+		abiFile:      fs.String("abi", os.Getenv(test.ABIEnv), "contract ABI or compiler artifact, "+test.ABIEnv+" by default"),
+		stateFile:    fs.String("state", "", "state dump of the genesis"),
+		depositsFile: fs.String("deposits", "", "JSON list of the L1 deposits, a deposit with a PubKey is a deposit to a new account"),
+	}
+}
+
+func (f *chainFlags) valid() bool {
+	return *f.stateFile != "" && *f.abiFile != ""
+}
+
+// reconstruct loads the contract ABI and the genesis, and rebuilds the chain from the submitBlock calldata of calldataFile.
+// The rollup holds the valid blocks if the chain fails.
+func (f *chainFlags) reconstruct(calldataFile string) (*abi.ABI, *blockchain.StateDump, *blockchain.Rollup, error) {
+	contract, err := test.LoadABI(*f.abiFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	calldata, err := loadCalldata(calldataFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var blocks []*blockchain.SubmittedBlock
+	for i, data := range calldata {
+		blk, err := test.UnpackSubmitBlock(contract, data)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		blocks = append(blocks, blk)
+	}
+	deposits := make(map[uint64]types.Transaction)
+	if *f.depositsFile != "" {
+		if deposits, err = loadDeposits(*f.depositsFile); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	genesis, err := blockchain.LoadStateDump(*f.stateFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bc, err := blockchain.Import(genesis)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r, err := blockchain.Reconstruct(bc, blocks, deposits)
+	return contract, genesis, r, err
+}
+
+// reconstruct rebuilds the state from the calldata of every submitBlock call and the L1 deposits,
+// checking every state hash and block root stored by the contract, see blockchain.Reconstruct:
+//
+//	l2gen reconstruct -state genesis.json -deposits deposits.json -out state.json calldata.txt
+func reconstruct(args []string) error {
+	fs := flag.NewFlagSet("reconstruct", flag.ExitOnError)
+	chain := addChainFlags(fs)
+	out := fs.String("out", "", "file to write the dump of the reconstructed state to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: l2gen reconstruct -state <genesis> [-deposits <file>] [-out <file>] <calldata file>")
+		fmt.Fprintln(fs.Output(), calldataUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || !chain.valid() {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	_, _, r, err := chain.reconstruct(fs.Arg(0))
+	if r != nil {
+		for i := uint32(1); i <= r.BlockNumber(); i++ {
+			blk, err := r.Block(i)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("block %d\troot %s\tstate hash %s\n", i, r.BlockRoot(i).Hex(), blk.Header.StateHash.Hex())
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		dump, err := r.Blockchain().Export()
+		if err != nil {
+			return err
+		}
+		return dump.WriteFile(*out)
+	}
+	return nil
+}
+
+const calldataUsage = "The calldata file holds the hex calldata of every submitBlock call in order, a call per line or a JSON list."
+
+// loadCalldata reads a JSON list of hex calldata, or hex calldata a line, skipping empty lines and lines starting with #
+func loadCalldata(path string) ([]hexutil.Bytes, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var calldata []hexutil.Bytes
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &calldata); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return calldata, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		call, err := hexutil.Decode(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		calldata = append(calldata, call)
+	}
+	return calldata, scanner.Err()
+}
+
+// loadDeposits reads a JSON list of types.DepositOp and types.DepositToNewOp by DepositID
+func loadDeposits(path string) (map[uint64]types.Transaction, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	deposits := make(map[uint64]types.Transaction)
+	for i, raw := range list {
+		var (
+			probe struct{ PubKey hexutil.Bytes }
+			tx    types.Transaction
+			id    uint64
+		)
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return nil, fmt.Errorf("%s: deposit %d: %w", path, i, err)
+		}
+		if probe.PubKey != nil {
+			deposit := &types.DepositToNewOp{}
+			if err := json.Unmarshal(raw, deposit); err != nil {
+				return nil, fmt.Errorf("%s: deposit %d: %w", path, i, err)
+			}
+			tx, id = deposit, deposit.DepositID
+		} else {
+			deposit := &types.DepositOp{}
+			if err := json.Unmarshal(raw, deposit); err != nil {
+				return nil, fmt.Errorf("%s: deposit %d: %w", path, i, err)
+			}
+			tx, id = deposit, deposit.DepositID
+		}
+		if _, ok := deposits[id]; ok {
+			return nil, fmt.Errorf("%s: deposit %d submitted twice", path, id)
+		}
+		deposits[id] = tx
+	}
+	return deposits, nil
+}
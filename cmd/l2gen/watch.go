@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+	"github.com/KyberNetwork/l2-contract-test-suite/watchtower"
+)
+
+// watch runs a watchtower printing, as JSON steps with their calldata, the accusations of the bad blocks submitted to the contract.
+// It follows either the calls saved in a directory, scanned every interval, or the contract on a node:
+//
+//	l2gen watch -state genesis.json -interval 5s calls/
+//	l2gen watch -state genesis.json -rpc ws://localhost:8546 -contract 0x... -from 1200
+func watch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	chain := addChainFlags(fs)
+	interval := fs.Duration("interval", 5*time.Second, "interval between two scans of the directory")
+	once := fs.Bool("once", false, "scan the directory once and exit")
+	rpc := fs.String("rpc", "", "websocket endpoint of the node to follow the contract on instead of a directory")
+	address := fs.String("contract", "", "address of the contract on the node")
+	from := fs.Uint64("from", 0, "L1 block to follow the contract from, its deployment")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: l2gen watch -state <genesis> [-deposits <file>] <directory>")
+		fmt.Fprintln(fs.Output(), "       l2gen watch -state <genesis> [-deposits <file>] -rpc <url> -contract <address> [-from <block>]")
+		fmt.Fprintln(fs.Output(), "The directory holds the hex calldata of every call to the contract in order, a call per file sorted by name.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !chain.valid() || (*rpc == "") != (fs.NArg() == 1) || (*rpc != "" && !common.IsHexAddress(*address)) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	contract, err := test.LoadABI(*chain.abiFile)
+	if err != nil {
+		return err
+	}
+	genesis, err := blockchain.LoadStateDump(*chain.stateFile)
+	if err != nil {
+		return err
+	}
+	bc, err := blockchain.Import(genesis)
+	if err != nil {
+		return err
+	}
+	w := watchtower.New(bc, contract)
+	if *chain.depositsFile != "" {
+		deposits, err := loadDeposits(*chain.depositsFile)
+		if err != nil {
+			return err
+		}
+		w.LoadDeposits(deposits)
+	}
+
+	if *rpc != "" {
+		client, err := ethclient.Dial(*rpc)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		steps := make(chan test.Step)
+		errs := make(chan error, 1)
+		go func() { errs <- w.Watch(context.Background(), client, common.HexToAddress(*address), *from, steps) }()
+		for {
+			select {
+			case step := <-steps:
+				if err := printAccusation(contract, step); err != nil {
+					return err
+				}
+			case err := <-errs:
+				return err
+			}
+		}
+	}
+
+	done := make(map[string]bool)
+	for {
+		steps, err := w.ScanDir(fs.Arg(0), done)
+		for _, step := range steps {
+			if err := printAccusation(contract, step); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if *once {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// printAccusation prints step as a JSON line, with its calldata if the contract has the accusation method
+func printAccusation(contract *abi.ABI, step test.Step) error {
+	if _, ok := contract.Methods[step.Action.Method()]; ok {
+		calldata, err := step.Pack(contract)
+		if err != nil {
+			return err
+		}
+		step.Calldata = calldata
+	}
+	b, err := json.Marshal(step)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s of block %d", step.Action.Method(), accusedBlock(step))
+	_, err = fmt.Println(string(b))
+	return err
+}
+
+func accusedBlock(step test.Step) uint {
+	switch data := step.Data.(type) {
+	case test.AccuseBlockFraudProofStep:
+		return data.BlockNumber
+	case test.AccuseCommitmentFraudProofStep:
+		return data.BlockNumber
+	}
+	return 0
+}
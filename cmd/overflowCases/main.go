@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const output = "testdata/overflowCases.json"
+
+// timestamp is the timestamp of the block submitting a miniblock
+const timestamp = 1600661872
+
+// OverflowTestSuit is a miniblock executed on the genesis with balances at the edge of uint256.
+// A miniblock which overflows reverts with Panic(PanicCode), otherwise ExecutionProofs is the proof of its execution.
+// Calldata holds the calls submitting a miniblock which executes alone in block 1, see test.NewSubmitMiniBlockSteps.
+// A reverting miniblock has no state hash nor commitment to submit, so it has no Calldata.
+type OverflowTestSuit struct {
+	Msg             string
+	MiniBlock       *types.MiniBlock
+	ExecutionProofs []hexutil.Bytes `json:",omitempty"`
+	PanicCode       uint8           `json:",omitempty"`
+	Error           string          `json:",omitempty"`
+	Calldata        []hexutil.Bytes `json:",omitempty"`
+}
+
+type OverflowTestSuits struct {
+	Genesis       *blockchain.Genesis
+	PrevStateHash common.Hash
+	Suits         []OverflowTestSuit
+}
+
+var (
+	oneRate = types.PackedAmount{Mantisa: 1, Exp: 18}
+	maxFee  = types.PackedFee{Mantisa: 1<<types.FeeMantisaBits - 1, Exp: 1<<types.FeeExpBits - 1}
+)
+
+func buildGenesis() *blockchain.Genesis {
+	return &blockchain.Genesis{
+		AccountAlloc: map[uint32]blockchain.GenesisAccount{
+			0: {
+				Tokens:  map[uint16]*big.Int{0: util.MaxUint256},
+				Pubkey:  testsample.PublicKeys[0],
+				Address: testsample.Accounts[0],
+			},
+			1: {
+				Tokens: map[uint16]*big.Int{
+					0: big.NewInt(10),
+					1: new(big.Int).Sub(util.MaxUint256, big.NewInt(1)),
+					2: big.NewInt(10),
+				},
+				Pubkey:  testsample.PublicKeys[1],
+				Address: testsample.Accounts[1],
+			},
+			2: {
+				Tokens: map[uint16]*big.Int{
+					0: big.NewInt(10),
+					1: big.NewInt(10),
+				},
+				Pubkey:  testsample.PublicKeys[2],
+				Address: testsample.Accounts[2],
+			},
+		},
+		AccountMax: 2,
+	}
+}
+
+// settlement sells amount of token 2 of account 1 for the same amount of token 1 of account 2
+func settlement(amount uint32) *types.Settlement1 {
+	return &types.Settlement1{
+		OpType:       types.SettlementOp11,
+		Token1:       2,
+		Token2:       1,
+		Account1:     1,
+		Account2:     2,
+		Rate1:        oneRate,
+		Rate2:        oneRate,
+		Amount1:      types.PackedAmount{Mantisa: amount},
+		Amount2:      types.PackedAmount{Mantisa: amount},
+		ValidSince1:  1600661872,
+		ValidSince2:  1600661873,
+		ValidPeriod1: 86400,
+		ValidPeriod2: 86400,
+	}
+}
+
+func withdraw(amount types.PackedAmount, fee types.PackedFee) *types.WithdrawOp {
+	return &types.WithdrawOp{
+		TokenID:    1,
+		Amount:     amount,
+		DestAddr:   testsample.Accounts[2],
+		AccountID:  2,
+		ValidSince: 1600661872,
+		Fee:        fee,
+	}
+}
+
+func buildSuit(genesis *blockchain.Genesis, tracer blockchain.Tracer, msg string, txs ...types.Transaction) OverflowTestSuit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
+	miniBlock := &types.MiniBlock{Txs: txs}
+	proofs, err := bc.AddMiniBlock(miniBlock)
+	if err != nil {
+		var arithmeticErr *util.ArithmeticError
+		if !errors.As(err, &arithmeticErr) {
+			panic(err)
+		}
+		return OverflowTestSuit{Msg: msg, MiniBlock: miniBlock, PanicCode: arithmeticErr.Code, Error: err.Error()}
+	}
+	calldata, err := test.CalldataFromEnv(test.NewSubmitMiniBlockSteps(miniBlock, 1, timestamp)...)
+	if err != nil {
+		panic(err)
+	}
+	return OverflowTestSuit{Msg: msg, MiniBlock: miniBlock, ExecutionProofs: proofs, Calldata: calldata}
+}
+
+func main() {
+	genesis := buildGenesis()
+	testSuits := OverflowTestSuits{
+		Genesis:       genesis,
+		PrevStateHash: blockchain.NewBlockchain(genesis, nil).GetStateData().Hash(),
+	}
+	if err := test.Trace(output, func(tracer blockchain.Tracer) {
+		add := func(msg string, txs ...types.Transaction) {
+			testSuits.Suits = append(testSuits.Suits, buildSuit(genesis, tracer, msg, txs...))
+		}
+
+		add("deposit fills the balance up to max uint256",
+			&types.DepositOp{AccountID: 1, TokenID: 1, Amount: big.NewInt(1)})
+		add("deposit overflows the balance",
+			&types.DepositOp{AccountID: 1, TokenID: 1, Amount: big.NewInt(2)})
+
+		add("settlement fills the balance up to max uint256", settlement(1))
+		add("settlement overflows the balance", settlement(2))
+		{
+			op := settlement(1)
+			op.Amount1 = types.PackedAmount{Mantisa: math.MaxUint32, Exp: 60}
+			op.Rate1 = types.PackedAmount{Mantisa: math.MaxUint32, Exp: 30}
+			add("settlement amount * rate overflows", op)
+
+			// the maker is partially filled with 1000 * 1e18, its fee * 1e21 overflows
+			op = settlement(1000)
+			op.Amount1 = types.PackedAmount{Mantisa: math.MaxUint32, Exp: 60}
+			op.Rate1 = types.PackedAmount{Mantisa: 1}
+			op.Fee1 = maxFee
+			add("settlement fee of a partial fill overflows", op)
+
+			op = settlement(1)
+			op.Amount2 = types.PackedAmount{Mantisa: 0, Exp: 78}
+			add("settlement amount 10**78 overflows", op)
+		}
+
+		add("withdraw the whole balance", withdraw(types.PackedAmount{Mantisa: 10}, types.PackedFee{}))
+		add("withdraw more than the balance", withdraw(types.PackedAmount{Mantisa: 11}, types.PackedFee{}))
+		add("withdraw amount 10**78 overflows", withdraw(types.PackedAmount{Mantisa: 1, Exp: 78}, types.PackedFee{}))
+		add("withdraw fee overflows the admin balance", withdraw(types.PackedAmount{Mantisa: 1}, types.PackedFee{Mantisa: 1}))
+	}); err != nil {
+		panic(err)
+	}
+
+	b, err := json.MarshalIndent(testSuits, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(output, b, 0644); err != nil {
+		panic(err)
+	}
+}
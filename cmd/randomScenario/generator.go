@@ -0,0 +1,576 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/settlement"
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+type opKind int
+
+const (
+	opDeposit opKind = iota
+	opDepositToNew
+	opSettlement1
+	opSettlement2
+	opSettlement3
+	opWithdraw
+	opExit
+	numOpKinds
+)
+
+var opKindNames = [numOpKinds]string{"deposit", "depositToNew", "settlement1", "settlement2", "settlement3", "withdraw", "exit"}
+
+// Config are the knobs of a random scenario
+type Config struct {
+	Seed int64
+	// Accounts is the max number of accounts, including the admin
+	Accounts int
+	// Tokens is the number of token ids used, from 0
+	Tokens int
+	Blocks int
+	// MaxMiniBlocks is the max number of miniblocks of a block
+	MaxMiniBlocks int
+	// Mix is the weight of every op kind
+	Mix [numOpKinds]int
+	// Hasher names the hash of the trees, see blockchain.Params
+	Hasher string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Seed:          1,
+		Accounts:      12,
+		Tokens:        4,
+		Blocks:        6,
+		MaxMiniBlocks: 3,
+		Mix:           [numOpKinds]int{2, 2, 4, 3, 2, 2, 1},
+	}
+}
+
+const (
+	genesisTimestamp = uint32(1600661872)
+	maxValidPeriod   = 1<<types.ValidPeriodBits - 1
+)
+
+var (
+	// rates are in 1e18, a maker and a taker cross if rate1 * rate2 <= 1e36
+	precisionSquare = new(big.Int).Mul(util.Precision, util.Precision)
+)
+
+// view is the state seen by the generator, only the fields needed to emit valid ops
+type view struct {
+	params     *blockchain.Params
+	accountMax uint32
+	balances   map[uint32]map[uint16]*big.Int
+	withdrawTo map[uint32]common.Address
+	exited     map[uint32]bool
+	loos       map[uint64]*types.LeftOverOrder
+	looMax     uint64
+}
+
+func (v *view) balance(accountID uint32, tokenID uint16) *big.Int {
+	if x, ok := v.balances[accountID][tokenID]; ok {
+		return x
+	}
+	return big.NewInt(0)
+}
+
+func (v *view) add(accountID uint32, tokenID uint16, x *big.Int) {
+	v.balances[accountID][tokenID] = new(big.Int).Add(v.balance(accountID, tokenID), x)
+}
+
+func (v *view) sub(accountID uint32, tokenID uint16, x *big.Int) {
+	balance := new(big.Int).Sub(v.balance(accountID, tokenID), x)
+	if balance.Sign() < 0 {
+		panic(fmt.Sprintf("negative balance of token %d of account %d", tokenID, accountID))
+	}
+	v.balances[accountID][tokenID] = balance
+}
+
+// traders returns the accounts which can trade, deposit or withdraw, ordered by id
+func (v *view) traders() []uint32 {
+	var accountIDs []uint32
+	for accountID := uint32(0); accountID <= v.accountMax; accountID++ {
+		if _, ok := v.balances[accountID]; ok && accountID != v.params.AdminIndex && !v.exited[accountID] {
+			accountIDs = append(accountIDs, accountID)
+		}
+	}
+	return accountIDs
+}
+
+// openLOOs returns the LOOs with a remaining amount whose owner has not exited, ordered by id
+func (v *view) openLOOs() []uint64 {
+	var looIDs []uint64
+	for looID, loo := range v.loos {
+		if loo.Amount.Sign() > 0 && !v.exited[loo.AccountID] {
+			looIDs = append(looIDs, looID)
+		}
+	}
+	sort.Slice(looIDs, func(i, j int) bool { return looIDs[i] < looIDs[j] })
+	return looIDs
+}
+
+// canPay returns true if accountID can sell amount of tokenID and pay fee in the fee token
+func (v *view) canPay(accountID uint32, tokenID uint16, amount, fee *big.Int) bool {
+	need := map[uint16]*big.Int{tokenID: new(big.Int).Set(amount)}
+	feeToken := v.params.FeeTokenIndex
+	if _, ok := need[feeToken]; !ok {
+		need[feeToken] = big.NewInt(0)
+	}
+	need[feeToken].Add(need[feeToken], fee)
+	for id, x := range need {
+		if v.balance(accountID, id).Cmp(x) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// settle applies a settlement between the owners of both orders, fees go to the admin at the end of the miniblock
+func (v *view) settle(account1, account2 uint32, token1, token2 uint16, amount1, amount2, fee1, fee2 *big.Int) {
+	v.sub(account1, token1, amount1)
+	v.add(account1, token2, amount2)
+	v.sub(account1, v.params.FeeTokenIndex, fee1)
+	v.sub(account2, token2, amount2)
+	v.add(account2, token1, amount1)
+	v.sub(account2, v.params.FeeTokenIndex, fee2)
+}
+
+func (v *view) addLOO(loo *types.LeftOverOrder) {
+	v.looMax++
+	v.loos[v.looMax] = loo
+}
+
+// generator emits random valid ops, it executes them on a rollup to check the view
+type generator struct {
+	config Config
+	rng    *rand.Rand
+	view   *view
+	rollup *blockchain.Rollup
+
+	timestamp uint32
+	// nonce is the ValidSince of the next order, every order is unique so none is replayed
+	nonce    uint32
+	totalFee *big.Int
+}
+
+func newGenerator(config Config) *generator {
+	params := blockchain.DefaultParams()
+	params.Hasher = config.Hasher
+	genesis := &blockchain.Genesis{
+		AccountAlloc: map[uint32]blockchain.GenesisAccount{
+			params.AdminIndex: {
+				Tokens:  map[uint16]*big.Int{},
+				Pubkey:  testsample.PublicKeys[0],
+				Address: testsample.Accounts[0],
+			},
+		},
+		AccountMax: params.AdminIndex,
+	}
+	return &generator{
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+		view: &view{
+			params:     params,
+			accountMax: genesis.AccountMax,
+			balances:   map[uint32]map[uint16]*big.Int{params.AdminIndex: {}},
+			withdrawTo: map[uint32]common.Address{params.AdminIndex: testsample.Accounts[0]},
+			exited:     map[uint32]bool{},
+			loos:       map[uint64]*types.LeftOverOrder{},
+		},
+		rollup:    blockchain.NewRollup(blockchain.NewBlockchain(genesis, params)),
+		timestamp: genesisTimestamp,
+		// orders are valid since before the first block
+		nonce:    genesisTimestamp - 1<<20,
+		totalFee: big.NewInt(0),
+	}
+}
+
+func (g *generator) nextNonce() uint32 {
+	g.nonce++
+	return g.nonce
+}
+
+// randAmount returns a random amount between 1e-3 and 1e3 tokens of 18 decimals
+func (g *generator) randAmount() *big.Int {
+	x := big.NewInt(1 + g.rng.Int63n(1e6))
+	return x.Mul(x, big.NewInt(1e12))
+}
+
+// randPart returns a random amount up to x, packed down
+func (g *generator) randPart(x *big.Int) (*big.Int, types.PackedAmount, bool) {
+	if x.Sign() <= 0 {
+		return nil, types.PackedAmount{}, false
+	}
+	part := new(big.Int).Mul(x, big.NewInt(1+g.rng.Int63n(100)))
+	part.Div(part, big.NewInt(100))
+	packed, err := types.PackAmount(part, types.RoundDown)
+	if err != nil {
+		panic(err)
+	}
+	if packed.Mantisa == 0 {
+		return nil, types.PackedAmount{}, false
+	}
+	return packed.Big(), packed, true
+}
+
+// amountOut returns the amount bought by a maker selling amount at rate, if it can be packed exactly
+func (g *generator) amountOut(amount *big.Int, rate types.PackedAmount) (*big.Int, types.PackedAmount, bool) {
+	x, err := settlement.AmountOut(amount, rate.Big())
+	if err != nil {
+		panic(err)
+	}
+	packed, err := types.PackAmount(x, types.RoundExact)
+	if err != nil || packed.Mantisa == 0 {
+		return nil, types.PackedAmount{}, false
+	}
+	return x, packed, true
+}
+
+// randFee returns a random fee up to 1e-4 tokens of 18 decimals, packed down
+func (g *generator) randFee() types.PackedFee {
+	if g.rng.Intn(4) == 0 {
+		return types.PackedFee{}
+	}
+	fee, err := types.PackFee(big.NewInt(g.rng.Int63n(1e14)), types.RoundDown)
+	if err != nil {
+		panic(err)
+	}
+	return fee
+}
+
+// randRate returns a random rate between 0.5 and 2 in 1e18, packed
+func (g *generator) randRate() types.PackedAmount {
+	return types.PackedAmount{Mantisa: uint32(5 + g.rng.Intn(16)), Exp: 17}
+}
+
+// crossRate returns a random rate of a taker which crosses the maker rate
+func (g *generator) crossRate(makerRate *big.Int) types.PackedAmount {
+	rate := new(big.Int).Div(precisionSquare, makerRate)
+	rate.Mul(rate, big.NewInt(int64(90+g.rng.Intn(11))))
+	rate.Div(rate, big.NewInt(100))
+	packed, err := types.PackAmount(rate, types.RoundDown)
+	if err != nil {
+		panic(err)
+	}
+	return packed
+}
+
+func (g *generator) randToken() uint16 {
+	return uint16(g.rng.Intn(g.config.Tokens))
+}
+
+func (g *generator) pick(accountIDs []uint32) uint32 {
+	return accountIDs[g.rng.Intn(len(accountIDs))]
+}
+
+// nextTx returns a random valid tx of kind and applies it to the view, or false if none is possible
+func (g *generator) nextTx(kind opKind) (types.Transaction, bool) {
+	v := g.view
+	traders := v.traders()
+	switch kind {
+	case opDepositToNew:
+		if int(v.accountMax)+1 >= g.config.Accounts {
+			return nil, false
+		}
+		op := &types.DepositToNewOp{
+			PubKey:     make(hexutil.Bytes, types.PubKeyLength),
+			WithdrawTo: common.BigToAddress(big.NewInt(g.rng.Int63())),
+			TokenID:    g.randToken(),
+			Amount:     g.randAmount(),
+		}
+		g.rng.Read(op.PubKey)
+		v.accountMax++
+		v.balances[v.accountMax] = map[uint16]*big.Int{op.TokenID: new(big.Int).Set(op.Amount)}
+		v.withdrawTo[v.accountMax] = op.WithdrawTo
+		return op, true
+
+	case opDeposit:
+		if len(traders) == 0 {
+			return nil, false
+		}
+		op := &types.DepositOp{AccountID: g.pick(traders), TokenID: g.randToken(), Amount: g.randAmount()}
+		v.add(op.AccountID, op.TokenID, op.Amount)
+		return op, true
+
+	case opSettlement1:
+		if len(traders) < 2 || g.config.Tokens < 2 {
+			return nil, false
+		}
+		op := &types.Settlement1{
+			Account1:     g.pick(traders),
+			Account2:     g.pick(traders),
+			Token1:       g.randToken(),
+			Token2:       g.randToken(),
+			Rate1:        g.randRate(),
+			Fee1:         g.randFee(),
+			Fee2:         g.randFee(),
+			ValidSince1:  g.nextNonce(),
+			ValidSince2:  g.nextNonce(),
+			ValidPeriod1: maxValidPeriod,
+			ValidPeriod2: maxValidPeriod,
+		}
+		if op.Account1 == op.Account2 || op.Token1 == op.Token2 {
+			return nil, false
+		}
+		op.Rate2 = g.crossRate(op.Rate1.Big())
+		if g.rng.Intn(2) == 0 {
+			// order 2 is the maker
+			op.Rate1 = g.crossRate(op.Rate2.Big())
+			op.ValidSince1, op.ValidSince2 = op.ValidSince2, op.ValidSince1
+		}
+		amount1, packed1, ok1 := g.randPart(v.balance(op.Account1, op.Token1))
+		amount2, packed2, ok2 := g.randPart(v.balance(op.Account2, op.Token2))
+		if ok1 && ok2 && g.rng.Intn(3) == 0 {
+			// the taker sells what the maker buys, both orders are fully filled
+			if op.ValidSince1 < op.ValidSince2 {
+				amount2, packed2, ok2 = g.amountOut(amount1, op.Rate1)
+			} else {
+				amount1, packed1, ok1 = g.amountOut(amount2, op.Rate2)
+			}
+		}
+		if !ok1 || !ok2 || !v.canPay(op.Account1, op.Token1, amount1, op.Fee1.Big()) ||
+			!v.canPay(op.Account2, op.Token2, amount2, op.Fee2.Big()) {
+			return nil, false
+		}
+		op.Amount1, op.Amount2 = packed1, packed2
+		op.OpType = types.SettlementOp11
+		filled1, filled2, fee1, fee2, loo, err := op.GetSettlementValue()
+		if err != nil {
+			panic(err)
+		}
+		if filled1.Sign() == 0 || filled2.Sign() == 0 {
+			return nil, false
+		}
+		if loo != nil {
+			// the op type tells which order is left over
+			op.OpType = types.SettlementOp12
+			if loo.AccountID == op.Account2 {
+				op.OpType = types.SettlementOp13
+			}
+			v.addLOO(loo)
+		}
+		v.settle(op.Account1, op.Account2, op.Token1, op.Token2, filled1, filled2, fee1, fee2)
+		g.totalFee.Add(g.totalFee, fee1).Add(g.totalFee, fee2)
+		return op, true
+
+	case opSettlement2:
+		looIDs := v.openLOOs()
+		if len(looIDs) == 0 || len(traders) == 0 {
+			return nil, false
+		}
+		looID := looIDs[g.rng.Intn(len(looIDs))]
+		loo := v.loos[looID].Clone()
+		op := &types.Settlement2{
+			LooID1:       looID,
+			AccountID2:   g.pick(traders),
+			Rate2:        g.crossRate(loo.Rate),
+			Fee2:         g.randFee(),
+			ValidSince2:  g.nextNonce(),
+			ValidPeriod2: maxValidPeriod,
+		}
+		amount2, packed2, ok := g.randPart(v.balance(op.AccountID2, loo.DestToken))
+		if !ok || op.AccountID2 == loo.AccountID || !v.canPay(loo.AccountID, loo.SrcToken, loo.Amount, loo.Fee) ||
+			!v.canPay(op.AccountID2, loo.DestToken, amount2, op.Fee2.Big()) {
+			return nil, false
+		}
+		op.Amount2 = packed2
+		op.OpType = types.SettlementOp21
+		filled1, filled2, fee1, fee2, loo2, err := op.GetSettlementValue(loo)
+		if err != nil {
+			panic(err)
+		}
+		if filled1.Sign() == 0 || filled2.Sign() == 0 {
+			return nil, false
+		}
+		v.loos[looID] = loo
+		if loo2 != nil {
+			op.OpType = types.SettlementOp22
+			v.addLOO(loo2)
+		}
+		v.settle(loo.AccountID, op.AccountID2, loo.SrcToken, loo.DestToken, filled1, filled2, fee1, fee2)
+		g.totalFee.Add(g.totalFee, fee1).Add(g.totalFee, fee2)
+		return op, true
+
+	case opSettlement3:
+		looIDs := v.openLOOs()
+		var pairs [][2]uint64
+		for _, id1 := range looIDs {
+			for _, id2 := range looIDs {
+				loo1, loo2 := v.loos[id1], v.loos[id2]
+				if loo1.AccountID != loo2.AccountID && loo1.SrcToken == loo2.DestToken && loo1.DestToken == loo2.SrcToken &&
+					new(big.Int).Mul(loo1.Rate, loo2.Rate).Cmp(precisionSquare) <= 0 &&
+					v.canPay(loo1.AccountID, loo1.SrcToken, loo1.Amount, loo1.Fee) &&
+					v.canPay(loo2.AccountID, loo2.SrcToken, loo2.Amount, loo2.Fee) {
+					pairs = append(pairs, [2]uint64{id1, id2})
+				}
+			}
+		}
+		if len(pairs) == 0 {
+			return nil, false
+		}
+		pair := pairs[g.rng.Intn(len(pairs))]
+		loo1, loo2 := v.loos[pair[0]].Clone(), v.loos[pair[1]].Clone()
+		fill1, fill2, err := settlement.Match(
+			&settlement.Order{Amount: loo1.Amount, Rate: loo1.Rate, Fee: loo1.Fee, ValidSince: loo1.ValidSince},
+			&settlement.Order{Amount: loo2.Amount, Rate: loo2.Rate, Fee: loo2.Fee, ValidSince: loo2.ValidSince},
+		)
+		if err != nil {
+			panic(err)
+		}
+		if fill1.Amount.Sign() == 0 || fill2.Amount.Sign() == 0 {
+			return nil, false
+		}
+		loo1.Amount, loo1.Fee = fill1.RemainingAmount, fill1.RemainingFee
+		loo2.Amount, loo2.Fee = fill2.RemainingAmount, fill2.RemainingFee
+		v.loos[pair[0]], v.loos[pair[1]] = loo1, loo2
+		v.settle(loo1.AccountID, loo2.AccountID, loo1.SrcToken, loo2.SrcToken, fill1.Amount, fill2.Amount, fill1.Fee, fill2.Fee)
+		g.totalFee.Add(g.totalFee, fill1.Fee).Add(g.totalFee, fill2.Fee)
+		return &types.Settlement3{LooID1: pair[0], LooID2: pair[1]}, true
+
+	case opWithdraw:
+		if len(traders) == 0 {
+			return nil, false
+		}
+		op := &types.WithdrawOp{
+			AccountID:  g.pick(traders),
+			TokenID:    g.randToken(),
+			ValidSince: g.timestamp,
+			Fee:        g.randFee(),
+		}
+		amount, packed, ok := g.randPart(v.balance(op.AccountID, op.TokenID))
+		if !ok || !v.canPay(op.AccountID, op.TokenID, amount, op.Fee.Big()) {
+			return nil, false
+		}
+		op.Amount = packed
+		op.DestAddr = v.withdrawTo[op.AccountID]
+		v.sub(op.AccountID, op.TokenID, amount)
+		v.sub(op.AccountID, v.params.FeeTokenIndex, op.Fee.Big())
+		g.totalFee.Add(g.totalFee, op.Fee.Big())
+		return op, true
+
+	case opExit:
+		// keep two traders so the scenario can go on once every account is opened
+		if len(traders) <= 2 {
+			return nil, false
+		}
+		op := &types.ExitOp{AccountID: g.pick(traders)}
+		v.exited[op.AccountID] = true
+		return op, true
+	}
+	panic("unknown op kind")
+}
+
+// randKind returns a random op kind by the weights of the mix
+func (g *generator) randKind() opKind {
+	total := 0
+	for _, w := range g.config.Mix {
+		total += w
+	}
+	x := g.rng.Intn(total)
+	for kind, w := range g.config.Mix {
+		if x < w {
+			return opKind(kind)
+		}
+		x -= w
+	}
+	panic("empty mix")
+}
+
+// nextMiniBlock returns up to NumTxPerBlock random valid txs
+func (g *generator) nextMiniBlock() (txs []types.Transaction) {
+	numTxs := 1 + g.rng.Intn(g.view.params.NumTxPerBlock)
+	for attempts := 0; len(txs) < numTxs && attempts < 20*numTxs; attempts++ {
+		if tx, ok := g.nextTx(g.randKind()); ok {
+			txs = append(txs, tx)
+		}
+	}
+	if len(txs) == 0 {
+		// a new account can always be opened, or a deposit made
+		for _, kind := range []opKind{opDepositToNew, opDeposit} {
+			if tx, ok := g.nextTx(kind); ok {
+				return []types.Transaction{tx}
+			}
+		}
+		panic("no valid tx")
+	}
+	return txs
+}
+
+// build returns the suit of the random scenario
+func (g *generator) build() *test.Suit {
+	suit := &test.Suit{
+		Msg:              fmt.Sprintf("random scenario of seed %d", g.config.Seed),
+		GenesisStateHash: g.rollup.Blockchain().GetStateData().Hash(),
+		AccountMax:       g.view.accountMax,
+	}
+	for i := 0; i < g.config.Blocks; i++ {
+		g.timestamp += uint32(1 + g.rng.Intn(600))
+		var miniBlocks []*types.MiniBlock
+		for j := 1 + g.rng.Intn(g.config.MaxMiniBlocks); j > 0; j-- {
+			txs := g.nextMiniBlock()
+			// the admin receives the fees at the end of the miniblock
+			g.view.add(g.view.params.AdminIndex, g.view.params.FeeTokenIndex, g.totalFee)
+			g.totalFee = big.NewInt(0)
+			miniBlocks = append(miniBlocks, &types.MiniBlock{Txs: txs})
+		}
+		blk, err := g.rollup.AddBlock(miniBlocks, g.timestamp)
+		if err != nil {
+			panic(err)
+		}
+		// deposits are submitted to the contract before the block including them
+		for _, miniBlock := range miniBlocks {
+			for _, tx := range miniBlock.Txs {
+				switch tx.(type) {
+				case *types.DepositToNewOp:
+					suit.Steps = append(suit.Steps, test.Step{Action: test.SubmitDepositToNew, Data: tx})
+				case *types.DepositOp:
+					suit.Steps = append(suit.Steps, test.Step{Action: test.SubmitDeposit, Data: tx})
+				}
+			}
+		}
+		suit.Steps = append(suit.Steps, test.Step{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(blk)})
+	}
+	suit.Steps = append(suit.Steps, test.Step{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(g.rollup)})
+	g.checkView()
+	return suit
+}
+
+// checkView panics if the balances of the view differ from the ones of the blockchain
+func (g *generator) checkView() {
+	dump, err := g.rollup.Blockchain().Export()
+	if err != nil {
+		panic(err)
+	}
+	for accountID, balances := range g.view.balances {
+		for tokenID, x := range balances {
+			y, ok := dump.Accounts[accountID].Tokens[tokenID]
+			if !ok {
+				y = big.NewInt(0)
+			}
+			if x.Cmp(y) != 0 {
+				panic(fmt.Sprintf("balance of token %d of account %d is %s, expect %s", tokenID, accountID, y, x))
+			}
+		}
+	}
+	if len(dump.LOOs) != len(g.view.loos) {
+		panic(fmt.Sprintf("%d loos, expect %d", len(dump.LOOs), len(g.view.loos)))
+	}
+	for looID, loo := range g.view.loos {
+		if dump.LOOs[looID].Hash() != loo.Hash() {
+			panic(fmt.Sprintf("loo %d differs", looID))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// randomScenario writes suits of random valid ops: deposits, settlements, withdraws and exits
+// packed into miniblocks of up to NumTxPerBlock txs. A seed always gives the same suit.
+//
+//	go run ./cmd/randomScenario -seed 7 -suits 100 -blocks 20 -mix settlement1=5,exit=0 -out /tmp/random.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const output = "testdata/randomScenario.json"
+
+// parseMix sets the weights of mix from a list of <op>=<weight>, the other weights are kept
+func parseMix(s string, mix *[numOpKinds]int) error {
+	if s == "" {
+		return nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Split(field, "=")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid mix %q, expect <op>=<weight>", field)
+		}
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil || weight < 0 {
+			return fmt.Errorf("invalid weight %q of %s", parts[1], parts[0])
+		}
+		kind := -1
+		for i, name := range opKindNames {
+			if name == parts[0] {
+				kind = i
+			}
+		}
+		if kind < 0 {
+			return fmt.Errorf("unknown op %q, expect one of %s", parts[0], strings.Join(opKindNames[:], ", "))
+		}
+		mix[kind] = weight
+	}
+	total := 0
+	for _, w := range mix {
+		total += w
+	}
+	if total == 0 {
+		return fmt.Errorf("every weight of the mix is zero")
+	}
+	return nil
+}
+
+func main() {
+	config := DefaultConfig()
+	var (
+		numSuits = flag.Int("suits", 3, "number of suits, of seeds seed, seed+1, ...")
+		mix      = flag.String("mix", "", "weights of the ops, e.g. settlement1=4,exit=0, ops are "+strings.Join(opKindNames[:], ", "))
+		out      = flag.String("out", output, "output file")
+	)
+	flag.Int64Var(&config.Seed, "seed", config.Seed, "seed of the first suit")
+	flag.IntVar(&config.Accounts, "accounts", config.Accounts, "max number of accounts, including the admin")
+	flag.IntVar(&config.Tokens, "tokens", config.Tokens, "number of tokens")
+	flag.IntVar(&config.Blocks, "blocks", config.Blocks, "number of blocks of a suit")
+	flag.IntVar(&config.MaxMiniBlocks, "miniblocks", config.MaxMiniBlocks, "max number of miniblocks of a block")
+	flag.StringVar(&config.Hasher, "hasher", config.Hasher, "hash of the trees, "+hasher.KeccakName+" or "+hasher.MiMC7Name)
+	flag.Parse()
+	if _, err := hasher.ByName(config.Hasher); err != nil {
+		log.Fatal(err)
+	}
+	if err := parseMix(*mix, &config.Mix); err != nil {
+		log.Fatal(err)
+	}
+	if config.Accounts < 2 || config.Tokens < 1 || config.Tokens > 1<<10 || config.Blocks < 1 || config.MaxMiniBlocks < 1 {
+		log.Fatal("expect at least 2 accounts, 1 to 1024 tokens, 1 block and 1 miniblock")
+	}
+
+	var testSuits []*test.Suit
+	if err := test.Trace(*out, func(tracer blockchain.Tracer) {
+		for i := 0; i < *numSuits; i++ {
+			c := config
+			c.Seed = config.Seed + int64(i)
+			g := newGenerator(c)
+			g.rollup.Blockchain().SetTracer(tracer)
+			testSuits = append(testSuits, g.build())
+		}
+	}); err != nil {
+		log.Fatal(err)
+	}
+	if err := test.EncodeCalldataFromEnv(testSuits...); err != nil {
+		panic(err)
+	}
+	b, err := json.MarshalIndent(testSuits, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(*out, b, 0644); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/runner"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+func TestGenerator(t *testing.T) {
+	config := DefaultConfig()
+	for seed := int64(0); seed < 20; seed++ {
+		config.Seed = seed
+		suit1, err := json.Marshal(newGenerator(config).build())
+		require.NoError(t, err)
+		suit2, err := json.Marshal(newGenerator(config).build())
+		require.NoError(t, err)
+		require.Equal(t, string(suit1), string(suit2), "seed %d", seed)
+	}
+
+	// two accounts and one token, only deposits and withdraws are possible
+	config = Config{Seed: 1, Accounts: 2, Tokens: 1, Blocks: 10, MaxMiniBlocks: 2, Mix: DefaultConfig().Mix}
+	require.NotPanics(t, func() { newGenerator(config).build() })
+}
+
+func TestContract(t *testing.T) {
+	config := DefaultConfig()
+	var suits []*test.Suit
+	for seed := int64(0); seed < 5; seed++ {
+		config.Seed = seed
+		suits = append(suits, newGenerator(config).build())
+	}
+	runner.RunSuitsFromEnv(t, suits...)
+}
@@ -13,7 +13,14 @@ import (
 	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
 )
 
-const testOutput = "simulateData/test1.json"
+const (
+	testOutput          = "simulateData/test1.json"
+	tokenRegistryFile   = "testsample/tokens.json"
+	test2Output         = "simulateData/test2.json"
+	test2BalancesOutput = "simulateData/test2.balances.json"
+	test2StateOutput    = "simulateData/test2.state.json"
+	test2GenesisOutput  = "simulateData/test2.genesis.json"
+)
 
 var genesis = &blockchain.Genesis{
 	AccountAlloc: map[uint32]blockchain.GenesisAccount{
@@ -30,8 +37,9 @@ var genesis = &blockchain.Genesis{
 	LooMax:     0,
 }
 
-func buildTest1() *test.Suit {
-	bc := blockchain.NewBlockchain(genesis)
+func buildTest1(tracer blockchain.Tracer) *test.Suit {
+	bc := blockchain.NewBlockchain(genesis, nil)
+	bc.SetTracer(tracer)
 	genesisHash := bc.GetStateData().Hash()
 
 	var steps []test.Step
@@ -50,13 +58,26 @@ func buildTest1() *test.Suit {
 		depositToNewTxs = append(depositToNewTxs, deposit)
 		steps = append(steps, test.Step{Action: test.SubmitDepositToNew, Data: deposit})
 	}
-	miniBlock1 := &types.MiniBlock{
-		Txs: depositToNewTxs,
+	// split the deposits into full miniblocks
+	var depositToNewMiniBlocks []*types.MiniBlock
+	numTxPerBlock := blockchain.DefaultParams().NumTxPerBlock
+	for len(depositToNewTxs) > 0 {
+		n := numTxPerBlock
+		if n > len(depositToNewTxs) {
+			n = len(depositToNewTxs)
+		}
+		miniBlock := &types.MiniBlock{
+			Txs: depositToNewTxs[:n],
+		}
+		if _, err := bc.AddMiniBlock(miniBlock); err != nil {
+			panic(err)
+		}
+		depositToNewMiniBlocks = append(depositToNewMiniBlocks, miniBlock)
+		depositToNewTxs = depositToNewTxs[n:]
 	}
-	bc.AddMiniBlock(miniBlock1)
 	steps = append(steps, test.Step{Action: test.SubmitBlock, Data: test.SubmitBlockStep{
 		BlockNumber: 1,
-		MiniBlocks:  []*types.MiniBlock{miniBlock1},
+		MiniBlocks:  depositToNewMiniBlocks,
 		Timestamp:   1600661872,
 	}})
 	// deposit steps
@@ -77,7 +98,9 @@ func buildTest1() *test.Suit {
 			miniBlock := &types.MiniBlock{
 				Txs: deposits,
 			}
-			bc.AddMiniBlock(miniBlock)
+			if _, err := bc.AddMiniBlock(miniBlock); err != nil {
+				panic(err)
+			}
 			depositMiniBlocks = append(depositMiniBlocks, miniBlock)
 		}
 		steps = append(steps, test.Step{Action: test.SubmitBlock, Data: test.SubmitBlockStep{
@@ -109,7 +132,9 @@ func buildTest1() *test.Suit {
 				},
 			},
 		}
-		bc.AddMiniBlock(settlement1Block)
+		if _, err := bc.AddMiniBlock(settlement1Block); err != nil {
+			panic(err)
+		}
 
 		var settlement2Block = &types.MiniBlock{
 			Txs: []types.Transaction{
@@ -142,7 +167,9 @@ func buildTest1() *test.Suit {
 				},
 			},
 		}
-		bc.AddMiniBlock(settlement2Block)
+		if _, err := bc.AddMiniBlock(settlement2Block); err != nil {
+			panic(err)
+		}
 
 		var settlement3Block = &types.MiniBlock{
 			Txs: []types.Transaction{
@@ -186,7 +213,9 @@ func buildTest1() *test.Suit {
 				},
 			},
 		}
-		bc.AddMiniBlock(settlement3Block)
+		if _, err := bc.AddMiniBlock(settlement3Block); err != nil {
+			panic(err)
+		}
 
 		steps = append(steps, test.Step{Action: test.SubmitBlock, Data: test.SubmitBlockStep{
 			BlockNumber: 3,
@@ -202,14 +231,181 @@ func buildTest1() *test.Suit {
 	}
 }
 
-func main() {
-	var testSuits *test.Suit = buildTest1()
-	b, err := json.MarshalIndent(testSuits, "", "  ")
+func mustParseAmount(registry *types.TokenRegistry, s string) (uint16, *big.Int) {
+	token, amount, err := registry.ParseAmount(s)
+	if err != nil {
+		panic(err)
+	}
+	return token.ID, amount
+}
+
+func mustParseFee(registry *types.TokenRegistry, s string) types.PackedFee {
+	tokenID, amount := mustParseAmount(registry, s)
+	if tokenID != blockchain.DefaultParams().FeeTokenIndex {
+		panic("fee is not paid in the fee token: " + s)
+	}
+	fee, err := types.PackFee(amount, types.RoundExact)
+	if err != nil {
+		panic(err)
+	}
+	return fee
+}
+
+// mustParseSettlement1 returns the settlement of order1 of account1 against order2 of account2,
+// orders are written as "1.5 ETH at 2000 USDT/ETH" and fees as "0.001 ETH"
+func mustParseSettlement1(registry *types.TokenRegistry, account1 uint32, order1, fee1 string,
+	account2 uint32, order2, fee2 string, validSince uint32) *types.Settlement1 {
+	spec1, err := registry.ParseOrder(order1)
+	if err != nil {
+		panic(err)
+	}
+	spec2, err := registry.ParseOrder(order2)
+	if err != nil {
+		panic(err)
+	}
+	if spec1.Src != spec2.Dst || spec1.Dst != spec2.Src {
+		panic("orders are not on the same pair")
+	}
+	amount1, rate1, err := spec1.Pack(types.RoundExact)
+	if err != nil {
+		panic(err)
+	}
+	amount2, rate2, err := spec2.Pack(types.RoundExact)
+	if err != nil {
+		panic(err)
+	}
+	return &types.Settlement1{
+		OpType:       types.SettlementOp11,
+		Token1:       spec1.Src.ID,
+		Token2:       spec2.Src.ID,
+		Account1:     account1,
+		Account2:     account2,
+		Rate1:        rate1,
+		Rate2:        rate2,
+		Amount1:      amount1,
+		Amount2:      amount2,
+		Fee1:         mustParseFee(registry, fee1),
+		Fee2:         mustParseFee(registry, fee2),
+		ValidSince1:  validSince,
+		ValidSince2:  validSince + 1,
+		ValidPeriod1: 86400,
+		ValidPeriod2: 86400,
+	}
+}
+
+var test2Genesis = &blockchain.Genesis{
+	AccountAlloc: map[uint32]blockchain.GenesisAccount{
+		0: {
+			Tokens:  map[uint16]*big.Int{},
+			Pubkey:  testsample.PublicKeys[0],
+			Address: testsample.Accounts[0],
+		},
+	},
+}
+
+// buildTest2 is a scenario written in token units of the registry,
+// it returns the suit and the blockchain after it
+func buildTest2(registry *types.TokenRegistry, tracer blockchain.Tracer) (*test.Suit, *blockchain.Blockchain) {
+	rollup := blockchain.NewRollup(blockchain.NewBlockchain(test2Genesis, nil))
+	rollup.Blockchain().SetTracer(tracer)
+	genesisHash := rollup.Blockchain().GetStateData().Hash()
+	timestamp := uint32(1600661872)
+
+	var (
+		steps    []test.Step
+		deposits []types.Transaction
+	)
+	for i, amount := range []string{"10 ETH", "50000 USDT"} {
+		tokenID, value := mustParseAmount(registry, amount)
+		deposit := &types.DepositToNewOp{
+			PubKey:     testsample.PublicKeys[i+1],
+			WithdrawTo: testsample.Accounts[i+1],
+			TokenID:    tokenID,
+			Amount:     value,
+		}
+		deposits = append(deposits, deposit)
+		steps = append(steps, test.Step{Action: test.SubmitDepositToNew, Data: deposit})
+	}
+	tokenID, value := mustParseAmount(registry, "0.1 ETH")
+	deposit := &types.DepositOp{AccountID: 2, TokenID: tokenID, Amount: value}
+	deposits = append(deposits, deposit)
+	steps = append(steps, test.Step{Action: test.SubmitDeposit, Data: deposit})
+
+	block1, err := rollup.AddBlock([]*types.MiniBlock{{Txs: deposits}}, timestamp)
 	if err != nil {
 		panic(err)
 	}
-	if err := ioutil.WriteFile(testOutput, b, 0644); err != nil {
+	settlement := mustParseSettlement1(registry,
+		1, "1.5 ETH at 2000 USDT/ETH", "0.001 ETH",
+		2, "3000 USDT at 2000 USDT/ETH", "0.002 ETH",
+		timestamp)
+	block2, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{settlement}}}, timestamp+100)
+	if err != nil {
 		panic(err)
 	}
+	steps = append(steps,
+		test.Step{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block1)},
+		test.Step{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(block2)},
+	)
+
+	return &test.Suit{
+		Msg:              "sell 1.5 ETH for 3000 USDT",
+		GenesisStateHash: genesisHash,
+		AccountMax:       test2Genesis.AccountMax,
+		Steps:            steps,
+	}, rollup.Blockchain()
+}
 
+func writeJSON(path string, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var testSuits *test.Suit
+	if err := test.Trace(testOutput, func(tracer blockchain.Tracer) {
+		testSuits = buildTest1(tracer)
+	}); err != nil {
+		panic(err)
+	}
+	if err := test.EncodeCalldataFromEnv(testSuits); err != nil {
+		panic(err)
+	}
+	writeJSON(testOutput, testSuits)
+
+	registry, err := types.LoadTokenRegistry(tokenRegistryFile)
+	if err != nil {
+		panic(err)
+	}
+	var (
+		test2 *test.Suit
+		bc    *blockchain.Blockchain
+	)
+	if err := test.Trace(test2Output, func(tracer blockchain.Tracer) {
+		test2, bc = buildTest2(registry, tracer)
+	}); err != nil {
+		panic(err)
+	}
+	if err := test.EncodeCalldataFromEnv(test2); err != nil {
+		panic(err)
+	}
+	writeJSON(test2Output, test2)
+	writeJSON(test2BalancesOutput, bc.DumpBalances(registry))
+	for path, bc := range map[string]*blockchain.Blockchain{
+		test2GenesisOutput: blockchain.NewBlockchain(test2Genesis, nil),
+		test2StateOutput:   bc,
+	} {
+		dump, err := bc.Export()
+		if err != nil {
+			panic(err)
+		}
+		if err := dump.WriteFile(path); err != nil {
+			panic(err)
+		}
+	}
 }
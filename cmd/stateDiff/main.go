@@ -0,0 +1,257 @@
+// stateDiff lists what changed between two states: either two state dumps written by Blockchain.Export,
+//
+//	go run ./cmd/stateDiff a.json b.json
+//
+// or two points of a suit replayed from the dump of its genesis, a point is "genesis" or "<step>:<miniblock>",
+// the state after miniblock <miniblock> of the SubmitBlock step <step>
+//
+//	go run ./cmd/stateDiff -state simulateData/test2.genesis.json -suit simulateData/test2.json -from 3:0 -to 4:0
+//
+// Like diff, it exits with status 1 if the states differ.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+var (
+	stateFile  = flag.String("state", "", "state dump of the genesis of the suit")
+	suitFile   = flag.String("suit", "", "suit to replay, a suit or a list of suits")
+	suitIndex  = flag.Int("index", 0, "index of the suit if the file holds a list of suits")
+	from       = flag.String("from", "genesis", "first point of the suit, genesis or <step>:<miniblock>")
+	to         = flag.String("to", "", "second point of the suit, genesis or <step>:<miniblock>")
+	tokensFile = flag.String("tokens", "", "token registry to print amounts in token units")
+)
+
+// point is the state after miniblock miniBlock of step step, step -1 is the genesis
+type point struct {
+	step      int
+	miniBlock int
+}
+
+func parsePoint(s string) (point, error) {
+	if s == "genesis" {
+		return point{step: -1}, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return point{}, fmt.Errorf("invalid point %q, expect genesis or <step>:<miniblock>", s)
+	}
+	step, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return point{}, fmt.Errorf("invalid step of point %q: %w", s, err)
+	}
+	miniBlock, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return point{}, fmt.Errorf("invalid miniblock of point %q: %w", s, err)
+	}
+	return point{step: step, miniBlock: miniBlock}, nil
+}
+
+// rawSuit is a test.Suit with the data of its steps left undecoded
+type rawSuit struct {
+	GenesisStateHash string
+	Steps            []struct {
+		Action test.StepType
+		Data   json.RawMessage
+		Revert bool
+	}
+}
+
+func loadSuit(path string, index int) (*rawSuit, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		var suits []*rawSuit
+		if err := json.Unmarshal(data, &suits); err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= len(suits) {
+			return nil, fmt.Errorf("suit %d not found, %s holds %d suits", index, path, len(suits))
+		}
+		return suits[index], nil
+	}
+	var suit rawSuit
+	if err := json.Unmarshal(data, &suit); err != nil {
+		return nil, err
+	}
+	return &suit, nil
+}
+
+// replay executes the blocks of suit on genesis and returns the state at every point of points.
+// Blocks reverted by a successful accusation are dropped as the contract does.
+func replay(genesis *blockchain.StateDump, suit *rawSuit, points ...point) (map[point]*blockchain.StateDump, error) {
+	bc, err := blockchain.Import(genesis)
+	if err != nil {
+		return nil, err
+	}
+	if suit.GenesisStateHash != genesis.StateHash.Hex() {
+		return nil, fmt.Errorf("genesis state hash of the suit %s differs from the dump %s", suit.GenesisStateHash, genesis.StateHash.Hex())
+	}
+	dumps := make(map[point]*blockchain.StateDump)
+	capture := func(p point) error {
+		for _, wanted := range points {
+			if wanted == p {
+				dump, err := bc.Export()
+				if err != nil {
+					return err
+				}
+				dumps[p] = dump
+			}
+		}
+		return nil
+	}
+	if err := capture(point{step: -1}); err != nil {
+		return nil, err
+	}
+
+	var (
+		deposits = make(map[uint64]types.Transaction)
+		// states[n] is the state after block n
+		states = []*blockchain.Blockchain{bc.Clone()}
+	)
+	for i, step := range suit.Steps {
+		if len(dumps) == len(points) {
+			break
+		}
+		if step.Revert {
+			continue
+		}
+		switch step.Action {
+		case test.SubmitDeposit:
+			var deposit types.DepositOp
+			if err := json.Unmarshal(step.Data, &deposit); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i, err)
+			}
+			deposits[deposit.DepositID] = &deposit
+		case test.SubmitDepositToNew:
+			var deposit types.DepositToNewOp
+			if err := json.Unmarshal(step.Data, &deposit); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i, err)
+			}
+			deposits[deposit.DepositID] = &deposit
+		case test.SubmitBlock:
+			var data struct{ MiniBlocks []hexutil.Bytes }
+			if err := json.Unmarshal(step.Data, &data); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i, err)
+			}
+			for j, miniBlockData := range data.MiniBlocks {
+				miniBlock, err := types.DecodeMiniBlock(miniBlockData)
+				if err != nil {
+					return nil, fmt.Errorf("step %d miniblock %d: %w", i, j, err)
+				}
+				if _, err := blockchain.FillDeposits(miniBlock, deposits, bc.NumDeposit()); err != nil {
+					return nil, fmt.Errorf("step %d miniblock %d: %w", i, j, err)
+				}
+				claimed := miniBlock.StateHash
+				if _, err := bc.AddMiniBlock(miniBlock); err != nil {
+					// the rest of the block can not be executed, it is expected to be accused
+					log.Printf("step %d miniblock %d fails: %v", i, j, err)
+					break
+				}
+				if miniBlock.StateHash != claimed {
+					log.Printf("step %d miniblock %d: state hash %s, submitted %s", i, j, miniBlock.StateHash.Hex(), claimed.Hex())
+				}
+				if err := capture(point{step: i, miniBlock: j}); err != nil {
+					return nil, err
+				}
+			}
+			states = append(states, bc.Clone())
+		case test.AccuseBlockFraudProof, test.AccuseCommitmentFraudProof:
+			var data struct{ BlockNumber int }
+			if err := json.Unmarshal(step.Data, &data); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i, err)
+			}
+			if data.BlockNumber < 1 || data.BlockNumber >= len(states) {
+				return nil, fmt.Errorf("step %d accuses unknown block %d", i, data.BlockNumber)
+			}
+			states = states[:data.BlockNumber]
+			bc = states[data.BlockNumber-1].Clone()
+		}
+	}
+	for _, p := range points {
+		if _, ok := dumps[p]; !ok {
+			return nil, fmt.Errorf("point %d:%d not reached", p.step, p.miniBlock)
+		}
+	}
+	return dumps, nil
+}
+
+func loadDumps() (a, b *blockchain.StateDump, err error) {
+	if *suitFile == "" {
+		if flag.NArg() != 2 {
+			return nil, nil, fmt.Errorf("expect two state dumps, or -state and -suit")
+		}
+		if a, err = blockchain.LoadStateDump(flag.Arg(0)); err != nil {
+			return nil, nil, err
+		}
+		if b, err = blockchain.LoadStateDump(flag.Arg(1)); err != nil {
+			return nil, nil, err
+		}
+		return a, b, nil
+	}
+
+	if *stateFile == "" || *to == "" {
+		return nil, nil, fmt.Errorf("-suit requires -state and -to")
+	}
+	genesis, err := blockchain.LoadStateDump(*stateFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	suit, err := loadSuit(*suitFile, *suitIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	p1, err := parsePoint(*from)
+	if err != nil {
+		return nil, nil, err
+	}
+	p2, err := parsePoint(*to)
+	if err != nil {
+		return nil, nil, err
+	}
+	dumps, err := replay(genesis, suit, p1, p2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dumps[p1], dumps[p2], nil
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	a, b, err := loadDumps()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var registry *types.TokenRegistry
+	if *tokensFile != "" {
+		if registry, err = types.LoadTokenRegistry(*tokensFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	diff := blockchain.Diff(a, b)
+	if diff.IsEmpty() {
+		fmt.Println("no change")
+		return
+	}
+	fmt.Println(diff.Format(registry))
+	os.Exit(1)
+}
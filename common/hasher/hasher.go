@@ -0,0 +1,71 @@
+package hasher
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/sha3"
+)
+
+// names of the hashers, see ByName
+const (
+	KeccakName = "keccak"
+	MiMC7Name  = "mimc7"
+)
+
+// Hasher hashes the nodes of the merkle trees and their leaves: accounts, LOOs and the state data
+type Hasher interface {
+	// Hash returns the hash of the concatenation of data
+	Hash(data ...[]byte) common.Hash
+	// HashPair returns the hash of 2 words, e.g. the parent of 2 nodes of a tree
+	HashPair(left, right common.Hash) common.Hash
+}
+
+var (
+	// Keccak is the Keccak256 of the deployed contract
+	Keccak Hasher = keccakHasher{}
+	// MiMC7 is the MiMC-7 sponge over the BN254 scalar field of circomlib, a SNARK-friendly hash
+	MiMC7 Hasher = mimc7Hasher{}
+)
+
+// ByName returns the hasher with the given name, the empty name is Keccak
+func ByName(name string) (Hasher, error) {
+	switch name {
+	case "", KeccakName:
+		return Keccak, nil
+	case MiMC7Name:
+		return MiMC7, nil
+	}
+	return nil, fmt.Errorf("unknown hasher %q", name)
+}
+
+type keccakHasher struct{}
+
+func (keccakHasher) Hash(data ...[]byte) common.Hash {
+	return crypto.Keccak256Hash(data...)
+}
+
+// keccakBuffer is a hasher with its input and output, pooled so that HashPair does not allocate
+type keccakBuffer struct {
+	hasher crypto.KeccakState
+	in     [2 * common.HashLength]byte
+	out    common.Hash
+}
+
+var keccakPool = sync.Pool{New: func() interface{} {
+	return &keccakBuffer{hasher: sha3.NewLegacyKeccak256().(crypto.KeccakState)}
+}}
+
+func (keccakHasher) HashPair(left, right common.Hash) common.Hash {
+	buf := keccakPool.Get().(*keccakBuffer)
+	copy(buf.in[:], left[:])
+	copy(buf.in[common.HashLength:], right[:])
+	buf.hasher.Reset()
+	buf.hasher.Write(buf.in[:])
+	buf.hasher.Read(buf.out[:])
+	out := buf.out
+	keccakPool.Put(buf)
+	return out
+}
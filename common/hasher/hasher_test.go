@@ -0,0 +1,38 @@
+package hasher
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiMC7(t *testing.T) {
+	// the test vector of circomlib
+	require.Equal(t,
+		common.HexToHash("0x176c6eefc3fdf8d6136002d8e6f7a885bbd1c4e3957b93ddc1ec3ae7859f1a08"),
+		common.BigToHash(mimc7(big.NewInt(1), big.NewInt(2))),
+	)
+}
+
+func TestHasher(t *testing.T) {
+	left, right := common.HexToHash("0x01"), common.HexToHash("0xff")
+	require.Equal(t, crypto.Keccak256Hash(left.Bytes(), right.Bytes()), Keccak.HashPair(left, right))
+	require.Equal(t, Keccak.Hash(left.Bytes(), right.Bytes()), Keccak.HashPair(left, right))
+
+	// words above the field are reduced, data is cut in chunks of 31 bytes
+	max := common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	reduced := common.BigToHash(new(big.Int).Mod(max.Big(), bn254))
+	require.Equal(t, MiMC7.HashPair(reduced, right), MiMC7.HashPair(max, right))
+	data := append(append([]byte{}, max.Bytes()...), right.Bytes()...)
+	require.Equal(t, mimc7MultiHash(new(big.Int).SetBytes(data[:31]), new(big.Int).SetBytes(data[31:62]), new(big.Int).SetBytes(data[62:])), MiMC7.Hash(max.Bytes(), right.Bytes()))
+
+	for _, name := range []string{"", KeccakName, MiMC7Name} {
+		_, err := ByName(name)
+		require.NoError(t, err)
+	}
+	_, err := ByName("sha256")
+	require.Error(t, err)
+}
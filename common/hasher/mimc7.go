@@ -0,0 +1,86 @@
+package hasher
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	mimc7Seed   = "mimc"
+	mimc7Rounds = 91
+	// mimc7ChunkSize is the size of the chunks of the data of Hash, which are always field elements
+	mimc7ChunkSize = 31
+)
+
+// bn254 is the order of the scalar field of BN254
+var bn254, _ = new(big.Int).SetString("21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+
+// mimc7Constants[i] is the constant of round i, the first is zero and the next ones are keccak chained from the seed
+var mimc7Constants = func() []*big.Int {
+	constants := make([]*big.Int, mimc7Rounds)
+	constants[0] = new(big.Int)
+	c := crypto.Keccak256([]byte(mimc7Seed))
+	for i := 1; i < mimc7Rounds; i++ {
+		c = crypto.Keccak256(c)
+		constants[i] = new(big.Int).Mod(new(big.Int).SetBytes(c), bn254)
+	}
+	return constants
+}()
+
+// mimc7 returns the MiMC-7 encryption of x with key k, as the hash function of circomlib
+func mimc7(x, k *big.Int) *big.Int {
+	r, t := new(big.Int), new(big.Int)
+	for i := 0; i < mimc7Rounds; i++ {
+		if i == 0 {
+			t.Add(x, k)
+		} else {
+			t.Add(r, k).Add(t, mimc7Constants[i])
+		}
+		t.Mod(t, bn254)
+		// r = t^7
+		r.Mul(t, t).Mod(r, bn254)
+		r.Mul(r, t).Mod(r, bn254)
+		r.Mul(r, r).Mod(r, bn254)
+		r.Mul(r, t).Mod(r, bn254)
+	}
+	return r.Add(r, k).Mod(r, bn254)
+}
+
+// mimc7MultiHash absorbs the field elements in, as multiHash of circomlib with a zero key
+func mimc7MultiHash(in ...*big.Int) common.Hash {
+	r := new(big.Int)
+	for _, x := range in {
+		h := mimc7(x, r)
+		r.Add(r, x).Add(r, h).Mod(r, bn254)
+	}
+	return common.BigToHash(r)
+}
+
+type mimc7Hasher struct{}
+
+// Hash absorbs the data cut in big-endian chunks of 31 bytes, the last one may be shorter
+func (mimc7Hasher) Hash(data ...[]byte) common.Hash {
+	var packed []byte
+	for _, d := range data {
+		packed = append(packed, d...)
+	}
+	var in []*big.Int
+	for len(packed) > 0 {
+		n := mimc7ChunkSize
+		if len(packed) < n {
+			n = len(packed)
+		}
+		in = append(in, new(big.Int).SetBytes(packed[:n]))
+		packed = packed[n:]
+	}
+	return mimc7MultiHash(in...)
+}
+
+// HashPair absorbs the 2 words reduced in the field
+func (mimc7Hasher) HashPair(left, right common.Hash) common.Hash {
+	l := new(big.Int).SetBytes(left[:])
+	r := new(big.Int).SetBytes(right[:])
+	return mimc7MultiHash(l.Mod(l, bn254), r.Mod(r, bn254))
+}
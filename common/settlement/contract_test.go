@@ -0,0 +1,97 @@
+package settlement_test
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/runner"
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const timestamp = uint32(1600661872)
+
+// vectorSuit returns the suit settling v in block 1 and accusing it, the accusation reverts if the contract
+// settles v as Match does
+func vectorSuit(t *testing.T, v vector) *test.Suit {
+	var (
+		packed [4]types.PackedAmount
+		fees   [2]types.PackedFee
+		err    error
+	)
+	for i, x := range []*big.Int{v.order1.Amount, v.order1.Rate, v.order2.Amount, v.order2.Rate} {
+		packed[i], err = types.PackAmount(x, types.RoundExact)
+		require.NoError(t, err, v.name)
+	}
+	for i, x := range []*big.Int{v.order1.Fee, v.order2.Fee} {
+		fees[i], err = types.PackFee(x, types.RoundExact)
+		require.NoError(t, err, v.name)
+	}
+	// the fee token is token 0, the orders trade token 1 for token 2
+	genesis := &blockchain.Genesis{
+		AccountAlloc: map[uint32]blockchain.GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Pubkey: testsample.PublicKeys[0], Address: testsample.Accounts[0]},
+			1: {Tokens: map[uint16]*big.Int{0: v.order1.Fee, 1: v.order1.Amount}, Pubkey: testsample.PublicKeys[1], Address: testsample.Accounts[1]},
+			2: {Tokens: map[uint16]*big.Int{0: v.order2.Fee, 2: v.order2.Amount}, Pubkey: testsample.PublicKeys[2], Address: testsample.Accounts[2]},
+		},
+		AccountMax: 2,
+	}
+	op := &types.Settlement1{
+		OpType:   types.SettlementOp11,
+		Token1:   1,
+		Token2:   2,
+		Account1: 1,
+		Account2: 2,
+		Rate1:    packed[1],
+		Rate2:    packed[3],
+		Amount1:  packed[0],
+		Amount2:  packed[2],
+		Fee1:     fees[0],
+		Fee2:     fees[1],
+		// the orders keep the order of their valid since, and are valid at the block timestamp
+		ValidSince1:  timestamp - 10 + v.order1.ValidSince,
+		ValidSince2:  timestamp - 10 + v.order2.ValidSince,
+		ValidPeriod1: 1<<types.ValidPeriodBits - 1,
+		ValidPeriod2: 1<<types.ValidPeriodBits - 1,
+	}
+	amount1, amount2, fee1, fee2, loo, err := op.GetSettlementValue()
+	require.NoError(t, err, v.name)
+	require.Equal(t, v.expected, [4]int64{amount1.Int64(), fee1.Int64(), amount2.Int64(), fee2.Int64()}, v.name)
+	if loo != nil {
+		// the op type tells which order is left over
+		op.OpType = types.SettlementOp12
+		if loo.AccountID == op.Account2 {
+			op.OpType = types.SettlementOp13
+		}
+	}
+
+	rollup := blockchain.NewRollup(blockchain.NewBlockchain(genesis, nil))
+	suit := &test.Suit{
+		Msg:              fmt.Sprintf("settlement vector %s", v.name),
+		GenesisStateHash: rollup.Blockchain().GetStateData().Hash(),
+		AccountMax:       genesis.AccountMax,
+	}
+	blk, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{op}}}, timestamp)
+	require.NoError(t, err, v.name)
+	accuse, err := test.NewAccuseBlockFraudProofStep(rollup, 1, 0)
+	require.NoError(t, err, v.name)
+	suit.Steps = []test.Step{
+		{Action: test.SubmitBlock, Data: test.NewSubmitBlockStep(blk)},
+		{Action: test.AccuseBlockFraudProof, Data: accuse, Revert: true},
+		{Action: test.CheckBlockRoots, Data: test.NewCheckBlockRootsStep(rollup)},
+	}
+	return suit
+}
+
+func TestVectorsOnContract(t *testing.T) {
+	var suits []*test.Suit
+	for _, v := range vectors {
+		suits = append(suits, vectorSuit(t, v))
+	}
+	runner.RunSuitsFromEnv(t, suits...)
+}
@@ -0,0 +1,137 @@
+// Package settlement matches two orders the way the contract settles them.
+//
+// An order sells Amount of its src token for the dest token at Rate, the amount of dest token
+// per src token scaled by 1e18, and pays Fee in the fee token when it is fully filled.
+//
+// The maker is the order with the smaller ValidSince, order 1 on a tie, and the trade uses the rate
+// of the maker:
+//  1. the taker gives AmountOut(maker.Amount, maker.Rate) = floor(maker.Amount * maker.Rate / 1e18)
+//  2. if that is more than taker.Amount, the taker gives taker.Amount and the maker gives
+//     AmountIn(taker.Amount, maker.Rate) = ceil(taker.Amount * 1e18 / maker.Rate),
+//     otherwise the maker gives maker.Amount
+//
+// A fully filled order pays its whole fee, a partially filled order pays floor(Fee * filled / Amount).
+// The remaining amount and fee of a partially filled order become a left-over order.
+//
+// Every product and sum is uint256 checked arithmetic, an overflow is reported as *common.ArithmeticError
+// where the contract reverts.
+package settlement
+
+import (
+	"math/big"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+)
+
+type Order struct {
+	Amount     *big.Int
+	Rate       *big.Int
+	Fee        *big.Int
+	ValidSince uint32
+}
+
+// Fill is the part of an order executed by a settlement
+type Fill struct {
+	// Amount is the amount of src token sold
+	Amount *big.Int
+	// Fee is the fee paid for Amount
+	Fee             *big.Int
+	RemainingAmount *big.Int
+	RemainingFee    *big.Int
+}
+
+// IsPartial returns true if the order is left with a remaining amount
+func (f *Fill) IsPartial() bool {
+	return f.RemainingAmount.Sign() > 0
+}
+
+// AmountOut returns floor(amount * rate / 1e18)
+func AmountOut(amount *big.Int, rate *big.Int) (*big.Int, error) {
+	tmp, err := util.CheckedMul(amount, rate)
+	if err != nil {
+		return nil, err
+	}
+	return util.CheckedDiv(tmp, util.Precision)
+}
+
+// AmountIn returns ceil(amount * 1e18 / rate)
+func AmountIn(amount *big.Int, rate *big.Int) (*big.Int, error) {
+	tmp, err := util.CheckedMul(amount, util.Precision)
+	if err != nil {
+		return nil, err
+	}
+	if tmp, err = util.CheckedAdd(tmp, rate); err != nil {
+		return nil, err
+	}
+	if tmp, err = util.CheckedSub(tmp, big.NewInt(1)); err != nil {
+		return nil, err
+	}
+	return util.CheckedDiv(tmp, rate)
+}
+
+// Match settles order1 against order2, order2 sells the dest token of order1 for its src token
+func Match(order1, order2 *Order) (fill1, fill2 *Fill, err error) {
+	maker, taker := order1, order2
+	if order1.ValidSince > order2.ValidSince {
+		maker, taker = order2, order1
+	}
+	makerAmount, takerAmount, err := match(maker, taker)
+	if err != nil {
+		return nil, nil, err
+	}
+	makerFill, err := newFill(maker, makerAmount)
+	if err != nil {
+		return nil, nil, err
+	}
+	takerFill, err := newFill(taker, takerAmount)
+	if err != nil {
+		return nil, nil, err
+	}
+	if maker == order1 {
+		return makerFill, takerFill, nil
+	}
+	return takerFill, makerFill, nil
+}
+
+// match returns the amounts sold by the maker and the taker at the rate of the maker
+func match(maker, taker *Order) (makerAmount, takerAmount *big.Int, err error) {
+	takerAmount, err = AmountOut(maker.Amount, maker.Rate)
+	if err != nil {
+		return nil, nil, err
+	}
+	if takerAmount.Cmp(taker.Amount) > 0 {
+		makerAmount, err = AmountIn(taker.Amount, maker.Rate)
+		if err != nil {
+			return nil, nil, err
+		}
+		return makerAmount, new(big.Int).Set(taker.Amount), nil
+	}
+	return new(big.Int).Set(maker.Amount), takerAmount, nil
+}
+
+func newFill(order *Order, amount *big.Int) (*Fill, error) {
+	fee := new(big.Int).Set(order.Fee)
+	if amount.Cmp(order.Amount) < 0 {
+		tmp, err := util.CheckedMul(fee, amount)
+		if err != nil {
+			return nil, err
+		}
+		if fee, err = util.CheckedDiv(tmp, order.Amount); err != nil {
+			return nil, err
+		}
+	}
+	remainingAmount, err := util.CheckedSub(order.Amount, amount)
+	if err != nil {
+		return nil, err
+	}
+	remainingFee, err := util.CheckedSub(order.Fee, fee)
+	if err != nil {
+		return nil, err
+	}
+	return &Fill{
+		Amount:          amount,
+		Fee:             fee,
+		RemainingAmount: remainingAmount,
+		RemainingFee:    remainingFee,
+	}, nil
+}
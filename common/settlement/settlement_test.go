@@ -0,0 +1,130 @@
+package settlement_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/settlement"
+)
+
+func e18(x float64) *big.Int {
+	out, _ := new(big.Float).Mul(big.NewFloat(x), big.NewFloat(1e18)).Int(nil)
+	return out
+}
+
+// vector is an input of the settlement and its expected fills, the values fit the packed fields of a settlement1 op.
+// The expected fills are the ones of the contract: TestVectorsOnContract settles every vector in a block and accuses it,
+// the contract re-executes the settlement and the accusation only reverts if its fills give the same state.
+// It runs against the contract set by L2_ARTIFACT_DIR and L2_CONTRACT, see runner.FromEnv.
+type vector struct {
+	name           string
+	order1, order2 settlement.Order
+	// amount1, fee1, amount2, fee2
+	expected [4]int64
+}
+
+var vectors = []vector{
+	{
+		name:     "maker order1, taker filled, maker left over",
+		order1:   settlement.Order{Amount: big.NewInt(1000), Rate: e18(2), Fee: big.NewInt(100), ValidSince: 1},
+		order2:   settlement.Order{Amount: big.NewInt(1500), Rate: e18(0.5), Fee: big.NewInt(30), ValidSince: 2},
+		expected: [4]int64{750, 75, 1500, 30},
+	},
+	{
+		name:     "maker order1, maker filled, taker left over with fee rounded down",
+		order1:   settlement.Order{Amount: big.NewInt(1000), Rate: e18(1.5), Fee: big.NewInt(100), ValidSince: 1},
+		order2:   settlement.Order{Amount: big.NewInt(2000), Rate: e18(0.5), Fee: big.NewInt(30), ValidSince: 2},
+		expected: [4]int64{1000, 100, 1500, 22},
+	},
+	{
+		name:     "maker order1, amount in rounded up",
+		order1:   settlement.Order{Amount: big.NewInt(10), Rate: big.NewInt(333333333000000000), Fee: big.NewInt(9), ValidSince: 1},
+		order2:   settlement.Order{Amount: big.NewInt(2), Rate: e18(3), Fee: big.NewInt(5), ValidSince: 2},
+		expected: [4]int64{7, 6, 2, 5},
+	},
+	{
+		name:     "same valid since, order1 is the maker",
+		order1:   settlement.Order{Amount: big.NewInt(1000), Rate: e18(2), Fee: big.NewInt(100), ValidSince: 5},
+		order2:   settlement.Order{Amount: big.NewInt(2000), Rate: e18(0.25), Fee: big.NewInt(30), ValidSince: 5},
+		expected: [4]int64{1000, 100, 2000, 30},
+	},
+	{
+		name:     "maker order2, taker left over",
+		order1:   settlement.Order{Amount: big.NewInt(1000), Rate: e18(4), Fee: big.NewInt(100), ValidSince: 2},
+		order2:   settlement.Order{Amount: big.NewInt(1500), Rate: e18(0.5), Fee: big.NewInt(30), ValidSince: 1},
+		expected: [4]int64{750, 75, 1500, 30},
+	},
+	{
+		name:     "maker order2, taker filled, maker left over",
+		order1:   settlement.Order{Amount: big.NewInt(500), Rate: e18(4), Fee: big.NewInt(100), ValidSince: 2},
+		order2:   settlement.Order{Amount: big.NewInt(1500), Rate: e18(0.5), Fee: big.NewInt(30), ValidSince: 1},
+		expected: [4]int64{500, 100, 1000, 20},
+	},
+	{
+		name:     "maker order2, amount in rounded up",
+		order1:   settlement.Order{Amount: big.NewInt(2), Rate: e18(3), Fee: big.NewInt(5), ValidSince: 2},
+		order2:   settlement.Order{Amount: big.NewInt(10), Rate: big.NewInt(333333333000000000), Fee: big.NewInt(9), ValidSince: 1},
+		expected: [4]int64{2, 5, 7, 6},
+	},
+}
+
+func TestMatch(t *testing.T) {
+	for _, v := range vectors {
+		fill1, fill2, err := settlement.Match(&v.order1, &v.order2)
+		require.NoError(t, err, v.name)
+		require.Equal(t, v.expected[0], fill1.Amount.Int64(), v.name)
+		require.Equal(t, v.expected[1], fill1.Fee.Int64(), v.name)
+		require.Equal(t, v.expected[2], fill2.Amount.Int64(), v.name)
+		require.Equal(t, v.expected[3], fill2.Fee.Int64(), v.name)
+
+		for _, x := range []struct {
+			order *settlement.Order
+			fill  *settlement.Fill
+		}{{&v.order1, fill1}, {&v.order2, fill2}} {
+			require.Equal(t, x.order.Amount, new(big.Int).Add(x.fill.Amount, x.fill.RemainingAmount), v.name)
+			require.Equal(t, x.order.Fee, new(big.Int).Add(x.fill.Fee, x.fill.RemainingFee), v.name)
+		}
+		// at most one order is left over
+		require.False(t, fill1.IsPartial() && fill2.IsPartial(), v.name)
+	}
+}
+
+func TestAmountInOut(t *testing.T) {
+	rate := big.NewInt(333333333333333333)
+	out, err := settlement.AmountOut(big.NewInt(10), rate)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(3), out)
+	in, err := settlement.AmountIn(big.NewInt(2), rate)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(7), in)
+	in, err = settlement.AmountIn(big.NewInt(3), e18(0.5))
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(6), in)
+}
+
+func TestMatchOverflow(t *testing.T) {
+	var arithmeticErr *util.ArithmeticError
+	// amount * rate exceeds uint256
+	maxAmount := new(big.Int).Rsh(util.MaxUint256, 32)
+	_, _, err := settlement.Match(
+		&settlement.Order{Amount: maxAmount, Rate: e18(1 << 40), Fee: big.NewInt(0), ValidSince: 1},
+		&settlement.Order{Amount: big.NewInt(1), Rate: e18(1), Fee: big.NewInt(0), ValidSince: 2},
+	)
+	require.True(t, errors.As(err, &arithmeticErr))
+	require.Equal(t, uint8(util.PanicArithmetic), arithmeticErr.Code)
+
+	// fee * filled amount of a partially filled maker exceeds uint256
+	_, _, err = settlement.Match(
+		&settlement.Order{Amount: maxAmount, Rate: e18(1), Fee: maxAmount, ValidSince: 1},
+		&settlement.Order{Amount: big.NewInt(1 << 40), Rate: e18(1), Fee: big.NewInt(0), ValidSince: 2},
+	)
+	require.True(t, errors.As(err, &arithmeticErr))
+
+	_, err = settlement.AmountIn(big.NewInt(1), big.NewInt(0))
+	require.True(t, errors.As(err, &arithmeticErr))
+	require.Equal(t, uint8(util.PanicDivisionByZero), arithmeticErr.Code)
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"math/big"
+)
+
+var MaxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// Solidity panic codes of checked arithmetic
+const (
+	PanicArithmetic     = 0x11
+	PanicDivisionByZero = 0x12
+)
+
+// ArithmeticError is an uint256 operation which reverts with Panic(Code) in Solidity 0.8
+type ArithmeticError struct {
+	Code uint8
+	Op   string
+	X, Y *big.Int
+}
+
+func (e *ArithmeticError) Error() string {
+	if e.Code == PanicDivisionByZero {
+		return fmt.Sprintf("division by zero: %s %s %s", e.X.String(), e.Op, e.Y.String())
+	}
+	return fmt.Sprintf("arithmetic underflow or overflow: %s %s %s", e.X.String(), e.Op, e.Y.String())
+}
+
+func isUint256(x *big.Int) bool {
+	return x.Sign() >= 0 && x.Cmp(MaxUint256) <= 0
+}
+
+func checked(op string, x, y, z *big.Int) (*big.Int, error) {
+	if !isUint256(x) || !isUint256(y) || !isUint256(z) {
+		return nil, &ArithmeticError{Code: PanicArithmetic, Op: op, X: x, Y: y}
+	}
+	return z, nil
+}
+
+// CheckedAdd returns x + y, or an ArithmeticError if it overflows uint256
+func CheckedAdd(x, y *big.Int) (*big.Int, error) {
+	return checked("+", x, y, new(big.Int).Add(x, y))
+}
+
+// CheckedSub returns x - y, or an ArithmeticError if it underflows
+func CheckedSub(x, y *big.Int) (*big.Int, error) {
+	return checked("-", x, y, new(big.Int).Sub(x, y))
+}
+
+// CheckedMul returns x * y, or an ArithmeticError if it overflows uint256
+func CheckedMul(x, y *big.Int) (*big.Int, error) {
+	return checked("*", x, y, new(big.Int).Mul(x, y))
+}
+
+// CheckedDiv returns x / y rounded down, or an ArithmeticError if y is zero
+func CheckedDiv(x, y *big.Int) (*big.Int, error) {
+	if y.Sign() == 0 {
+		return nil, &ArithmeticError{Code: PanicDivisionByZero, Op: "/", X: x, Y: y}
+	}
+	return checked("/", x, y, new(big.Int).Div(x, y))
+}
+
+// CheckedExp returns x ** y, or an ArithmeticError if it overflows uint256
+func CheckedExp(x, y *big.Int) (*big.Int, error) {
+	if !isUint256(x) || !isUint256(y) {
+		return nil, &ArithmeticError{Code: PanicArithmetic, Op: "**", X: x, Y: y}
+	}
+	if x.Cmp(big.NewInt(1)) > 0 && y.BitLen() > 8 {
+		// x ** y >= 2 ** 256
+		return nil, &ArithmeticError{Code: PanicArithmetic, Op: "**", X: x, Y: y}
+	}
+	return checked("**", x, y, new(big.Int).Exp(x, y, nil))
+}
@@ -28,26 +28,22 @@ func GenerateRandomPubKey() hexutil.Bytes {
 	return out
 }
 
-func AddAmount(beforeValue common.Hash, value *big.Int) common.Hash {
-	return common.BigToHash(new(big.Int).Add(beforeValue.Big(), value))
-}
-
-func SubAmount(beforeValue common.Hash, value *big.Int) common.Hash {
-	if beforeValue.Big().Cmp(value) < 0 {
-		panic("insufficient funds")
+// AddAmount returns the balance beforeValue + value, or an ArithmeticError if it overflows uint256
+func AddAmount(beforeValue common.Hash, value *big.Int) (common.Hash, error) {
+	out, err := CheckedAdd(beforeValue.Big(), value)
+	if err != nil {
+		return common.Hash{}, err
 	}
-	return common.BigToHash(new(big.Int).Sub(beforeValue.Big(), value))
+	return common.BigToHash(out), nil
 }
 
-func CalAmountOut(amount *big.Int, rate *big.Int) *big.Int {
-	return new(big.Int).Div(new(big.Int).Mul(amount, rate), Precision)
-}
-
-func CalAmountIn(amount *big.Int, rate *big.Int) *big.Int {
-	tmp := new(big.Int).Mul(amount, Precision)
-	tmp = new(big.Int).Add(tmp, rate)
-	tmp = new(big.Int).Sub(tmp, big.NewInt(1))
-	return tmp.Div(tmp, rate)
+// SubAmount returns the balance beforeValue - value, or an ArithmeticError if the funds are insufficient
+func SubAmount(beforeValue common.Hash, value *big.Int) (common.Hash, error) {
+	out, err := CheckedSub(beforeValue.Big(), value)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BigToHash(out), nil
 }
 
 func GetMiniBlockHash(miniBlocks []common.Hash) common.Hash {
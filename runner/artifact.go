@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Artifact is a compiled contract: its ABI and creation bytecode
+type Artifact struct {
+	ABI      abi.ABI
+	Bytecode []byte
+}
+
+// LoadArtifact loads the contract name from dir. It accepts either a hardhat/truffle artifact
+// name.json with "abi" and "bytecode" fields, or the solc outputs name.abi and name.bin.
+func LoadArtifact(dir, name string) (*Artifact, error) {
+	jsonPath := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(jsonPath); err == nil {
+		return loadJSONArtifact(jsonPath)
+	}
+
+	abiData, err := ioutil.ReadFile(filepath.Join(dir, name+".abi"))
+	if err != nil {
+		return nil, err
+	}
+	contractABI, err := abi.JSON(bytes.NewReader(abiData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid abi of %s: %w", name, err)
+	}
+	binData, err := ioutil.ReadFile(filepath.Join(dir, name+".bin"))
+	if err != nil {
+		return nil, err
+	}
+	bytecode, err := decodeBytecode(string(binData))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytecode of %s: %w", name, err)
+	}
+	return &Artifact{ABI: contractABI, Bytecode: bytecode}, nil
+}
+
+func loadJSONArtifact(path string) (*Artifact, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var artifact struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode string          `json:"bytecode"`
+	}
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("invalid artifact %s: %w", path, err)
+	}
+	contractABI, err := abi.JSON(bytes.NewReader(artifact.ABI))
+	if err != nil {
+		return nil, fmt.Errorf("invalid abi in %s: %w", path, err)
+	}
+	bytecode, err := decodeBytecode(artifact.Bytecode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytecode in %s: %w", path, err)
+	}
+	return &Artifact{ABI: contractABI, Bytecode: bytecode}, nil
+}
+
+func decodeBytecode(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "0x") {
+		s = "0x" + s
+	}
+	return hexutil.Decode(s)
+}
@@ -0,0 +1,264 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+const defaultGasLimit = 12000000
+
+// Environment variables read by FromEnv
+const (
+	ArtifactDirEnv    = "L2_ARTIFACT_DIR"
+	ContractEnv       = "L2_CONTRACT"
+	SkipBlockRootsEnv = "L2_SKIP_BLOCK_ROOTS"
+)
+
+var senderBalance = new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)
+
+// Config describes the contract under test
+type Config struct {
+	// ArtifactDir is the directory holding the compiled contract, see LoadArtifact
+	ArtifactDir string
+	// Contract is the artifact name of the contract
+	Contract string
+	// BlockRootMethod is a view method taking a block number and returning its root,
+	// the ABI must have it unless SkipBlockRoots is set
+	BlockRootMethod string
+	// SkipBlockRoots runs the suits without reading the block roots stored by the contract,
+	// only the success or revert of every step and the CheckBlockRoots steps are checked
+	SkipBlockRoots bool
+	// GasLimit is the gas limit of every transaction
+	GasLimit uint64
+}
+
+// Runner executes test suits against a contract deployed on a go-ethereum SimulatedBackend
+type Runner struct {
+	config   Config
+	artifact *Artifact
+}
+
+func New(config Config) (*Runner, error) {
+	artifact, err := LoadArtifact(config.ArtifactDir, config.Contract)
+	if err != nil {
+		return nil, err
+	}
+	if config.GasLimit == 0 {
+		config.GasLimit = defaultGasLimit
+	}
+	if config.BlockRootMethod == "" {
+		config.BlockRootMethod = "blockRoots"
+	}
+	if method, ok := artifact.ABI.Methods[config.BlockRootMethod]; !config.SkipBlockRoots && (!ok || len(method.Inputs) != 1) {
+		return nil, fmt.Errorf("%s has no method %s(blockNumber) to check the block roots, set SkipBlockRoots to run without",
+			config.Contract, config.BlockRootMethod)
+	}
+	return &Runner{config: config, artifact: artifact}, nil
+}
+
+// FromEnv returns a runner of the contract set by the L2_ARTIFACT_DIR and L2_CONTRACT
+// environment variables, or nil if they are not set. Block roots are not checked if L2_SKIP_BLOCK_ROOTS is set.
+func FromEnv() (*Runner, error) {
+	dir, contract := os.Getenv(ArtifactDirEnv), os.Getenv(ContractEnv)
+	if dir == "" || contract == "" {
+		return nil, nil
+	}
+	return New(Config{ArtifactDir: dir, Contract: contract, SkipBlockRoots: os.Getenv(SkipBlockRootsEnv) != ""})
+}
+
+// Run deploys a fresh contract and executes the steps of suit in order. The constructor arguments
+// are taken from the fields of suit with the same name, e.g. genesisStateHash and accountMax.
+// Every step must succeed or revert as the step expects, and after every successful step
+// the contract must store the expected block roots unless SkipBlockRoots is set.
+func (r *Runner) Run(suit *test.Suit) error {
+	s, err := r.newSession()
+	if err != nil {
+		return err
+	}
+	defer s.backend.Close()
+
+	args, err := test.PackArgs(r.artifact.ABI.Constructor.Inputs, suit)
+	if err != nil {
+		return fmt.Errorf("constructor: %w", err)
+	}
+	receipt, err := s.send(nil, append(append([]byte{}, r.artifact.Bytecode...), args...))
+	if err != nil {
+		return err
+	}
+	if receipt.Status != ethTypes.ReceiptStatusSuccessful {
+		return fmt.Errorf("failed to deploy %s", r.config.Contract)
+	}
+	s.contract = receipt.ContractAddress
+
+	for i, step := range suit.Steps {
+		if err := s.runStep(&step); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i, step.Action.Method(), err)
+		}
+	}
+	return nil
+}
+
+type session struct {
+	runner   *Runner
+	backend  *backends.SimulatedBackend
+	auth     *bind.TransactOpts
+	contract common.Address
+	// blockRoots[i] is the expected root of block i+1
+	blockRoots []common.Hash
+}
+
+func (r *Runner) newSession() (*session, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	auth := bind.NewKeyedTransactor(key)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: senderBalance},
+	}, 2*r.config.GasLimit)
+	return &session{runner: r, backend: backend, auth: auth}, nil
+}
+
+func (s *session) runStep(step *test.Step) error {
+	if step.Action == test.CheckBlockRoots {
+		return s.checkExpectedBlockRoots(step)
+	}
+	data, err := step.Pack(&s.runner.artifact.ABI)
+	if err != nil {
+		return err
+	}
+	receipt, err := s.send(&s.contract, data)
+	if err != nil {
+		return err
+	}
+	success := receipt.Status == ethTypes.ReceiptStatusSuccessful
+	if success == step.Revert {
+		return fmt.Errorf("expect revert = %v, got receipt status %d", step.Revert, receipt.Status)
+	}
+	if !success {
+		return nil
+	}
+
+	switch data := reflect.Indirect(reflect.ValueOf(step.Data)).Interface().(type) {
+	case test.SubmitBlockStep:
+		s.blockRoots = append(s.blockRoots, blockchain.NewBlockHeader(s.lastBlockRoot(), data.BlockNumber, data.MiniBlocks, data.Timestamp).Root())
+	case test.AccuseBlockFraudProofStep:
+		s.revertFrom(data.BlockNumber)
+	case test.AccuseCommitmentFraudProofStep:
+		s.revertFrom(data.BlockNumber)
+	}
+	return s.checkBlockRoots()
+}
+
+func (s *session) lastBlockRoot() common.Hash {
+	if len(s.blockRoots) == 0 {
+		return common.Hash{}
+	}
+	return s.blockRoots[len(s.blockRoots)-1]
+}
+
+// revertFrom drops the expected roots of blockNumber and every later block
+func (s *session) revertFrom(blockNumber uint) {
+	if blockNumber >= 1 && int(blockNumber) <= len(s.blockRoots) {
+		s.blockRoots = s.blockRoots[:blockNumber-1]
+	}
+}
+
+// checkExpectedBlockRoots checks the roots of a CheckBlockRoots step against the roots tracked
+// by the session, then against the contract
+func (s *session) checkExpectedBlockRoots(step *test.Step) error {
+	data, ok := reflect.Indirect(reflect.ValueOf(step.Data)).Interface().(test.CheckBlockRootsStep)
+	if !ok {
+		return fmt.Errorf("unexpected data %T", step.Data)
+	}
+	if len(data.BlockRoots) != len(s.blockRoots) {
+		return fmt.Errorf("expect %d blocks, got %d", len(data.BlockRoots), len(s.blockRoots))
+	}
+	for i, root := range data.BlockRoots {
+		if root != s.blockRoots[i] {
+			return fmt.Errorf("block %d: expect root %s, got %s", i+1, root.Hex(), s.blockRoots[i].Hex())
+		}
+	}
+	return s.checkBlockRoots()
+}
+
+func (s *session) checkBlockRoots() error {
+	if s.runner.config.SkipBlockRoots {
+		return nil
+	}
+	method := s.runner.artifact.ABI.Methods[s.runner.config.BlockRootMethod]
+	for i, expected := range s.blockRoots {
+		blockNumber, err := numberArg(method.Inputs[0].Type, uint64(i+1))
+		if err != nil {
+			return err
+		}
+		input, err := method.Inputs.Pack(blockNumber)
+		if err != nil {
+			return err
+		}
+		output, err := s.backend.CallContract(context.Background(), ethereum.CallMsg{
+			From: s.auth.From,
+			To:   &s.contract,
+			Data: append(append([]byte{}, method.ID...), input...),
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to call %s: %w", method.Name, err)
+		}
+		if len(output) < common.HashLength {
+			return fmt.Errorf("unexpected output of %s: %x", method.Name, output)
+		}
+		if actual := common.BytesToHash(output[:common.HashLength]); actual != expected {
+			return fmt.Errorf("block %d: expect root %s, got %s", i+1, expected.Hex(), actual.Hex())
+		}
+	}
+	return nil
+}
+
+// send executes a transaction calling to, or creating a contract if to is nil
+func (s *session) send(to *common.Address, data []byte) (*ethTypes.Receipt, error) {
+	ctx := context.Background()
+	nonce, err := s.backend.PendingNonceAt(ctx, s.auth.From)
+	if err != nil {
+		return nil, err
+	}
+	var tx *ethTypes.Transaction
+	if to == nil {
+		tx = ethTypes.NewContractCreation(nonce, big.NewInt(0), s.runner.config.GasLimit, big.NewInt(1), data)
+	} else {
+		tx = ethTypes.NewTransaction(nonce, *to, big.NewInt(0), s.runner.config.GasLimit, big.NewInt(1), data)
+	}
+	signedTx, err := s.auth.Signer(ethTypes.HomesteadSigner{}, s.auth.From, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.SendTransaction(ctx, signedTx); err != nil {
+		return nil, err
+	}
+	s.backend.Commit()
+	return s.backend.TransactionReceipt(ctx, signedTx.Hash())
+}
+
+func numberArg(t abi.Type, x uint64) (interface{}, error) {
+	if t.T != abi.UintTy {
+		return nil, fmt.Errorf("unexpected block number type %s", t.String())
+	}
+	if t.GetType() == reflect.TypeOf(&big.Int{}) {
+		return new(big.Int).SetUint64(x), nil
+	}
+	return reflect.ValueOf(x).Convert(t.GetType()).Interface(), nil
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
+	"github.com/KyberNetwork/l2-contract-test-suite/testsample"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+func buildDepositSuit() *test.Suit {
+	genesis := &blockchain.Genesis{
+		AccountAlloc: map[uint32]blockchain.GenesisAccount{
+			0: {
+				Tokens:  map[uint16]*big.Int{0: big.NewInt(30000)},
+				Pubkey:  testsample.PublicKeys[0],
+				Address: testsample.Accounts[0],
+			},
+			3: {
+				Tokens:  map[uint16]*big.Int{1: big.NewInt(2000000)},
+				Pubkey:  testsample.PublicKeys[3],
+				Address: testsample.Accounts[3],
+			},
+		},
+		AccountMax: 3,
+	}
+	bc := blockchain.NewBlockchain(genesis, nil)
+	genesisHash := bc.GetStateData().Hash()
+	prevStateData := bc.GetStateData()
+
+	deposit := &types.DepositOp{AccountID: 3, TokenID: 1, Amount: big.NewInt(45242000)}
+	miniBlock := &types.MiniBlock{Txs: []types.Transaction{deposit}}
+	executionProofs, err := bc.AddMiniBlock(miniBlock)
+	if err != nil {
+		panic(err)
+	}
+	submitBlockStep := test.SubmitBlockStep{
+		BlockNumber: 1,
+		MiniBlocks:  []*types.MiniBlock{miniBlock},
+		Timestamp:   1600661872,
+	}
+	return &test.Suit{
+		Msg:              "deposit and accuse the block",
+		GenesisStateHash: genesisHash,
+		AccountMax:       genesis.AccountMax,
+		Steps: []test.Step{
+			{Action: test.SubmitDeposit, Data: deposit},
+			{Action: test.SubmitBlock, Data: submitBlockStep},
+			{Action: test.AccuseBlockFraudProof, Data: test.AccuseBlockFraudProofStep{
+				BlockNumber:     1,
+				MiniBlockNumber: 0,
+				MiniBlock:       miniBlock,
+				PrevStateData:   prevStateData,
+				MiniBlockProof:  proof.BuildMiniBlockProof(submitBlockStep.MiniBlocks, 0, submitBlockStep.Timestamp),
+				ExecutionProof:  executionProofs,
+			}},
+		},
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	// Accept stores no block roots
+	_, err := New(Config{ArtifactDir: "testdata", Contract: "Accept"})
+	require.Error(t, err)
+	r, err := New(Config{ArtifactDir: "testdata", Contract: "Accept", SkipBlockRoots: true})
+	require.NoError(t, err)
+	suit := buildDepositSuit()
+	require.NoError(t, r.Run(suit))
+
+	// the accusation reverts block 1
+	suit.Steps = append(suit.Steps, test.Step{Action: test.CheckBlockRoots, Data: test.CheckBlockRootsStep{}})
+	require.NoError(t, r.Run(suit))
+	suit.Steps[3].Data = test.CheckBlockRootsStep{BlockRoots: []common.Hash{{}}}
+	require.Error(t, r.Run(suit))
+
+	suit.Steps = suit.Steps[:3]
+	suit.Steps[2].Revert = true
+	require.Error(t, r.Run(suit))
+}
+
+func TestRunner_RunRevert(t *testing.T) {
+	r, err := New(Config{ArtifactDir: "testdata", Contract: "Reject", SkipBlockRoots: true})
+	require.NoError(t, err)
+	suit := buildDepositSuit()
+	require.Error(t, r.Run(suit))
+
+	for i := range suit.Steps {
+		suit.Steps[i].Revert = true
+	}
+	// the constructor of Reject does not revert, only its calls do
+	require.NoError(t, r.Run(suit))
+}
@@ -0,0 +1,31 @@
+package runner
+
+import (
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+// TB is the part of a *testing.T RunSuitsFromEnv reports to, so that the runner does not link the testing package
+type TB interface {
+	Helper()
+	Skip(args ...interface{})
+	Fatal(args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// RunSuitsFromEnv runs suits against the contract set by the environment, see FromEnv,
+// and skips the test if no contract is set
+func RunSuitsFromEnv(t TB, suits ...*test.Suit) {
+	t.Helper()
+	r, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Skip("set L2_ARTIFACT_DIR and L2_CONTRACT to run the suits against the contract")
+	}
+	for _, suit := range suits {
+		if err := r.Run(suit); err != nil {
+			t.Fatalf("%s: %v", suit.Msg, err)
+		}
+	}
+}
@@ -14,14 +14,20 @@ type MiniBlock struct {
 	Commitment common.Hash
 }
 
-func (blk *MiniBlock) MarshalJSON() ([]byte, error) {
-	var data hexutil.Bytes
+// Bytes returns the miniblock data submitted to the contract: commitment, state hash and pubData of every tx
+func (blk *MiniBlock) Bytes() []byte {
+	var data []byte
 
 	data = append(data, blk.Commitment.Bytes()...)
 	data = append(data, blk.StateHash.Bytes()...)
 	for _, tx := range blk.Txs {
 		data = append(data, tx.ToBytes()...)
 	}
+	return data
+}
+
+func (blk *MiniBlock) MarshalJSON() ([]byte, error) {
+	data := hexutil.Bytes(blk.Bytes())
 	return json.Marshal(&data)
 }
 
@@ -3,7 +3,6 @@ package blockchain
 import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
 	"math/big"
 )
 
@@ -14,11 +13,11 @@ type Account struct {
 	isConfirmedExit bool
 }
 
-func NewAccount(pubKey hexutil.Bytes, withdrawTo common.Address) *Account {
+func NewAccount(pubKey hexutil.Bytes, withdrawTo common.Address, params *Params) *Account {
 	return &Account{
 		pubKey:          pubKey,
 		withdrawTo:      withdrawTo,
-		tree:            NewTree(AccountTreeDeep),
+		tree:            NewTreeWithStore(params.AccountTreeDeep, &memoryNodeStore{}, params.hasher()),
 		isConfirmedExit: false,
 	}
 }
@@ -29,6 +28,26 @@ func (a *Account) Update(tokenID uint16, amount *big.Int) common.Hash {
 	return a.tree.RootHash()
 }
 
+// GetPubAccountHash returns the hash of the public key and the withdraw address, by the hasher of the balances
 func (a *Account) GetPubAccountHash() common.Hash {
-	return crypto.Keccak256Hash(a.pubKey, a.withdrawTo.Bytes())
+	return a.tree.hasher.Hash(a.pubKey, a.withdrawTo.Bytes())
+}
+
+// Clone returns a copy of the account with its balances in memory
+func (a *Account) Clone() *Account {
+	return &Account{
+		pubKey:          append(hexutil.Bytes{}, a.pubKey...),
+		withdrawTo:      a.withdrawTo,
+		tree:            a.tree.Clone(),
+		isConfirmedExit: a.isConfirmedExit,
+	}
+}
+
+// Balances returns every non zero balance of the account by token id
+func (a *Account) Balances() map[uint16]*big.Int {
+	balances := make(map[uint16]*big.Int)
+	for tokenID, amount := range a.tree.Leaves() {
+		balances[uint16(tokenID)] = amount.Big()
+	}
+	return balances
 }
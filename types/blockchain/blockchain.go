@@ -1,31 +1,36 @@
 package blockchain
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
 
 	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/settlement"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 )
 
-const AccountTreeDeep = 11
-const StateTreeDeep = 33
-const LOOTreeDeep = 45
-const FeeTokenIndex = 0
-const AdminIndex uint32 = 0
-const NumTxPerBLock = 8
-
 type Blockchain struct {
+	params      *Params
+	store       Store
 	state       *State
 	accountMax  uint32
 	looState    *LeftOverOrderList
 	looMax      uint64
 	numDeposit  uint64
 	numWithdraw uint
+	// history[i] undoes the miniblock pruned+i+1 executed since bc was created.
+	// In a LevelDB store each of them is also a record written with its miniblock, see versionKey.
+	history []*overlayStore
+	// pruned is the number of versions dropped by PruneHistory
+	pruned int
+	tracer Tracer
+	// trace is the trace of the tx being executed, if bc has a tracer
+	trace *TxTrace
 }
 
 type Genesis struct {
@@ -42,54 +47,189 @@ type GenesisAccount struct {
 	Address common.Address
 }
 
-func NewBlockchain(genesis *Genesis) *Blockchain {
-	if genesis == nil {
-		return &Blockchain{
-			state:      NewState(),
-			accountMax: 0,
-			looState:   NewLOOList(),
-			looMax:     0,
+// NewBlockchain returns the blockchain at genesis in memory, params defaults to DefaultParams()
+func NewBlockchain(genesis *Genesis, params *Params) *Blockchain {
+	bc, err := NewBlockchainWithStore(NewMemoryStore(), genesis, params)
+	if err != nil {
+		panic(err)
+	}
+	return bc
+}
+
+// NewBlockchainWithStore writes the blockchain at genesis to store at once, store must be empty.
+// params defaults to DefaultParams().
+func NewBlockchainWithStore(store Store, genesis *Genesis, params *Params) (*Blockchain, error) {
+	if _, ok := store.GetMeta(); ok {
+		return nil, fmt.Errorf("store already holds a blockchain")
+	}
+	if params == nil {
+		params = DefaultParams()
+	}
+	if _, err := hasher.ByName(params.Hasher); err != nil {
+		return nil, err
+	}
+	bc := &Blockchain{params: params}
+	overlay := newOverlayStore(store)
+	bc.setStore(overlay)
+	if genesis != nil {
+		accountHashes := make(map[uint64]common.Hash, len(genesis.AccountAlloc))
+		for accountID, accountAlloc := range genesis.AccountAlloc {
+			account := bc.state.newAccount(accountID, accountAlloc.Pubkey, accountAlloc.Address)
+			tokens := make(map[uint64]common.Hash, len(accountAlloc.Tokens))
+			for tokenID, tokenAmount := range accountAlloc.Tokens {
+				tokens[uint64(tokenID)] = common.BigToHash(tokenAmount)
+			}
+			account.tree.UpdateBatch(tokens)
+			accountHash := params.hasher().HashPair(account.tree.RootHash(), account.GetPubAccountHash())
+			accountHashes[uint64(accountID)] = accountHash
+		}
+		bc.state.tree.UpdateBatch(accountHashes)
+
+		looHashes := make(map[uint64]common.Hash, len(genesis.LooAlloc))
+		for looID, loo := range genesis.LooAlloc {
+			bc.looState.put(looID, loo)
+			looHashes[looID] = loo.HashWith(params.hasher())
 		}
+		bc.looState.tree.UpdateBatch(looHashes)
+		bc.accountMax = genesis.AccountMax
+		bc.looMax = genesis.LooMax
 	}
+	overlay.PutMeta(bc.meta())
+	overlay.commit()
+	bc.setStore(store)
+	return bc, nil
+}
 
-	looState := NewLOOList()
-	state := NewState()
-	for accountID, accountAlloc := range genesis.AccountAlloc {
-		account := NewAccount(accountAlloc.Pubkey, accountAlloc.Address)
-		for tokenID, tokenAmount := range accountAlloc.Tokens {
-			account.Update(tokenID, tokenAmount)
+// OpenBlockchain returns the blockchain of store, as it was after its last miniblock, with its versions
+func OpenBlockchain(store Store) (*Blockchain, error) {
+	meta, ok := store.GetMeta()
+	if !ok {
+		return nil, fmt.Errorf("store holds no blockchain")
+	}
+	if meta.Params == nil {
+		return nil, fmt.Errorf("store holds no params")
+	}
+	bc := &Blockchain{
+		params:      meta.Params,
+		accountMax:  meta.AccountMax,
+		looMax:      meta.LOOMax,
+		numDeposit:  meta.NumDeposit,
+		numWithdraw: meta.NumWithdraw,
+		pruned:      meta.Pruned,
+	}
+	for version := meta.Pruned + 1; ; version++ {
+		data, ok := store.GetRecord(versionKey(version))
+		if !ok {
+			break
 		}
-		state.accounts[accountID] = account
-		accountHash := crypto.Keccak256Hash(
-			account.tree.RootHash().Bytes(),
-			account.GetPubAccountHash().Bytes(),
-		)
-		state.tree.Update(uint64(accountID), accountHash)
+		undo := newOverlayStore(nil)
+		if err := json.Unmarshal(data, undo); err != nil {
+			return nil, fmt.Errorf("version %d: %w", version, err)
+		}
+		bc.history = append(bc.history, undo)
 	}
+	bc.setStore(store)
+	return bc, nil
+}
 
-	for looID, loo := range genesis.LooAlloc {
-		looState.loos[looID] = loo.Clone()
-		looState.tree.Update(looID, loo.Hash())
+func (bc *Blockchain) setStore(store Store) {
+	bc.store = store
+	bc.state = newState(store, bc.params)
+	bc.looState = newLOOList(store, bc.params)
+}
+
+// apply writes overlay, whose parent is bc.store, to bc: at once to a LevelDB store, which its clones read
+// through a snapshot. In memory the stores of bc are never written once shared, overlay becomes their top layer.
+func (bc *Blockchain) apply(overlay *overlayStore) {
+	if _, ok := overlay.parent.(*levelDBStore); ok {
+		overlay.commit()
+		bc.setStore(overlay.parent)
+		return
 	}
+	bc.setStore(push(overlay))
+}
+
+func (bc *Blockchain) meta() *StoreMeta {
+	params := *bc.params
+	return &StoreMeta{
+		Params:      &params,
+		AccountMax:  bc.accountMax,
+		LOOMax:      bc.looMax,
+		NumDeposit:  bc.numDeposit,
+		NumWithdraw: bc.numWithdraw,
+		Pruned:      bc.pruned,
+	}
+}
+
+// persistent tells if bc is written to LevelDB, its versions and the blocks of its rollup are then persisted too
+func (bc *Blockchain) persistent() bool {
+	_, ok := bc.store.(*levelDBStore)
+	return ok
+}
+
+// NumDeposit returns the number of deposits executed, the DepositID of the next deposit
+func (bc *Blockchain) NumDeposit() uint64 {
+	return bc.numDeposit
+}
 
-	return &Blockchain{
-		state:      state,
-		accountMax: genesis.AccountMax,
-		looState:   looState,
-		looMax:     genesis.LooMax,
+// Clone returns a copy of the blockchain, including the deposit and withdraw counters and its versions.
+// It is O(1) and does not change bc: the copy reads the current state of bc, the layers of a blockchain in memory
+// or a snapshot of a LevelDB store, and keeps its own changes in memory.
+// bc and the copy can then be advanced independently from different goroutines.
+func (bc *Blockchain) Clone() *Blockchain {
+	out := *bc
+	// the copy appends its versions to a new array, bc may append to the shared one
+	out.history = bc.history[:len(bc.history):len(bc.history)]
+	if store, ok := bc.store.(*levelDBStore); ok {
+		out.setStore(store.snapshot())
+	} else {
+		out.setStore(bc.store)
 	}
+	return &out
 }
 
 //func (bc *Blockchain) AddBlock(block *types.Blo)
 
-func (bc *Blockchain) AddMiniBlock(block *types.MiniBlock) []hexutil.Bytes {
+// AddMiniBlock executes block and sets its state hash and commitment.
+// It returns the execution proof of every tx followed by the proof of the total fee.
+// If a tx reverts, e.g. with an *util.ArithmeticError, the blockchain is left unchanged.
+func (bc *Blockchain) AddMiniBlock(block *types.MiniBlock) ([]hexutil.Bytes, error) {
+	if len(block.Txs) > bc.params.NumTxPerBlock {
+		return nil, fmt.Errorf("number of txs %d exceeds %d", len(block.Txs), bc.params.NumTxPerBlock)
+	}
+	for i, tx := range block.Txs {
+		if err := tx.Validate(); err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+	}
+	// the changes are buffered until the miniblock succeeds
+	persistent := bc.persistent()
+	snapshot := *bc
+	overlay := newOverlayStore(bc.store)
+	bc.setStore(overlay)
+	proofs, err := bc.addMiniBlock(block)
+	if err != nil {
+		*bc = snapshot
+		return nil, err
+	}
+	overlay.PutMeta(bc.meta())
+	undo := overlay.undo()
+	if persistent {
+		overlay.PutRecord(versionKey(bc.Version()+1), mustMarshal(undo))
+	}
+	bc.apply(overlay)
+	bc.history = append(bc.history, undo)
+	return proofs, nil
+}
+
+func (bc *Blockchain) addMiniBlock(block *types.MiniBlock) ([]hexutil.Bytes, error) {
 	var (
 		proofs          []hexutil.Bytes
 		totalFee        = big.NewInt(0)
 		commitmentInput []byte
 	)
 
-	for _, tx := range block.Txs {
+	for i, tx := range block.Txs {
 		switch obj := tx.(type) {
 		case *types.Settlement1:
 			commitmentInput = append(commitmentInput, bc.buildSettlement1ZkMsg(obj)...)
@@ -103,99 +243,104 @@ func (bc *Blockchain) AddMiniBlock(block *types.MiniBlock) []hexutil.Bytes {
 			}
 		}
 
+		var (
+			proof hexutil.Bytes
+			fee   = big.NewInt(0)
+			err   error
+		)
+		bc.startTrace(i, opName(tx))
 		switch obj := tx.(type) {
 		case *types.Settlement1:
-			proof, fee := bc.handleSettlement1(obj)
-			proofs = append(proofs, proof)
-			totalFee = totalFee.Add(totalFee, fee)
+			proof, fee, err = bc.handleSettlement1(obj)
 		case *types.Settlement2:
-			proof, fee := bc.handleSettlement2(obj)
-			proofs = append(proofs, proof)
-			totalFee = totalFee.Add(totalFee, fee)
+			proof, fee, err = bc.handleSettlement2(obj)
 		case *types.Settlement3:
-			proof, fee := bc.handleSettlement3(obj)
-			proofs = append(proofs, proof)
-			totalFee = totalFee.Add(totalFee, fee)
+			proof, fee, err = bc.handleSettlement3(obj)
 		case *types.DepositOp:
-			proof := bc.handleDeposit(obj)
-			proofs = append(proofs, proof)
+			proof, err = bc.handleDeposit(obj)
 		case *types.DepositToNewOp:
-			proof := bc.handleDepositToNew(obj)
-			proofs = append(proofs, proof)
+			proof = bc.handleDepositToNew(obj)
 		case *types.WithdrawOp:
-			proof, fee := bc.handleWithdraw(obj)
-			proofs = append(proofs, proof)
-			totalFee = totalFee.Add(totalFee, fee)
+			proof, fee, err = bc.handleWithdraw(obj)
 		case *types.ExitOp:
-			proof := bc.handleExit(obj)
-			proofs = append(proofs, proof)
+			proof = bc.handleExit(obj)
 		default:
 			panic("unsupported type")
 		}
+		if err == nil {
+			totalFee, err = util.CheckedAdd(totalFee, fee)
+		}
+		bc.endTrace(fee, err)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+		proofs = append(proofs, proof)
+	}
+	bc.startTrace(len(block.Txs), "totalFee")
+	proof, err := bc.handleTotalFee(totalFee)
+	bc.endTrace(totalFee, err)
+	if err != nil {
+		return nil, fmt.Errorf("total fee: %w", err)
 	}
-	proofs = append(proofs, bc.handleTotalFee(totalFee))
+	proofs = append(proofs, proof)
 	block.StateHash = bc.GetStateData().Hash()
 
-	if len(block.Txs) <= NumTxPerBLock {
-		for len(commitmentInput) < NumTxPerBLock*128 {
-			commitmentInput = append(commitmentInput, 0)
-		}
-	} else {
-		fmt.Println("warning: number of txs >4")
+	for len(commitmentInput) < bc.params.NumTxPerBlock*128 {
+		commitmentInput = append(commitmentInput, 0)
 	}
 	block.Commitment = util.Sha256ToHash(commitmentInput)
-	return proofs
+	return proofs, nil
 }
 
-func (bc *Blockchain) handleDeposit(op *types.DepositOp) (proof hexutil.Bytes) {
-	account := bc.state.accounts[op.AccountID]
+func (bc *Blockchain) handleDeposit(op *types.DepositOp) (proof hexutil.Bytes, err error) {
+	account := bc.state.getAccount(op.AccountID)
 	if account == nil {
 		panic("empty account")
 	}
 
 	_, accountSiblings := bc.state.tree.GetProof(uint64(op.AccountID))
-	proof = appendSiblings(proof, accountSiblings)
+	proof = bc.appendSiblingsField(proof, "accountSiblings", accountSiblings)
 	pubAccountHash := account.GetPubAccountHash()
-	proof = append(proof, pubAccountHash.Bytes()...)
+	proof = bc.appendField(proof, "pubAccountHash", pubAccountHash.Bytes())
 	// update account tree
 	tokenAmount, tokenSiblings := account.tree.GetProof(uint64(op.TokenID))
-	proof = appendTokenProof(proof, tokenAmount, tokenSiblings)
-	account.tree.Update(uint64(op.TokenID), util.AddAmount(tokenAmount, op.Amount))
+	proof = bc.appendTokenField(proof, "token", tokenAmount, tokenSiblings)
+	if err := bc.addBalance(op.AccountID, account, op.TokenID, tokenAmount, op.Amount); err != nil {
+		return nil, err
+	}
 	// update bc tree
-	accountHash := crypto.Keccak256Hash(account.tree.RootHash().Bytes(), pubAccountHash.Bytes())
+	accountHash := bc.params.hasher().HashPair(account.tree.RootHash(), pubAccountHash)
 	bc.state.tree.Update(uint64(op.AccountID), accountHash)
 
-	proof = append(proof, util.Uint32ToBytes(op.AccountID)...)
-	proof = append(proof, util.Uint16ToBytes(op.TokenID)...)
-	proof = append(proof, common.BigToHash(op.Amount).Bytes()...)
+	proof = bc.appendField(proof, "accountID", util.Uint32ToBytes(op.AccountID))
+	proof = bc.appendField(proof, "tokenID", util.Uint16ToBytes(op.TokenID))
+	proof = bc.appendField(proof, "amount", common.BigToHash(op.Amount).Bytes())
 
 	op.DepositID = bc.numDeposit
 	bc.numDeposit++
-	return proof
+	return proof, nil
 }
 
 func (bc *Blockchain) handleDepositToNew(op *types.DepositToNewOp) (proof hexutil.Bytes) {
 	bc.accountMax++
 	accountID := bc.accountMax
-	account := bc.state.accounts[accountID]
-	if account != nil {
+	if bc.state.getAccount(accountID) != nil {
 		panic("account existed")
 	}
 	_, siblings := bc.state.tree.GetProof(uint64(accountID))
 
-	account = NewAccount(op.PubKey, op.WithdrawTo)
+	account := bc.state.newAccount(accountID, op.PubKey, op.WithdrawTo)
 	account.tree.Update(uint64(op.TokenID), common.BigToHash(op.Amount))
-	account.GetPubAccountHash()
-	bc.state.accounts[accountID] = account
+	bc.traceBalance(accountID, op.TokenID, new(big.Int), op.Amount)
 
-	accountHash := crypto.Keccak256Hash(account.tree.RootHash().Bytes(), account.GetPubAccountHash().Bytes())
+	accountHash := bc.params.hasher().HashPair(account.tree.RootHash(), account.GetPubAccountHash())
 	bc.state.tree.Update(uint64(accountID), accountHash)
 
-	proof = append(proof, op.PubKey...)
-	proof = append(proof, op.WithdrawTo.Bytes()...)
-	proof = append(proof, util.Uint16ToByte(op.TokenID)...)
-	proof = append(proof, common.BigToHash(op.Amount).Bytes()...)
-	proof = appendSiblings(proof, siblings)
+	proof = bc.appendField(proof, "pubKey", op.PubKey)
+	proof = bc.appendField(proof, "withdrawTo", op.WithdrawTo.Bytes())
+	proof = bc.appendField(proof, "tokenID", util.Uint16ToByte(op.TokenID))
+	proof = bc.appendField(proof, "amount", common.BigToHash(op.Amount).Bytes())
+	proof = bc.appendSiblingsField(proof, "accountSiblings", siblings)
 
 	op.DepositID = bc.numDeposit
 	bc.numDeposit++
@@ -205,231 +350,262 @@ func (bc *Blockchain) handleDepositToNew(op *types.DepositToNewOp) (proof hexuti
 func (bc *Blockchain) updateSettlementBalance(
 	accountID1, accountID2 uint32, tokenID1, tokenID2 uint16,
 	amount1, amount2, fee1, fee2 *big.Int,
-) (proof hexutil.Bytes) {
-	account := bc.state.accounts[accountID1]
+) (proof hexutil.Bytes, err error) {
+	account := bc.state.getAccount(accountID1)
 	if account == nil {
 		panic("empty account")
 	}
 
 	_, accountSiblings := bc.state.tree.GetProof(uint64(accountID1))
-	proof = appendSiblings(proof, accountSiblings)
+	proof = bc.appendSiblingsField(proof, "account1Siblings", accountSiblings)
 	pubAccountHash := account.GetPubAccountHash()
-	proof = append(proof, pubAccountHash.Bytes()...)
+	proof = bc.appendField(proof, "account1PubAccountHash", pubAccountHash.Bytes())
 	// update balance of token
 	token1Amount, token1Siblings := account.tree.GetProof(uint64(tokenID1))
-	account.tree.Update(uint64(tokenID1), util.SubAmount(token1Amount, amount1))
-	proof = appendTokenProof(proof, token1Amount, token1Siblings)
+	if err := bc.subBalance(accountID1, account, tokenID1, token1Amount, amount1); err != nil {
+		return nil, err
+	}
+	proof = bc.appendTokenField(proof, "account1Token1", token1Amount, token1Siblings)
 
 	token2Amount, token2Siblings := account.tree.GetProof(uint64(tokenID2))
-	account.tree.Update(uint64(tokenID2), util.AddAmount(token2Amount, amount2))
-	proof = appendTokenProof(proof, token2Amount, token2Siblings)
+	if err := bc.addBalance(accountID1, account, tokenID2, token2Amount, amount2); err != nil {
+		return nil, err
+	}
+	proof = bc.appendTokenField(proof, "account1Token2", token2Amount, token2Siblings)
 
-	token0Amount, token0Siblings := account.tree.GetProof(FeeTokenIndex)
-	account.tree.Update(FeeTokenIndex, util.SubAmount(token0Amount, fee1))
-	proof = appendTokenProof(proof, token0Amount, token0Siblings)
+	token0Amount, token0Siblings := account.tree.GetProof(uint64(bc.params.FeeTokenIndex))
+	if err := bc.subBalance(accountID1, account, bc.params.FeeTokenIndex, token0Amount, fee1); err != nil {
+		return nil, err
+	}
+	proof = bc.appendTokenField(proof, "account1FeeToken", token0Amount, token0Siblings)
 
 	// update root to merkle tree
-	accountHash := crypto.Keccak256Hash(account.tree.RootHash().Bytes(), pubAccountHash.Bytes())
+	accountHash := bc.params.hasher().HashPair(account.tree.RootHash(), pubAccountHash)
 	bc.state.tree.Update(uint64(accountID1), accountHash)
 
-	account = bc.state.accounts[accountID2]
+	account = bc.state.getAccount(accountID2)
 	if account == nil {
 		panic("empty account")
 	}
 	_, accountSiblings = bc.state.tree.GetProof(uint64(accountID2))
-	proof = appendSiblings(proof, accountSiblings)
+	proof = bc.appendSiblingsField(proof, "account2Siblings", accountSiblings)
 	pubAccountHash = account.GetPubAccountHash()
-	proof = append(proof, pubAccountHash.Bytes()...)
+	proof = bc.appendField(proof, "account2PubAccountHash", pubAccountHash.Bytes())
 	// update balance of token
 	token2Amount, token2Siblings = account.tree.GetProof(uint64(tokenID2))
-	account.tree.Update(uint64(tokenID2), util.SubAmount(token2Amount, amount2))
-	proof = appendTokenProof(proof, token2Amount, token2Siblings)
+	if err := bc.subBalance(accountID2, account, tokenID2, token2Amount, amount2); err != nil {
+		return nil, err
+	}
+	proof = bc.appendTokenField(proof, "account2Token2", token2Amount, token2Siblings)
 
 	token1Amount, token1Siblings = account.tree.GetProof(uint64(tokenID1))
-	account.tree.Update(uint64(tokenID1), util.AddAmount(token1Amount, amount1))
-	proof = appendTokenProof(proof, token1Amount, token1Siblings)
+	if err := bc.addBalance(accountID2, account, tokenID1, token1Amount, amount1); err != nil {
+		return nil, err
+	}
+	proof = bc.appendTokenField(proof, "account2Token1", token1Amount, token1Siblings)
 
-	token0Amount, token0Siblings = account.tree.GetProof(FeeTokenIndex)
-	account.tree.Update(FeeTokenIndex, util.SubAmount(token0Amount, fee2))
-	proof = appendTokenProof(proof, token0Amount, token0Siblings)
+	token0Amount, token0Siblings = account.tree.GetProof(uint64(bc.params.FeeTokenIndex))
+	if err := bc.subBalance(accountID2, account, bc.params.FeeTokenIndex, token0Amount, fee2); err != nil {
+		return nil, err
+	}
+	proof = bc.appendTokenField(proof, "account2FeeToken", token0Amount, token0Siblings)
 	// update root to merkle tree
-	accountHash = crypto.Keccak256Hash(account.tree.RootHash().Bytes(), pubAccountHash.Bytes())
+	accountHash = bc.params.hasher().HashPair(account.tree.RootHash(), pubAccountHash)
 	bc.state.tree.Update(uint64(accountID2), accountHash)
 
-	return proof
+	return proof, nil
 }
 
-func (bc *Blockchain) handleSettlement1(op *types.Settlement1) (proof hexutil.Bytes, fee *big.Int) {
-	account := bc.state.accounts[op.Account1]
+func (bc *Blockchain) handleSettlement1(op *types.Settlement1) (proof hexutil.Bytes, fee *big.Int, err error) {
+	account := bc.state.getAccount(op.Account1)
 	if account == nil {
 		panic("empty account")
 	}
 
-	amount1, amount2, fee1, fee2, loo := op.GetSettlementValue()
+	orderHash1, orderHash2 := Settlement1OrderHashes(op)
+	if err := bc.useOrder(op.Account1, orderHash1); err != nil {
+		return nil, nil, err
+	}
+	if err := bc.useOrder(op.Account2, orderHash2); err != nil {
+		return nil, nil, err
+	}
 
-	proof = append(proof, bc.updateSettlementBalance(op.Account1, op.Account2, op.Token1, op.Token2,
-		amount1, amount2, fee1, fee2)...)
+	amount1, amount2, fee1, fee2, loo, err := op.GetSettlementValue()
+	if err != nil {
+		return nil, nil, err
+	}
+	bc.traceSettlementValues(amount1, amount2, fee1, fee2)
+	fee, err = util.CheckedAdd(fee1, fee2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	balanceProof, err := bc.updateSettlementBalance(op.Account1, op.Account2, op.Token1, op.Token2,
+		amount1, amount2, fee1, fee2)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof = append(proof, balanceProof...)
 
 	if loo != nil {
 		bc.looMax += 1
 		_, looSiblings := bc.looState.tree.GetProof(bc.looMax)
-		proof = appendSiblings(proof, looSiblings)
-		bc.looState.tree.Update(bc.looMax, loo.Hash())
-		bc.looState.loos[bc.looMax] = loo
+		proof = bc.appendSiblingsField(proof, "newLOOSiblings", looSiblings)
+		bc.putLOO(bc.looMax, loo)
 	}
-	fee = new(big.Int).Add(fee1, fee2)
-	return
+	return proof, fee, nil
 }
 
-func (bc *Blockchain) handleSettlement2(op *types.Settlement2) (proof hexutil.Bytes, fee *big.Int) {
-	loo, ok := bc.looState.loos[op.LooID1]
-	if !ok {
+func (bc *Blockchain) handleSettlement2(op *types.Settlement2) (proof hexutil.Bytes, fee *big.Int, err error) {
+	loo := bc.looState.get(op.LooID1)
+	if loo == nil {
 		panic("loo not exist")
 	}
 	_, looSiblings := bc.looState.tree.GetProof(op.LooID1)
-	proof = append(proof, loo.Bytes()...)
-	proof = appendSiblings(proof, looSiblings)
+	proof = bc.appendField(proof, "loo1", loo.Bytes())
+	proof = bc.appendSiblingsField(proof, "loo1Siblings", looSiblings)
 
-	amount1, amount2, fee1, fee2, loo2 := op.GetSettlementValue(loo)
+	if err := bc.useOrder(op.AccountID2, Settlement2OrderHash(op, loo)); err != nil {
+		return nil, nil, err
+	}
+
+	amount1, amount2, fee1, fee2, loo2, err := op.GetSettlementValue(loo)
+	if err != nil {
+		return nil, nil, err
+	}
+	bc.traceSettlementValues(amount1, amount2, fee1, fee2)
+	fee, err = util.CheckedAdd(fee1, fee2)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	proof = append(proof,
-		bc.updateSettlementBalance(
-			loo.AccountID, op.AccountID2, loo.SrcToken, loo.DestToken,
-			amount1, amount2, fee1, fee2,
-		)...)
+	balanceProof, err := bc.updateSettlementBalance(
+		loo.AccountID, op.AccountID2, loo.SrcToken, loo.DestToken,
+		amount1, amount2, fee1, fee2,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof = append(proof, balanceProof...)
 
-	bc.looState.tree.Update(op.LooID1, loo.Hash())
+	bc.putLOO(op.LooID1, loo)
 	if loo2 != nil {
 		bc.looMax += 1
 		_, looSiblings := bc.looState.tree.GetProof(bc.looMax)
-		proof = appendSiblings(proof, looSiblings)
-		bc.looState.tree.Update(bc.looMax, loo2.Hash())
-		bc.looState.loos[bc.looMax] = loo2
+		proof = bc.appendSiblingsField(proof, "newLOOSiblings", looSiblings)
+		bc.putLOO(bc.looMax, loo2)
 	}
-
-	totalFee := new(big.Int).Add(fee1, fee2)
-	return proof, totalFee
+	return proof, fee, nil
 }
 
-func (bc *Blockchain) handleSettlement3(op *types.Settlement3) (proof hexutil.Bytes, fee *big.Int) {
-	loo1, ok := bc.looState.loos[op.LooID1]
-	if !ok {
+func (bc *Blockchain) handleSettlement3(op *types.Settlement3) (proof hexutil.Bytes, fee *big.Int, err error) {
+	loo1 := bc.looState.get(op.LooID1)
+	if loo1 == nil {
 		panic("loo not exist")
 	}
-	proof = append(proof, loo1.Bytes()...)
+	proof = bc.appendField(proof, "loo1", loo1.Bytes())
 
-	loo2, ok := bc.looState.loos[op.LooID2]
-	if !ok {
+	loo2 := bc.looState.get(op.LooID2)
+	if loo2 == nil {
 		panic("loo not exist")
 	}
-	proof = append(proof, loo2.Bytes()...)
+	proof = bc.appendField(proof, "loo2", loo2.Bytes())
 
-	var (
-		orderAmount1                 = new(big.Int).Set(loo1.Amount)
-		orderRate1                   = new(big.Int).Set(loo1.Rate)
-		orderAmount2                 = new(big.Int).Set(loo2.Amount)
-		orderRate2                   = new(big.Int).Set(loo2.Rate)
-		amount1, amount2, fee1, fee2 *big.Int
+	fill1, fill2, err := settlement.Match(
+		&settlement.Order{Amount: loo1.Amount, Rate: loo1.Rate, Fee: loo1.Fee, ValidSince: loo1.ValidSince},
+		&settlement.Order{Amount: loo2.Amount, Rate: loo2.Rate, Fee: loo2.Fee, ValidSince: loo2.ValidSince},
 	)
-
-	if loo1.ValidSince <= loo2.ValidSince {
-		amount2 = util.CalAmountOut(orderAmount1, orderRate1)
-		if amount2.Cmp(orderAmount2) == 1 {
-			amount2.Set(orderAmount2)
-			amount1 = util.CalAmountIn(orderAmount2, orderRate1)
-		} else {
-			amount1 = new(big.Int).Set(orderAmount1)
-		}
-	} else {
-		amount1 = util.CalAmountOut(orderAmount2, orderRate2)
-		if amount1.Cmp(orderAmount1) == 1 {
-			amount1.Set(orderAmount1)
-			amount2 = util.CalAmountIn(orderAmount1, orderRate2)
-		} else {
-			amount2 = new(big.Int).Set(orderAmount2)
-		}
+	if err != nil {
+		return nil, nil, err
 	}
-	if amount1.Cmp(orderAmount1) < 0 { //left-over loo1 at order 1
-		fee1 = new(big.Int).Div(new(big.Int).Mul(loo1.Fee, amount1), orderAmount1)
-	} else {
-		fee1 = new(big.Int).Set(loo1.Fee)
+	amount1, amount2, fee1, fee2 := fill1.Amount, fill2.Amount, fill1.Fee, fill2.Fee
+	bc.traceSettlementValues(amount1, amount2, fee1, fee2)
+	fee, err = util.CheckedAdd(fee1, fee2)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	_, looSiblings := bc.looState.tree.GetProof(op.LooID1)
-	proof = appendSiblings(proof, looSiblings)
-	loo1.Amount = orderAmount1.Sub(orderAmount1, amount1)
-	loo1.Fee = loo1.Fee.Sub(loo1.Fee, fee1)
-	bc.looState.tree.Update(op.LooID1, loo1.Hash())
+	proof = bc.appendSiblingsField(proof, "loo1Siblings", looSiblings)
+	loo1.Amount = fill1.RemainingAmount
+	loo1.Fee = fill1.RemainingFee
+	bc.putLOO(op.LooID1, loo1)
 
-	if amount2.Cmp(orderAmount2) < 0 { //left-over loo1 at order 2
-		fee2 = new(big.Int).Div(new(big.Int).Mul(loo2.Fee, amount2), orderAmount2)
-	} else {
-		fee2 = new(big.Int).Set(loo2.Fee)
-	}
 	_, looSiblings = bc.looState.tree.GetProof(op.LooID2)
-	proof = appendSiblings(proof, looSiblings)
-	loo2.Amount = orderAmount2.Sub(orderAmount2, amount2)
-	loo2.Fee = loo2.Fee.Sub(loo2.Fee, fee2)
-	bc.looState.tree.Update(op.LooID2, loo2.Hash())
-
-	proof = append(proof,
-		bc.updateSettlementBalance(
-			loo1.AccountID, loo2.AccountID, loo1.SrcToken, loo2.SrcToken,
-			amount1, amount2, fee1, fee2,
-		)...)
-
-	totalFee := new(big.Int).Add(fee1, fee2)
-	return proof, totalFee
+	proof = bc.appendSiblingsField(proof, "loo2Siblings", looSiblings)
+	loo2.Amount = fill2.RemainingAmount
+	loo2.Fee = fill2.RemainingFee
+	bc.putLOO(op.LooID2, loo2)
+
+	balanceProof, err := bc.updateSettlementBalance(
+		loo1.AccountID, loo2.AccountID, loo1.SrcToken, loo2.SrcToken,
+		amount1, amount2, fee1, fee2,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof = append(proof, balanceProof...)
+	return proof, fee, nil
 }
 
-func (bc *Blockchain) handleWithdraw(op *types.WithdrawOp) (proof hexutil.Bytes, fee *big.Int) {
+func (bc *Blockchain) handleWithdraw(op *types.WithdrawOp) (proof hexutil.Bytes, fee *big.Int, err error) {
 	fee = op.Fee.Big()
-	amount := op.Amount.Big()
-	account := bc.state.accounts[op.AccountID]
+	amount, err := op.Amount.Uint256()
+	if err != nil {
+		return nil, nil, err
+	}
+	bc.traceValue("amount", amount)
+	bc.traceValue("fee", fee)
+	account := bc.state.getAccount(op.AccountID)
 	if account == nil {
 		panic("empty account")
 	}
 
 	_, accountSiblings := bc.state.tree.GetProof(uint64(op.AccountID))
-	proof = appendSiblings(proof, accountSiblings)
+	proof = bc.appendSiblingsField(proof, "accountSiblings", accountSiblings)
 	pubAccountHash := account.GetPubAccountHash()
-	proof = append(proof, account.pubKey...)
-	proof = append(proof, account.withdrawTo.Bytes()...)
+	proof = bc.appendField(proof, "pubKey", account.pubKey)
+	proof = bc.appendField(proof, "withdrawTo", account.withdrawTo.Bytes())
 	// update account tree
 	tokenAmount, tokenSiblings := account.tree.GetProof(uint64(op.TokenID))
-	proof = appendTokenProof(proof, tokenAmount, tokenSiblings)
-	account.tree.Update(uint64(op.TokenID), util.SubAmount(tokenAmount, amount))
+	proof = bc.appendTokenField(proof, "token", tokenAmount, tokenSiblings)
+	if err := bc.subBalance(op.AccountID, account, op.TokenID, tokenAmount, amount); err != nil {
+		return nil, nil, err
+	}
 	// update token fee
-	tokenAmount, tokenSiblings = account.tree.GetProof(uint64(FeeTokenIndex))
-	proof = appendTokenProof(proof, tokenAmount, tokenSiblings)
-	account.tree.Update(uint64(FeeTokenIndex), util.SubAmount(tokenAmount, fee))
+	tokenAmount, tokenSiblings = account.tree.GetProof(uint64(bc.params.FeeTokenIndex))
+	proof = bc.appendTokenField(proof, "feeToken", tokenAmount, tokenSiblings)
+	if err := bc.subBalance(op.AccountID, account, bc.params.FeeTokenIndex, tokenAmount, fee); err != nil {
+		return nil, nil, err
+	}
 	// update bc tree
-	accountHash := crypto.Keccak256Hash(account.tree.RootHash().Bytes(), pubAccountHash.Bytes())
+	accountHash := bc.params.hasher().HashPair(account.tree.RootHash(), pubAccountHash)
 	bc.state.tree.Update(uint64(op.AccountID), accountHash)
 
 	op.WithdrawID = bc.numWithdraw
 	bc.numWithdraw++
-	return proof, fee
+	return proof, fee, nil
 }
 
 func (bc *Blockchain) handleExit(op *types.ExitOp) (proof hexutil.Bytes) {
-	account := bc.state.accounts[op.AccountID]
+	account := bc.state.getAccount(op.AccountID)
 	if account == nil {
 		panic("empty account")
 	}
 
 	balanceRoot := account.tree.RootHash()
-	proof = append(proof, balanceRoot.Bytes()...)
+	proof = bc.appendField(proof, "balanceRoot", balanceRoot.Bytes())
 
 	pubAccountHash := account.GetPubAccountHash()
-	proof = append(proof, pubAccountHash.Bytes()...)
+	proof = bc.appendField(proof, "pubAccountHash", pubAccountHash.Bytes())
 
 	_, accountSiblings := bc.state.tree.GetProof(uint64(op.AccountID))
-	proof = appendSiblings(proof, accountSiblings)
+	proof = bc.appendSiblingsField(proof, "accountSiblings", accountSiblings)
 	// set balance root of this account to bytes32(0)
-	accountHash := crypto.Keccak256Hash(common.HexToHash(zeroHash).Bytes(), pubAccountHash.Bytes())
+	accountHash := bc.params.hasher().HashPair(common.HexToHash(zeroHash), pubAccountHash)
 	bc.state.tree.Update(uint64(op.AccountID), accountHash)
 	account.isConfirmedExit = true
+	bc.state.putAccount(op.AccountID, account)
 	// set balanceRoot to operation
 	op.AccountRoot = balanceRoot
 	return proof
@@ -437,7 +613,7 @@ func (bc *Blockchain) handleExit(op *types.ExitOp) (proof hexutil.Bytes) {
 
 // BuildSubmitExitProof builds a proof for user to submit exit
 func (bc *Blockchain) BuildSubmitExitProof(accountID uint32) (balanceRoot common.Hash, proof hexutil.Bytes) {
-	account := bc.state.accounts[accountID]
+	account := bc.state.getAccount(accountID)
 	if account == nil {
 		panic("empty account")
 	}
@@ -459,33 +635,70 @@ func (bc *Blockchain) BuildCompleteExit(accountID uint32, tokenIDs []uint16) (am
 	for i := 0; i < len(tokenIDs); i++ {
 		keys = append(keys, uint64(tokenIDs[i]))
 	}
-	values, siblings := bc.state.accounts[accountID].tree.GetProofBatch(keys)
+	account := bc.state.getAccount(accountID)
+	if account == nil {
+		panic("empty account")
+	}
+	values, siblings := account.tree.GetProofBatch(keys)
 	for i := 0; i < len(values); i++ {
 		amounts = append(amounts, values[i].Big())
 	}
 	return amounts, siblings
 }
 
-func (bc *Blockchain) handleTotalFee(fee *big.Int) (proof hexutil.Bytes) {
-	account, ok := bc.state.accounts[AdminIndex]
-	if !ok {
+func (bc *Blockchain) handleTotalFee(fee *big.Int) (proof hexutil.Bytes, err error) {
+	account := bc.state.getAccount(bc.params.AdminIndex)
+	if account == nil {
 		panic("no admin account")
 	}
 
-	_, accountSiblings := bc.state.tree.GetProof(uint64(AdminIndex))
-	proof = appendSiblings(proof, accountSiblings)
+	_, accountSiblings := bc.state.tree.GetProof(uint64(bc.params.AdminIndex))
+	proof = bc.appendSiblingsField(proof, "adminSiblings", accountSiblings)
 
 	pubAccountHash := account.GetPubAccountHash()
-	proof = append(proof, pubAccountHash.Bytes()...)
+	proof = bc.appendField(proof, "adminPubAccountHash", pubAccountHash.Bytes())
 
-	feeAmount, feeSiblings := account.tree.GetProof(uint64(FeeTokenIndex))
-	proof = appendTokenProof(proof, feeAmount, feeSiblings)
+	feeAmount, feeSiblings := account.tree.GetProof(uint64(bc.params.FeeTokenIndex))
+	proof = bc.appendTokenField(proof, "adminFeeToken", feeAmount, feeSiblings)
 
-	account.tree.Update(uint64(FeeTokenIndex), util.AddAmount(feeAmount, fee))
-	accountHash := crypto.Keccak256Hash(account.tree.RootHash().Bytes(), pubAccountHash.Bytes())
+	if err := bc.addBalance(bc.params.AdminIndex, account, bc.params.FeeTokenIndex, feeAmount, fee); err != nil {
+		return nil, err
+	}
+	accountHash := bc.params.hasher().HashPair(account.tree.RootHash(), pubAccountHash)
 
-	bc.state.tree.Update(uint64(AdminIndex), accountHash)
-	return proof
+	bc.state.tree.Update(uint64(bc.params.AdminIndex), accountHash)
+	return proof, nil
+}
+
+// addBalance sets the balance of tokenID of the account to balance + value
+func (bc *Blockchain) addBalance(accountID uint32, account *Account, tokenID uint16, balance common.Hash, value *big.Int) error {
+	newBalance, err := util.AddAmount(balance, value)
+	if err != nil {
+		return err
+	}
+	account.tree.Update(uint64(tokenID), newBalance)
+	bc.traceBalance(accountID, tokenID, balance.Big(), newBalance.Big())
+	return nil
+}
+
+// subBalance sets the balance of tokenID of the account to balance - value
+func (bc *Blockchain) subBalance(accountID uint32, account *Account, tokenID uint16, balance common.Hash, value *big.Int) error {
+	newBalance, err := util.SubAmount(balance, value)
+	if err != nil {
+		return err
+	}
+	account.tree.Update(uint64(tokenID), newBalance)
+	bc.traceBalance(accountID, tokenID, balance.Big(), newBalance.Big())
+	return nil
+}
+
+// putLOO writes the LOO and its leaf
+func (bc *Blockchain) putLOO(looID uint64, loo *types.LeftOverOrder) {
+	if bc.trace != nil {
+		bc.trace.LOOs = append(bc.trace.LOOs, LOODiff{LOOID: looID, Before: bc.looState.get(looID), After: loo.Clone()})
+	}
+	bc.looState.put(looID, loo)
+	bc.looState.tree.Update(looID, loo.HashWith(bc.params.hasher()))
 }
 
 func appendTokenProof(proof hexutil.Bytes, tokenAmount common.Hash, siblings []common.Hash) hexutil.Bytes {
@@ -509,25 +722,46 @@ func (bc *Blockchain) GetStateData() *StateData {
 		LOORoot:    bc.looState.tree.RootHash(),
 		AccountMax: bc.accountMax,
 		LOOMax:     bc.looMax,
+		hasher:     bc.params.hasher(),
 	}
 }
 
+// LeftOverOrderList is the LOOs of a Blockchain and their tree, in its Store
 type LeftOverOrderList struct {
-	loos map[uint64]*types.LeftOverOrder
-	tree *MerkleTree
+	store Store
+	tree  *MerkleTree
+}
+
+// NewLOOList returns an empty list in memory
+func NewLOOList(params *Params) *LeftOverOrderList {
+	return newLOOList(NewMemoryStore(), params)
 }
 
-func NewLOOList() *LeftOverOrderList {
+func newLOOList(store Store, params *Params) *LeftOverOrderList {
 	return &LeftOverOrderList{
-		loos: make(map[uint64]*types.LeftOverOrder),
-		tree: NewTree(LOOTreeDeep),
+		store: store,
+		tree:  NewTreeWithStore(params.LOOTreeDeep, store.Tree(LOOTreeID), params.hasher()),
 	}
 }
 
+// get returns a copy of the LOO, or nil if it does not exist, a changed LOO must be put back
+func (l *LeftOverOrderList) get(looID uint64) *types.LeftOverOrder {
+	loo, ok := l.store.GetLOO(looID)
+	if !ok {
+		return nil
+	}
+	return loo
+}
+
+func (l *LeftOverOrderList) put(looID uint64, loo *types.LeftOverOrder) {
+	l.store.PutLOO(looID, loo)
+}
+
+// BlockData is a block of a fraud proof suit, Proof accuses its miniblock MiniBlockNumber, which is -1 if it has no Proof
 type BlockData struct {
 	MiniBlocks      []*types.MiniBlock
 	Timestamp       uint32
-	MiniBlockNumber uint
+	MiniBlockNumber int
 	Proof           *FraudProof
 }
 
@@ -1,13 +1,19 @@
 package blockchain
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"math/big"
+	"sync"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
 
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
 	"github.com/KyberNetwork/l2-contract-test-suite/types"
 )
 
@@ -48,7 +54,7 @@ func TestNewBlockchain(t *testing.T) {
 		},
 		AccountMax: 18,
 		LooMax:     0,
-	})
+	}, nil)
 	fmt.Println(bc.GetStateData().Hash().Hex())
 
 	blk := &types.MiniBlock{
@@ -90,6 +96,249 @@ func TestNewBlockchain(t *testing.T) {
 			},
 		},
 	}
-	proofs := bc.AddMiniBlock(blk)
+	proofs, err := bc.AddMiniBlock(blk)
+	require.NoError(t, err)
 	fmt.Println(proofs)
 }
+
+func TestBlockchain_AddMiniBlockParams(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+		},
+	}
+	var txs []types.Transaction
+	for i := 0; i < 9; i++ {
+		txs = append(txs, &types.DepositOp{AccountID: 0, TokenID: 1, Amount: big.NewInt(1)})
+	}
+
+	bc := NewBlockchain(genesis, nil)
+	stateData := bc.GetStateData()
+	_, err := bc.AddMiniBlock(&types.MiniBlock{Txs: txs})
+	require.Error(t, err)
+	require.Equal(t, stateData, bc.GetStateData())
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 0, TokenID: 1024, Amount: big.NewInt(1)}}})
+	require.Error(t, err)
+	require.Equal(t, stateData, bc.GetStateData())
+
+	params := DefaultParams()
+	params.NumTxPerBlock = 9
+	params.StateTreeDeep = 5
+	bc = NewBlockchain(genesis, params)
+	proofs, err := bc.AddMiniBlock(&types.MiniBlock{Txs: txs})
+	require.NoError(t, err)
+	require.Len(t, proofs, 10)
+	// deposit proof: 4 account siblings, pub account hash, token amount and 10 token siblings, account, token, amount
+	require.Len(t, proofs[0], 4*32+32+32+10*32+4+2+32)
+}
+
+func TestBlockchain_AddMiniBlockOverflow(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{0: util.MaxUint256}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			1: {Tokens: map[uint16]*big.Int{0: big.NewInt(10), 1: big.NewInt(100)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+		},
+		AccountMax: 1,
+	}
+	bc := NewBlockchain(genesis, nil)
+	stateData := bc.GetStateData()
+
+	var arithmeticErr *util.ArithmeticError
+	for _, txs := range [][]types.Transaction{
+		// the first deposit succeeds, the second overflows the balance
+		{
+			&types.DepositOp{AccountID: 1, TokenID: 1, Amount: big.NewInt(1)},
+			&types.DepositOp{AccountID: 0, TokenID: 0, Amount: big.NewInt(1)},
+		},
+		{&types.WithdrawOp{AccountID: 1, TokenID: 1, Amount: types.PackedAmount{Mantisa: 101}}},
+		{&types.WithdrawOp{AccountID: 1, TokenID: 1, Amount: types.PackedAmount{Mantisa: 1, Exp: 78}}},
+		// the fee paid to the admin overflows its balance
+		{&types.WithdrawOp{AccountID: 1, TokenID: 1, Amount: types.PackedAmount{Mantisa: 1}, Fee: types.PackedFee{Mantisa: 1}}},
+	} {
+		_, err := bc.AddMiniBlock(&types.MiniBlock{Txs: txs})
+		require.True(t, errors.As(err, &arithmeticErr), err)
+		require.Equal(t, stateData, bc.GetStateData())
+	}
+}
+
+func TestBlockchain_ReplayedOrder(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			1: {Tokens: map[uint16]*big.Int{1: big.NewInt(1000)}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			2: {Tokens: map[uint16]*big.Int{2: big.NewInt(1000)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+		},
+		AccountMax: 2,
+	}
+	settlement := func() *types.Settlement1 {
+		return &types.Settlement1{
+			OpType:   types.SettlementOp11,
+			Token1:   1,
+			Token2:   2,
+			Account1: 1,
+			Account2: 2,
+			Rate1:    types.PackedAmount{Mantisa: 1, Exp: 18},
+			Rate2:    types.PackedAmount{Mantisa: 1, Exp: 18},
+			Amount1:  types.PackedAmount{Mantisa: 100},
+			Amount2:  types.PackedAmount{Mantisa: 100},
+		}
+	}
+	bc := NewBlockchain(genesis, nil)
+	_, err := bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{settlement()}})
+	require.NoError(t, err)
+	orderHash1, orderHash2 := Settlement1OrderHashes(settlement())
+	require.True(t, bc.IsOrderUsed(1, orderHash1))
+	require.True(t, bc.IsOrderUsed(2, orderHash2))
+	require.False(t, bc.IsOrderUsed(2, orderHash1))
+
+	stateData := bc.GetStateData()
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{settlement()}})
+	require.True(t, errors.Is(err, ErrReplayedOrder))
+	require.Equal(t, stateData, bc.GetStateData())
+
+	// order 2 is still replayed until it is signed again
+	op := settlement()
+	op.ValidSince1++
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{op}})
+	require.True(t, errors.Is(err, ErrReplayedOrder))
+	op.ValidSince2++
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{op}})
+	require.NoError(t, err)
+}
+
+func TestBlockchain_Clone(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}
+	deposit := func(accountID uint32, tokenID uint16, amount int64) *types.MiniBlock {
+		return &types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: accountID, TokenID: tokenID, Amount: big.NewInt(amount)}}}
+	}
+	// forks[i] is cloned after i+1 miniblocks by the goroutine running bc, and advanced by another one meanwhile
+	const numForks = 40
+	bc := NewBlockchain(genesis, nil)
+	forks := make([]*Blockchain, numForks)
+	errs := make([]error, numForks)
+	var wg sync.WaitGroup
+	sourceErr := make(chan error, 1)
+	go func() {
+		for i := 0; i < numForks; i++ {
+			if _, err := bc.AddMiniBlock(deposit(8, uint16(i), int64(i+1))); err != nil {
+				sourceErr <- err
+				return
+			}
+			forks[i] = bc.Clone()
+			wg.Add(1)
+			go func(i int, fork *Blockchain) {
+				defer wg.Done()
+				_, errs[i] = fork.AddMiniBlock(deposit(0, 1, int64(1000+i)))
+			}(i, forks[i])
+		}
+		_, err := bc.AddMiniBlock(deposit(0, 2, 7))
+		sourceErr <- err
+	}()
+	require.NoError(t, <-sourceErr)
+	wg.Wait()
+
+	export := func(bc *Blockchain) *StateDump {
+		dump, err := bc.Export()
+		require.NoError(t, err)
+		return dump
+	}
+	reference := NewBlockchain(genesis, nil)
+	for i, fork := range forks {
+		require.NoError(t, errs[i])
+		_, err := reference.AddMiniBlock(deposit(8, uint16(i), int64(i+1)))
+		require.NoError(t, err)
+		forked := reference.Clone()
+		_, err = forked.AddMiniBlock(deposit(0, 1, int64(1000+i)))
+		require.NoError(t, err)
+		require.Equal(t, export(forked), export(fork))
+		require.Equal(t, i+2, fork.Version())
+	}
+	_, err := reference.AddMiniBlock(deposit(0, 2, 7))
+	require.NoError(t, err)
+	require.Equal(t, export(reference), export(bc))
+
+	// cloning does not change bc, several goroutines clone it at once
+	clones := make([]*Blockchain, 4)
+	for i := range clones {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clones[i] = bc.Clone()
+			_, errs[i] = clones[i].AddMiniBlock(deposit(0, 3, int64(i+1)))
+		}(i)
+	}
+	wg.Wait()
+	for i, clone := range clones {
+		require.NoError(t, errs[i])
+		forked := reference.Clone()
+		_, err = forked.AddMiniBlock(deposit(0, 3, int64(i+1)))
+		require.NoError(t, err)
+		require.Equal(t, export(forked), export(clone))
+	}
+	require.Equal(t, export(reference), export(bc))
+
+	// a fork reverts without changing the blockchain it was cloned from
+	require.NoError(t, forks[0].RevertToVersion(0))
+	require.Equal(t, export(NewBlockchain(genesis, nil)), export(forks[0]))
+	require.Equal(t, export(reference), export(bc))
+}
+
+func TestBlockchain_Hasher(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}
+	params := DefaultParams()
+	params.Hasher = hasher.MiMC7Name
+	bc := NewBlockchain(genesis, params)
+	_, err := bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}}})
+	require.NoError(t, err)
+	keccakBC := NewBlockchain(genesis, nil)
+	_, err = keccakBC.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}}})
+	require.NoError(t, err)
+	require.NotEqual(t, keccakBC.GetStateData().StateRoot, bc.GetStateData().StateRoot)
+	require.Equal(t, hasher.MiMC7.Hash(bc.GetStateData().Bytes()), bc.GetStateData().Hash())
+	// a decoded state data is hashed by the hasher of the params, not guessed
+	b, err := json.Marshal(bc.GetStateData())
+	require.NoError(t, err)
+	var decoded StateData
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Panics(t, func() { decoded.Hash() })
+	require.Equal(t, bc.GetStateData().Hash(), decoded.HashWith(hasher.MiMC7))
+
+	// the account proves against the state root by MiMC7
+	account := bc.state.getAccount(8)
+	value := hasher.MiMC7.HashPair(account.tree.RootHash(), hasher.MiMC7.Hash(account.pubKey, account.withdrawTo.Bytes()))
+	leaf, siblings := bc.state.tree.GetProof(8)
+	require.Equal(t, value, leaf)
+	k := uint64(8)
+	for _, sibling := range siblings {
+		if k&1 == 0 {
+			value = hashNode(hasher.MiMC7, value, sibling)
+		} else {
+			value = hashNode(hasher.MiMC7, sibling, value)
+		}
+		k >>= 1
+	}
+	require.Equal(t, bc.GetStateData().StateRoot, value)
+
+	dump, err := bc.Export()
+	require.NoError(t, err)
+	imported, err := Import(dump)
+	require.NoError(t, err)
+	require.Equal(t, bc.GetStateData(), imported.GetStateData())
+
+	params = DefaultParams()
+	params.Hasher = "sha256"
+	_, err = NewBlockchainWithStore(NewMemoryStore(), genesis, params)
+	require.Error(t, err)
+}
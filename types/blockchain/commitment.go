@@ -102,12 +102,11 @@ func (bc *Blockchain) buildSettlement1ZkMsg(op *types.Settlement1) []byte {
 	var (
 		account1 *Account
 		account2 *Account
-		ok       bool
 	)
-	if account1, ok = bc.state.accounts[op.Account1]; !ok {
+	if account1 = bc.state.getAccount(op.Account1); account1 == nil {
 		panic("account 1 not found")
 	}
-	if account2, ok = bc.state.accounts[op.Account2]; !ok {
+	if account2 = bc.state.getAccount(op.Account2); account2 == nil {
 		panic("account 1 not found")
 	}
 	return BuildSettlement1ZkMsg(op, account1.pubKey, account2.pubKey)
@@ -116,13 +115,12 @@ func (bc *Blockchain) buildSettlement1ZkMsg(op *types.Settlement1) []byte {
 func (bc *Blockchain) buildSettlement2ZkMsg(op *types.Settlement2) []byte {
 	var (
 		account *Account
-		ok      bool
 	)
-	loo := bc.looState.loos[op.LooID1]
+	loo := bc.looState.get(op.LooID1)
 	if loo == nil {
 		panic("loo not found")
 	}
-	if account, ok = bc.state.accounts[op.AccountID2]; !ok {
+	if account = bc.state.getAccount(op.AccountID2); account == nil {
 		panic("account 1 not found")
 	}
 	return BuildSettlement2ZkMsg(op, loo.SrcToken, loo.DestToken, account.pubKey)
@@ -159,23 +157,23 @@ func BuildWithdrawZkMsg(op *types.WithdrawOp, accountPubKey hexutil.Bytes) []byt
 func (bc *Blockchain) buildWithdrawZkMsg(op *types.WithdrawOp) []byte {
 	var (
 		account *Account
-		ok      bool
 	)
 
-	if account, ok = bc.state.accounts[op.AccountID]; !ok {
+	if account = bc.state.getAccount(op.AccountID); account == nil {
 		panic("account 1 not found")
 	}
 	return BuildWithdrawZkMsg(op, account.pubKey)
 }
 
-// only for the last miniblock
+// BuildCommitmentProof proves the accounts and LOOs of block in bc, which must be the state right after block,
+// see StateAt for an earlier miniblock
 func (bc *Blockchain) BuildCommitmentProof(block *types.MiniBlock) hexutil.Bytes {
 	var proof hexutil.Bytes
 
 	for _, tx := range block.Txs {
 		switch obj := tx.(type) {
 		case *types.Settlement1:
-			account := bc.state.accounts[obj.Account1]
+			account := bc.state.getAccount(obj.Account1)
 			if account == nil {
 				panic("account not found")
 			}
@@ -186,7 +184,7 @@ func (bc *Blockchain) BuildCommitmentProof(block *types.MiniBlock) hexutil.Bytes
 			_, accountSiblings := bc.state.tree.GetProof(uint64(obj.Account1))
 			proof = appendSiblings(proof, accountSiblings)
 
-			account = bc.state.accounts[obj.Account2]
+			account = bc.state.getAccount(obj.Account2)
 			if account == nil {
 				panic("account not found")
 			}
@@ -197,7 +195,7 @@ func (bc *Blockchain) BuildCommitmentProof(block *types.MiniBlock) hexutil.Bytes
 			_, accountSiblings = bc.state.tree.GetProof(uint64(obj.Account2))
 			proof = appendSiblings(proof, accountSiblings)
 		case *types.Settlement2:
-			account := bc.state.accounts[obj.AccountID2]
+			account := bc.state.getAccount(obj.AccountID2)
 			if account == nil {
 				panic("account not found")
 			}
@@ -208,7 +206,7 @@ func (bc *Blockchain) BuildCommitmentProof(block *types.MiniBlock) hexutil.Bytes
 			_, accountSiblings := bc.state.tree.GetProof(uint64(obj.AccountID2))
 			proof = appendSiblings(proof, accountSiblings)
 
-			loo := bc.looState.loos[obj.LooID1]
+			loo := bc.looState.get(obj.LooID1)
 			if loo == nil {
 				panic("loo not found")
 			}
@@ -217,7 +215,7 @@ func (bc *Blockchain) BuildCommitmentProof(block *types.MiniBlock) hexutil.Bytes
 			proof = append(proof, util.Uint48ToBytes(obj.LooID1)...)
 			proof = appendSiblings(proof, looSiblings)
 		case *types.WithdrawOp:
-			account := bc.state.accounts[obj.AccountID]
+			account := bc.state.getAccount(obj.AccountID)
 			if account == nil {
 				panic("account not found")
 			}
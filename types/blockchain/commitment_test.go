@@ -86,7 +86,7 @@ func Test_Commitment(t *testing.T) {
 	err = json.Unmarshal(data, &test)
 	require.NoError(t, err)
 
-	//bc := NewBlockchain(genesis)
+	//bc := NewBlockchain(genesis, nil)
 	//amount0, _ := new(big.Int).SetString("12000000000000000000", 10)
 	//miniBlock1 := &types.MiniBlock{
 	//	Txs: []types.Transaction{
@@ -168,3 +168,75 @@ func Test_Commitment(t *testing.T) {
 	}, test.AccountPubKeys[1])
 	require.Equal(t, hexutil.Encode(outWithdraw), hexutil.Encode(test.MiniBlockPubData[768:896]))
 }
+
+func TestDisassembleZkMsg(t *testing.T) {
+	pubKey1 := hexutil.MustDecode("0x2a1b0c4ea6bbc7e6ae1dc62dc2d4c1a2e0bcc01f5b5ba6f1e2d6c8b3b4e2f101")
+	pubKey2 := hexutil.MustDecode("0x0f3c2a1b0c4ea6bbc7e6ae1dc62dc2d4c1a2e0bcc01f5b5ba6f1e2d6c8b3b402")
+	op := &types.Settlement1{
+		OpType:       types.SettlementOp13,
+		Token1:       1,
+		Token2:       1<<types.TokenIDBits - 1,
+		Account1:     4,
+		Account2:     9,
+		Amount1:      types.PackedAmount{Mantisa: 15, Exp: 17},
+		Amount2:      types.PackedAmount{Mantisa: 3, Exp: 6},
+		Rate1:        types.PackedAmount{Mantisa: 2, Exp: 13},
+		Rate2:        types.PackedAmount{Mantisa: 5, Exp: 22},
+		Fee1:         types.PackedFee{Mantisa: 1<<types.FeeMantisaBits - 1, Exp: 1<<types.FeeExpBits - 1},
+		Fee2:         types.PackedFee{Mantisa: 7, Exp: 2},
+		ValidSince1:  1600661872,
+		ValidSince2:  1600661873,
+		ValidPeriod1: 1<<types.ValidPeriodBits - 1,
+		ValidPeriod2: 86400,
+	}
+	sections, err := types.DisassembleZkMsg(BuildSettlement1ZkMsg(op, pubKey1, pubKey2))
+	require.NoError(t, err)
+	require.Len(t, sections, 2)
+	values := func(section types.Section) map[string]string {
+		out := make(map[string]string)
+		for _, field := range section.Fields {
+			out[field.Name] = field.Value
+		}
+		return out
+	}
+	require.Equal(t, map[string]string{
+		"type":              "1",
+		"accountID":         "4",
+		"amount":            "1500000000000000000 (15e17)",
+		"rate":              "20000000000000 (2e13)",
+		"validSince":        "1600661872",
+		"validPeriod":       "268435455",
+		"fee":               "1023000000000000000000000000000000000000000000000000000000000000000 (1023e63)",
+		"srcToken":          "1",
+		"destToken":         "1023",
+		"partiallyFillable": "false",
+		"pubKey":            hexutil.Encode(pubKey1),
+	}, values(sections[0]))
+	require.Equal(t, "false", values(sections[1])["partiallyFillable"])
+	require.Equal(t, "1", values(sections[1])["destToken"])
+	require.Equal(t, "86400", values(sections[1])["validPeriod"])
+	require.Equal(t, 64, sections[1].Offset)
+
+	withdraw := &types.WithdrawOp{
+		TokenID:    1<<types.TokenIDBits - 1,
+		Amount:     types.PackedAmount{Mantisa: 9, Exp: 3},
+		AccountID:  2,
+		ValidSince: 1600661872,
+		Fee:        types.PackedFee{Mantisa: 1<<types.FeeMantisaBits - 1, Exp: 2},
+	}
+	sections, err = types.DisassembleZkMsg(BuildWithdrawZkMsg(withdraw, pubKey2))
+	require.NoError(t, err)
+	require.Len(t, sections, 1)
+	require.Equal(t, map[string]string{
+		"type":       "9",
+		"accountID":  "2",
+		"amount":     "9000 (9e3)",
+		"validSince": "1600661872",
+		"tokenID":    "1023",
+		"fee":        "102300 (1023e2)",
+		"pubKey":     hexutil.Encode(pubKey2),
+	}, values(sections[0]))
+
+	_, err = types.DisassembleZkMsg(make([]byte, types.ZkMsgLength))
+	require.Error(t, err)
+}
@@ -0,0 +1,221 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// BalanceChange is the balance of a token before and after, a missing balance is zero
+type BalanceChange struct {
+	TokenID uint16
+	Before  *big.Int
+	After   *big.Int
+}
+
+// AccountDiff lists the changes of an account, Before or After is nil if the account is created or removed
+type AccountDiff struct {
+	AccountID  uint32
+	Before     *AccountDump
+	After      *AccountDump
+	Tokens     []BalanceChange
+	UsedOrders []common.Hash `json:",omitempty"`
+}
+
+// LOODiff is a LOO before and after, Before is nil if the LOO is created
+type LOODiff struct {
+	LOOID  uint64
+	Before *types.LeftOverOrder
+	After  *types.LeftOverOrder
+}
+
+// StateDiff lists the changes from a StateDump to another, accounts and LOOs are ordered by id
+type StateDiff struct {
+	StateHashBefore  common.Hash
+	StateHashAfter   common.Hash
+	AccountMaxBefore uint32
+	AccountMaxAfter  uint32
+	LOOMaxBefore     uint64
+	LOOMaxAfter      uint64
+	// FeeTokenIndex is the token of the fees of LOOs
+	FeeTokenIndex uint16
+	Accounts      []AccountDiff
+	LOOs          []LOODiff
+}
+
+// Diff returns the changes from a to b
+func Diff(a, b *StateDump) *StateDiff {
+	diff := &StateDiff{
+		StateHashBefore:  a.StateHash,
+		StateHashAfter:   b.StateHash,
+		AccountMaxBefore: a.AccountMax,
+		AccountMaxAfter:  b.AccountMax,
+		LOOMaxBefore:     a.LOOMax,
+		LOOMaxAfter:      b.LOOMax,
+		FeeTokenIndex:    DefaultParams().FeeTokenIndex,
+	}
+	if b.Params != nil {
+		diff.FeeTokenIndex = b.Params.FeeTokenIndex
+	}
+
+	accountIDs := make(map[uint32]struct{})
+	for accountID := range a.Accounts {
+		accountIDs[accountID] = struct{}{}
+	}
+	for accountID := range b.Accounts {
+		accountIDs[accountID] = struct{}{}
+	}
+	for accountID := range accountIDs {
+		if accountDiff, changed := diffAccount(accountID, a.Accounts[accountID], b.Accounts[accountID]); changed {
+			diff.Accounts = append(diff.Accounts, accountDiff)
+		}
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool { return diff.Accounts[i].AccountID < diff.Accounts[j].AccountID })
+
+	looIDs := make(map[uint64]struct{})
+	for looID := range a.LOOs {
+		looIDs[looID] = struct{}{}
+	}
+	for looID := range b.LOOs {
+		looIDs[looID] = struct{}{}
+	}
+	for looID := range looIDs {
+		before, after := a.LOOs[looID], b.LOOs[looID]
+		if before != nil && after != nil && before.Hash() == after.Hash() {
+			continue
+		}
+		diff.LOOs = append(diff.LOOs, LOODiff{LOOID: looID, Before: before, After: after})
+	}
+	sort.Slice(diff.LOOs, func(i, j int) bool { return diff.LOOs[i].LOOID < diff.LOOs[j].LOOID })
+	return diff
+}
+
+func diffAccount(accountID uint32, before, after *AccountDump) (diff AccountDiff, changed bool) {
+	diff = AccountDiff{AccountID: accountID, Before: before, After: after}
+	var (
+		tokensBefore, tokensAfter map[uint16]*big.Int
+		usedOrders                = make(map[common.Hash]struct{})
+	)
+	if before != nil {
+		tokensBefore = before.Tokens
+		for _, orderHash := range before.UsedOrders {
+			usedOrders[orderHash] = struct{}{}
+		}
+	}
+	if after != nil {
+		tokensAfter = after.Tokens
+		for _, orderHash := range after.UsedOrders {
+			if _, ok := usedOrders[orderHash]; !ok {
+				diff.UsedOrders = append(diff.UsedOrders, orderHash)
+			}
+		}
+	}
+
+	tokenIDs := make(map[uint16]struct{})
+	for tokenID := range tokensBefore {
+		tokenIDs[tokenID] = struct{}{}
+	}
+	for tokenID := range tokensAfter {
+		tokenIDs[tokenID] = struct{}{}
+	}
+	for tokenID := range tokenIDs {
+		x, y := balanceOf(tokensBefore, tokenID), balanceOf(tokensAfter, tokenID)
+		if x.Cmp(y) != 0 {
+			diff.Tokens = append(diff.Tokens, BalanceChange{TokenID: tokenID, Before: x, After: y})
+		}
+	}
+	sort.Slice(diff.Tokens, func(i, j int) bool { return diff.Tokens[i].TokenID < diff.Tokens[j].TokenID })
+
+	changed = len(diff.Tokens) > 0 || len(diff.UsedOrders) > 0 || (before == nil) != (after == nil)
+	if before != nil && after != nil {
+		changed = changed || !sameAccount(before, after)
+	}
+	return diff, changed
+}
+
+func balanceOf(tokens map[uint16]*big.Int, tokenID uint16) *big.Int {
+	if x, ok := tokens[tokenID]; ok && x != nil {
+		return x
+	}
+	return big.NewInt(0)
+}
+
+func sameAccount(a, b *AccountDump) bool {
+	return a.PubKey.String() == b.PubKey.String() && a.WithdrawTo == b.WithdrawTo && a.IsConfirmedExit == b.IsConfirmedExit
+}
+
+// IsEmpty reports whether both dumps have the same state
+func (d *StateDiff) IsEmpty() bool {
+	return d.StateHashBefore == d.StateHashAfter && d.AccountMaxBefore == d.AccountMaxAfter &&
+		d.LOOMaxBefore == d.LOOMaxAfter && len(d.Accounts) == 0 && len(d.LOOs) == 0
+}
+
+// Format returns one line per change, amounts are formatted in token units of registry if it is not nil
+func (d *StateDiff) Format(registry *types.TokenRegistry) string {
+	format := func(tokenID uint16, x *big.Int) string {
+		if registry == nil {
+			return fmt.Sprintf("%s of token %d", x.String(), tokenID)
+		}
+		return registry.FormatAmount(tokenID, x)
+	}
+
+	var lines []string
+	if d.StateHashBefore != d.StateHashAfter {
+		lines = append(lines, fmt.Sprintf("state hash %s -> %s", d.StateHashBefore.Hex(), d.StateHashAfter.Hex()))
+	}
+	if d.AccountMaxBefore != d.AccountMaxAfter {
+		lines = append(lines, fmt.Sprintf("AccountMax %d -> %d", d.AccountMaxBefore, d.AccountMaxAfter))
+	}
+	if d.LOOMaxBefore != d.LOOMaxAfter {
+		lines = append(lines, fmt.Sprintf("LOOMax %d -> %d", d.LOOMaxBefore, d.LOOMaxAfter))
+	}
+	for _, account := range d.Accounts {
+		switch {
+		case account.Before == nil:
+			lines = append(lines, fmt.Sprintf("account %d created: pubkey %s, withdrawTo %s",
+				account.AccountID, account.After.PubKey.String(), account.After.WithdrawTo.Hex()))
+		case account.After == nil:
+			lines = append(lines, fmt.Sprintf("account %d removed", account.AccountID))
+		default:
+			if account.Before.PubKey.String() != account.After.PubKey.String() {
+				lines = append(lines, fmt.Sprintf("account %d pubkey %s -> %s",
+					account.AccountID, account.Before.PubKey.String(), account.After.PubKey.String()))
+			}
+			if account.Before.WithdrawTo != account.After.WithdrawTo {
+				lines = append(lines, fmt.Sprintf("account %d withdrawTo %s -> %s",
+					account.AccountID, account.Before.WithdrawTo.Hex(), account.After.WithdrawTo.Hex()))
+			}
+			if account.Before.IsConfirmedExit != account.After.IsConfirmedExit {
+				lines = append(lines, fmt.Sprintf("account %d exited %t -> %t",
+					account.AccountID, account.Before.IsConfirmedExit, account.After.IsConfirmedExit))
+			}
+		}
+		for _, change := range account.Tokens {
+			lines = append(lines, fmt.Sprintf("account %d token %d: %s -> %s", account.AccountID, change.TokenID,
+				format(change.TokenID, change.Before), format(change.TokenID, change.After)))
+		}
+		for _, orderHash := range account.UsedOrders {
+			lines = append(lines, fmt.Sprintf("account %d used order %s", account.AccountID, orderHash.Hex()))
+		}
+	}
+	for _, loo := range d.LOOs {
+		switch {
+		case loo.Before == nil:
+			lines = append(lines, fmt.Sprintf("loo %d created: account %d sells %s for token %d at rate %s, fee %s, valid since %d for %d",
+				loo.LOOID, loo.After.AccountID, format(loo.After.SrcToken, loo.After.Amount), loo.After.DestToken,
+				loo.After.Rate.String(), format(d.FeeTokenIndex, loo.After.Fee), loo.After.ValidSince, loo.After.ValidPeriod))
+		case loo.After == nil:
+			lines = append(lines, fmt.Sprintf("loo %d removed", loo.LOOID))
+		default:
+			lines = append(lines, fmt.Sprintf("loo %d of account %d: amount %s -> %s, fee %s -> %s", loo.LOOID, loo.After.AccountID,
+				format(loo.Before.SrcToken, loo.Before.Amount), format(loo.After.SrcToken, loo.After.Amount),
+				format(d.FeeTokenIndex, loo.Before.Fee), format(d.FeeTokenIndex, loo.After.Fee)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
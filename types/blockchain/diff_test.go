@@ -0,0 +1,62 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+func TestDiff(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			1: {Tokens: map[uint16]*big.Int{0: big.NewInt(100), 1: big.NewInt(1000)}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			2: {Tokens: map[uint16]*big.Int{0: big.NewInt(100), 2: big.NewInt(1000)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+		},
+		AccountMax: 2,
+	}
+	bc := NewBlockchain(genesis, nil)
+	before, err := bc.Export()
+	require.NoError(t, err)
+	require.True(t, Diff(before, before).IsEmpty())
+
+	// account 1 is partially filled, its left-over order is created
+	op := &types.Settlement1{
+		OpType:   types.SettlementOp12,
+		Token1:   1,
+		Token2:   2,
+		Account1: 1,
+		Account2: 2,
+		Rate1:    types.PackedAmount{Mantisa: 1, Exp: 18},
+		Rate2:    types.PackedAmount{Mantisa: 1, Exp: 18},
+		Amount1:  types.PackedAmount{Mantisa: 300},
+		Amount2:  types.PackedAmount{Mantisa: 100},
+		Fee1:     types.PackedFee{Mantisa: 3},
+	}
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{op}})
+	require.NoError(t, err)
+	after, err := bc.Export()
+	require.NoError(t, err)
+
+	diff := Diff(before, after)
+	require.False(t, diff.IsEmpty())
+	require.Equal(t, uint64(0), diff.LOOMaxBefore)
+	require.Equal(t, uint64(1), diff.LOOMaxAfter)
+	require.Len(t, diff.LOOs, 1)
+	require.Nil(t, diff.LOOs[0].Before)
+	require.Equal(t, uint32(1), diff.LOOs[0].After.AccountID)
+
+	require.Len(t, diff.Accounts, 3)
+	require.Equal(t, uint32(1), diff.Accounts[1].AccountID)
+	require.Equal(t, []BalanceChange{
+		{TokenID: 0, Before: big.NewInt(100), After: big.NewInt(99)},
+		{TokenID: 1, Before: big.NewInt(1000), After: big.NewInt(900)},
+		{TokenID: 2, Before: big.NewInt(0), After: big.NewInt(100)},
+	}, diff.Accounts[1].Tokens)
+	require.Len(t, diff.Accounts[1].UsedOrders, 1)
+	require.Contains(t, diff.Format(nil), "account 1 token 2: 0 of token 2 -> 100 of token 2")
+}
@@ -0,0 +1,194 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// AccountDump is an account of a StateDump
+type AccountDump struct {
+	PubKey          hexutil.Bytes
+	WithdrawTo      common.Address
+	Tokens          map[uint16]*big.Int
+	IsConfirmedExit bool `json:",omitempty"`
+	// UsedOrders are the hashes of the settled orders of the account, see OrderHash
+	UsedOrders []common.Hash `json:",omitempty"`
+}
+
+// StateDump is the whole state of a Blockchain, StateHash is the hash of its StateData
+type StateDump struct {
+	Params      *Params
+	StateHash   common.Hash
+	Accounts    map[uint32]*AccountDump
+	AccountMax  uint32
+	LOOs        map[uint64]*types.LeftOverOrder
+	LOOMax      uint64
+	NumDeposit  uint64
+	NumWithdraw uint
+}
+
+// Export returns a copy of the whole state of bc
+func (bc *Blockchain) Export() (*StateDump, error) {
+	params := *bc.params
+	dump := &StateDump{
+		Params:      &params,
+		StateHash:   bc.GetStateData().Hash(),
+		Accounts:    make(map[uint32]*AccountDump),
+		AccountMax:  bc.accountMax,
+		LOOs:        make(map[uint64]*types.LeftOverOrder),
+		LOOMax:      bc.looMax,
+		NumDeposit:  bc.numDeposit,
+		NumWithdraw: bc.numWithdraw,
+	}
+	bc.state.forEachAccount(func(accountID uint32, account *Account) {
+		accountDump := &AccountDump{
+			PubKey:          append(hexutil.Bytes{}, account.pubKey...),
+			WithdrawTo:      account.withdrawTo,
+			Tokens:          account.Balances(),
+			IsConfirmedExit: account.isConfirmedExit,
+		}
+		bc.store.ForEachUsedOrder(accountID, func(orderHash common.Hash) {
+			accountDump.UsedOrders = append(accountDump.UsedOrders, orderHash)
+		})
+		sort.Slice(accountDump.UsedOrders, func(i, j int) bool {
+			return bytes.Compare(accountDump.UsedOrders[i].Bytes(), accountDump.UsedOrders[j].Bytes()) < 0
+		})
+		dump.Accounts[accountID] = accountDump
+	})
+	bc.store.ForEachLOO(func(looID uint64, loo *types.LeftOverOrder) {
+		dump.LOOs[looID] = loo
+	})
+	return dump, nil
+}
+
+// Import returns the blockchain of dump in memory, params defaults to DefaultParams().
+// It fails if a field does not fit in its tree or the state hash differs from dump.StateHash.
+func Import(dump *StateDump) (*Blockchain, error) {
+	return ImportWithStore(NewMemoryStore(), dump)
+}
+
+// ImportWithStore writes the blockchain of dump to store, which must be empty, see Import.
+// Nothing is written if it fails.
+func ImportWithStore(store Store, dump *StateDump) (*Blockchain, error) {
+	if _, ok := store.GetMeta(); ok {
+		return nil, fmt.Errorf("store already holds a blockchain")
+	}
+	params := DefaultParams()
+	if dump.Params != nil {
+		p := *dump.Params
+		params = &p
+	}
+	if _, err := hasher.ByName(params.Hasher); err != nil {
+		return nil, err
+	}
+	bc := &Blockchain{
+		params:      params,
+		accountMax:  dump.AccountMax,
+		looMax:      dump.LOOMax,
+		numDeposit:  dump.NumDeposit,
+		numWithdraw: dump.NumWithdraw,
+	}
+	overlay := newOverlayStore(store)
+	bc.setStore(overlay)
+	accountHashes := make(map[uint64]common.Hash, len(dump.Accounts))
+	for accountID, accountDump := range dump.Accounts {
+		if uint64(accountID) >= 1<<(params.StateTreeDeep-1) {
+			return nil, fmt.Errorf("account %d out of the state tree", accountID)
+		}
+		account := bc.state.newAccount(accountID, append(hexutil.Bytes{}, accountDump.PubKey...), accountDump.WithdrawTo)
+		for tokenID, amount := range accountDump.Tokens {
+			if uint64(tokenID) >= 1<<(params.AccountTreeDeep-1) {
+				return nil, fmt.Errorf("token %d of account %d out of the account tree", tokenID, accountID)
+			}
+			if amount == nil || amount.Sign() < 0 || amount.Cmp(util.MaxUint256) > 0 {
+				return nil, fmt.Errorf("invalid balance of token %d of account %d", tokenID, accountID)
+			}
+			account.Update(tokenID, amount)
+		}
+		account.isConfirmedExit = accountDump.IsConfirmedExit
+		bc.state.putAccount(accountID, account)
+		for _, orderHash := range accountDump.UsedOrders {
+			bc.store.PutUsedOrder(accountID, orderHash)
+		}
+
+		balanceRoot := account.tree.RootHash()
+		if account.isConfirmedExit {
+			balanceRoot = common.HexToHash(zeroHash)
+		}
+		accountHashes[uint64(accountID)] = params.hasher().HashPair(balanceRoot, account.GetPubAccountHash())
+	}
+	bc.state.tree.UpdateBatch(accountHashes)
+
+	looHashes := make(map[uint64]common.Hash, len(dump.LOOs))
+	for looID, loo := range dump.LOOs {
+		if looID >= 1<<(params.LOOTreeDeep-1) {
+			return nil, fmt.Errorf("loo %d out of the loo tree", looID)
+		}
+		if loo == nil || loo.Amount == nil || loo.Fee == nil || loo.Rate == nil {
+			return nil, fmt.Errorf("invalid loo %d", looID)
+		}
+		bc.looState.put(looID, loo)
+		looHashes[looID] = loo.HashWith(params.hasher())
+	}
+	bc.looState.tree.UpdateBatch(looHashes)
+	if stateHash := bc.GetStateData().Hash(); stateHash != dump.StateHash {
+		return nil, fmt.Errorf("state hash %s differs from the dump %s", stateHash.Hex(), dump.StateHash.Hex())
+	}
+	overlay.PutMeta(bc.meta())
+	overlay.commit()
+	bc.setStore(store)
+	return bc, nil
+}
+
+// LoadStateDump reads a StateDump written as JSON
+func LoadStateDump(path string) (*StateDump, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var dump StateDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse state dump %s: %w", path, err)
+	}
+	return &dump, nil
+}
+
+// WriteFile writes d as JSON
+func (d *StateDump) WriteFile(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// DumpBalances returns the balances of every account ordered by token id in token units of registry,
+// e.g. "1.5 ETH"
+func (bc *Blockchain) DumpBalances(registry *types.TokenRegistry) map[uint32][]string {
+	dump := make(map[uint32][]string)
+	bc.state.forEachAccount(func(accountID uint32, account *Account) {
+		balances := account.Balances()
+		var tokenIDs []int
+		for tokenID := range balances {
+			tokenIDs = append(tokenIDs, int(tokenID))
+		}
+		sort.Ints(tokenIDs)
+		out := []string{}
+		for _, tokenID := range tokenIDs {
+			out = append(out, registry.FormatAmount(uint16(tokenID), balances[uint16(tokenID)]))
+		}
+		dump[accountID] = out
+	})
+	return dump
+}
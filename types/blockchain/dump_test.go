@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+func TestBlockchain_ExportImport(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			1: {Tokens: map[uint16]*big.Int{1: big.NewInt(1000)}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			2: {Tokens: map[uint16]*big.Int{2: big.NewInt(1000)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+			3: {Tokens: map[uint16]*big.Int{1: big.NewInt(5)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+		},
+		AccountMax: 3,
+		LooAlloc: map[uint64]*types.LeftOverOrder{
+			0: {AccountID: 2, SrcToken: 2, DestToken: 1, Amount: big.NewInt(10), Fee: big.NewInt(0), Rate: big.NewInt(1e18), ValidSince: 1600661872, ValidPeriod: 86400},
+		},
+		LooMax: 1,
+	}
+	bc := NewBlockchain(genesis, nil)
+	_, err := bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{
+		&types.Settlement1{
+			OpType:   types.SettlementOp11,
+			Token1:   1,
+			Token2:   2,
+			Account1: 1,
+			Account2: 2,
+			Rate1:    types.PackedAmount{Mantisa: 1, Exp: 18},
+			Rate2:    types.PackedAmount{Mantisa: 1, Exp: 18},
+			Amount1:  types.PackedAmount{Mantisa: 100},
+			Amount2:  types.PackedAmount{Mantisa: 100},
+		},
+		&types.ExitOp{AccountID: 3},
+		&types.DepositOp{AccountID: 1, TokenID: 2, Amount: big.NewInt(7)},
+	}})
+	require.NoError(t, err)
+
+	dump, err := bc.Export()
+	require.NoError(t, err)
+	require.True(t, dump.Accounts[3].IsConfirmedExit)
+	require.Len(t, dump.Accounts[1].UsedOrders, 1)
+	require.Len(t, dump.LOOs, 1)
+
+	data, err := json.Marshal(dump)
+	require.NoError(t, err)
+	var decoded StateDump
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	imported, err := Import(&decoded)
+	require.NoError(t, err)
+	require.Equal(t, bc.GetStateData(), imported.GetStateData())
+	reexported, err := imported.Export()
+	require.NoError(t, err)
+	require.Equal(t, dump, reexported)
+
+	// both blockchains execute the next miniblock alike
+	next := &types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 2, TokenID: 1, Amount: big.NewInt(3)}}}
+	proofs, err := bc.AddMiniBlock(next)
+	require.NoError(t, err)
+	importedProofs, err := imported.AddMiniBlock(next)
+	require.NoError(t, err)
+	require.Equal(t, proofs, importedProofs)
+	require.Equal(t, bc.GetStateData(), imported.GetStateData())
+
+	decoded.Accounts[2].Tokens[2] = big.NewInt(1)
+	_, err = Import(&decoded)
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import "fmt"
+
+// Version returns the number of miniblocks executed by bc since it was created, in LevelDB the versions are
+// persisted and restored by OpenBlockchain
+func (bc *Blockchain) Version() int {
+	return bc.pruned + len(bc.history)
+}
+
+// versionKey is the key of the record undoing version in a store
+func versionKey(version int) []byte {
+	return concat([]byte("version"), uint64Bytes(uint64(version)))
+}
+
+// StateAt returns the blockchain as it was after version miniblocks, see Version.
+// It reads the store of bc through the writes undoing the later miniblocks, so it is only valid until bc changes.
+// Executing miniblocks on it does not change bc.
+func (bc *Blockchain) StateAt(version int) (*Blockchain, error) {
+	if version < bc.pruned || version > bc.Version() {
+		return nil, fmt.Errorf("version %d not found, the versions are %d to %d", version, bc.pruned, bc.Version())
+	}
+	view := newOverlayStore(bc.store)
+	for i := len(bc.history) - 1; i >= version-bc.pruned; i-- {
+		bc.history[i].writeTo(view)
+	}
+	meta, ok := view.GetMeta()
+	if !ok {
+		return nil, fmt.Errorf("store holds no blockchain")
+	}
+	state := &Blockchain{
+		params:      bc.params,
+		accountMax:  meta.AccountMax,
+		looMax:      meta.LOOMax,
+		numDeposit:  meta.NumDeposit,
+		numWithdraw: meta.NumWithdraw,
+		history:     append([]*overlayStore(nil), bc.history[:version-bc.pruned]...),
+		pruned:      bc.pruned,
+	}
+	state.setStore(view)
+	return state, nil
+}
+
+// RevertToVersion restores bc in place as it was after version miniblocks, dropping the later versions
+func (bc *Blockchain) RevertToVersion(version int) error {
+	state, err := bc.StateAt(version)
+	if err != nil {
+		return err
+	}
+	view := state.store.(*overlayStore)
+	if bc.persistent() {
+		for v := version + 1; v <= bc.Version(); v++ {
+			view.DeleteRecord(versionKey(v))
+		}
+	}
+	// the versions pruned since are not restored
+	view.PutMeta(state.meta())
+	bc.apply(view)
+	bc.accountMax, bc.looMax = state.accountMax, state.looMax
+	bc.numDeposit, bc.numWithdraw = state.numDeposit, state.numWithdraw
+	n := version - bc.pruned
+	bc.history = bc.history[:n:n]
+	return nil
+}
+
+// PruneHistory drops the versions before version, bc can then only be restored to version or a later one.
+// The history grows with every miniblock, it is meant to be pruned once the earlier states can no longer be reverted to.
+func (bc *Blockchain) PruneHistory(version int) error {
+	if version < bc.pruned || version > bc.Version() {
+		return fmt.Errorf("version %d not found, the versions are %d to %d", version, bc.pruned, bc.Version())
+	}
+	// the remaining versions are copied to release the dropped ones
+	bc.history = append([]*overlayStore(nil), bc.history[version-bc.pruned:]...)
+	pruned := bc.pruned
+	bc.pruned = version
+	if bc.persistent() {
+		overlay := newOverlayStore(bc.store)
+		for v := pruned + 1; v <= version; v++ {
+			overlay.DeleteRecord(versionKey(v))
+		}
+		overlay.PutMeta(bc.meta())
+		bc.apply(overlay)
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldberrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	levelutil "github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// key prefixes of the LevelDB store
+var (
+	nodePrefix      = []byte("n") // nodePrefix + tree kind + account id + level + index -> hash
+	accountPrefix   = []byte("a") // accountPrefix + account id -> AccountInfo JSON
+	usedOrderPrefix = []byte("o") // usedOrderPrefix + account id + order hash -> empty
+	looPrefix       = []byte("l") // looPrefix + loo id -> LeftOverOrder JSON
+	metaKey         = []byte("m") // StoreMeta JSON
+	recordPrefix    = []byte("r") // recordPrefix + key -> record
+)
+
+// errReadOnly is the panic of a write to a snapshot of a LevelDB store
+var errReadOnly = errors.New("write to a read-only LevelDB snapshot")
+
+// levelDBReader is the reading part of a LevelDB database, implemented by the database and its snapshots
+type levelDBReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *levelutil.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+type levelDBStore struct {
+	// db is nil for a snapshot, which is read-only
+	db     *leveldb.DB
+	reader levelDBReader
+	// pending is the batch of the writes of a commit
+	pending *leveldb.Batch
+}
+
+// NewLevelDBStore opens the LevelDB database at path, creating it if missing
+func NewLevelDBStore(path string) (Store, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{
+		OpenFilesCacheCapacity: 256,
+		BlockCacheCapacity:     128 * opt.MiB,
+		WriteBuffer:            64 * opt.MiB,
+		Filter:                 filter.NewBloomFilter(10),
+		DisableSeeksCompaction: true,
+	})
+	if _, corrupted := err.(*leveldberrors.ErrCorrupted); corrupted {
+		db, err = leveldb.RecoverFile(path, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBStore{db: db, reader: db}, nil
+}
+
+// levelDBSnapshot is a read-only LevelDB store as it was when the snapshot was taken
+type levelDBSnapshot struct {
+	*levelDBStore
+}
+
+// snapshot returns the store as it is now, the later writes to s are not seen through it
+func (s *levelDBStore) snapshot() *levelDBSnapshot {
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		panic(err)
+	}
+	// the snapshot is also released when it is garbage collected
+	return &levelDBSnapshot{&levelDBStore{reader: snap}}
+}
+
+func (s *levelDBSnapshot) Close() error {
+	s.reader.(*leveldb.Snapshot).Release()
+	return nil
+}
+
+func (s *levelDBStore) get(key []byte) ([]byte, bool) {
+	value, err := s.reader.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, false
+	}
+	if err != nil {
+		panic(err)
+	}
+	return value, true
+}
+
+func (s *levelDBStore) put(key, value []byte) {
+	switch {
+	case s.db == nil:
+		panic(errReadOnly)
+	case s.pending != nil:
+		s.pending.Put(key, value)
+	default:
+		if err := s.db.Put(key, value, nil); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (s *levelDBStore) delete(key []byte) {
+	switch {
+	case s.db == nil:
+		panic(errReadOnly)
+	case s.pending != nil:
+		s.pending.Delete(key)
+	default:
+		if err := s.db.Delete(key, nil); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (s *levelDBStore) forEach(prefix []byte, fn func(key, value []byte)) {
+	it := s.reader.NewIterator(levelutil.BytesPrefix(prefix), nil)
+	defer it.Release()
+	for it.Next() {
+		fn(it.Key()[len(prefix):], it.Value())
+	}
+	if err := it.Error(); err != nil {
+		panic(err)
+	}
+}
+
+// batch writes the writes of fn at once
+func (s *levelDBStore) batch(fn func()) {
+	if s.db == nil {
+		panic(errReadOnly)
+	}
+	s.pending = new(leveldb.Batch)
+	fn()
+	if err := s.db.Write(s.pending, nil); err != nil {
+		panic(err)
+	}
+	s.pending = nil
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func uint32Bytes(x uint32) []byte {
+	out := make([]byte, 4)
+	binary.BigEndian.PutUint32(out, x)
+	return out
+}
+
+func uint64Bytes(x uint64) []byte {
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, x)
+	return out
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func mustUnmarshal(data []byte, v interface{}) {
+	if err := json.Unmarshal(data, v); err != nil {
+		panic(err)
+	}
+}
+
+type levelDBNodeStore struct {
+	store  *levelDBStore
+	prefix []byte
+}
+
+func (s *levelDBNodeStore) levelPrefix(level uint) []byte {
+	return concat(s.prefix, []byte{byte(level)})
+}
+
+func (s *levelDBNodeStore) GetNode(level uint, index uint64) (common.Hash, bool) {
+	value, ok := s.store.get(concat(s.levelPrefix(level), uint64Bytes(index)))
+	return common.BytesToHash(value), ok
+}
+
+func (s *levelDBNodeStore) PutNode(level uint, index uint64, value common.Hash) {
+	s.store.put(concat(s.levelPrefix(level), uint64Bytes(index)), value.Bytes())
+}
+
+func (s *levelDBNodeStore) DeleteNode(level uint, index uint64) {
+	s.store.delete(concat(s.levelPrefix(level), uint64Bytes(index)))
+}
+
+func (s *levelDBNodeStore) ForEachNode(level uint, fn func(index uint64, value common.Hash)) {
+	s.store.forEach(s.levelPrefix(level), func(key, value []byte) {
+		fn(binary.BigEndian.Uint64(key), common.BytesToHash(value))
+	})
+}
+
+func (s *levelDBStore) Tree(id TreeID) NodeStore {
+	return &levelDBNodeStore{store: s, prefix: concat(nodePrefix, []byte{byte(id.Kind)}, uint32Bytes(id.AccountID))}
+}
+
+func (s *levelDBStore) GetAccount(accountID uint32) (*AccountInfo, bool) {
+	data, ok := s.get(concat(accountPrefix, uint32Bytes(accountID)))
+	if !ok {
+		return nil, false
+	}
+	var info AccountInfo
+	mustUnmarshal(data, &info)
+	return &info, true
+}
+
+func (s *levelDBStore) PutAccount(accountID uint32, info *AccountInfo) {
+	s.put(concat(accountPrefix, uint32Bytes(accountID)), mustMarshal(info))
+}
+
+func (s *levelDBStore) DeleteAccount(accountID uint32) {
+	s.delete(concat(accountPrefix, uint32Bytes(accountID)))
+}
+
+func (s *levelDBStore) ForEachAccount(fn func(accountID uint32, info *AccountInfo)) {
+	s.forEach(accountPrefix, func(key, value []byte) {
+		var info AccountInfo
+		mustUnmarshal(value, &info)
+		fn(binary.BigEndian.Uint32(key), &info)
+	})
+}
+
+func (s *levelDBStore) HasUsedOrder(accountID uint32, orderHash common.Hash) bool {
+	_, ok := s.get(concat(usedOrderPrefix, uint32Bytes(accountID), orderHash.Bytes()))
+	return ok
+}
+
+func (s *levelDBStore) PutUsedOrder(accountID uint32, orderHash common.Hash) {
+	s.put(concat(usedOrderPrefix, uint32Bytes(accountID), orderHash.Bytes()), []byte{})
+}
+
+func (s *levelDBStore) DeleteUsedOrder(accountID uint32, orderHash common.Hash) {
+	s.delete(concat(usedOrderPrefix, uint32Bytes(accountID), orderHash.Bytes()))
+}
+
+func (s *levelDBStore) ForEachUsedOrder(accountID uint32, fn func(orderHash common.Hash)) {
+	s.forEach(concat(usedOrderPrefix, uint32Bytes(accountID)), func(key, _ []byte) {
+		fn(common.BytesToHash(key))
+	})
+}
+
+func (s *levelDBStore) GetLOO(looID uint64) (*types.LeftOverOrder, bool) {
+	data, ok := s.get(concat(looPrefix, uint64Bytes(looID)))
+	if !ok {
+		return nil, false
+	}
+	var loo types.LeftOverOrder
+	mustUnmarshal(data, &loo)
+	return &loo, true
+}
+
+func (s *levelDBStore) PutLOO(looID uint64, loo *types.LeftOverOrder) {
+	s.put(concat(looPrefix, uint64Bytes(looID)), mustMarshal(loo))
+}
+
+func (s *levelDBStore) DeleteLOO(looID uint64) {
+	s.delete(concat(looPrefix, uint64Bytes(looID)))
+}
+
+func (s *levelDBStore) ForEachLOO(fn func(looID uint64, loo *types.LeftOverOrder)) {
+	s.forEach(looPrefix, func(key, value []byte) {
+		var loo types.LeftOverOrder
+		mustUnmarshal(value, &loo)
+		fn(binary.BigEndian.Uint64(key), &loo)
+	})
+}
+
+func (s *levelDBStore) GetMeta() (*StoreMeta, bool) {
+	data, ok := s.get(metaKey)
+	if !ok {
+		return nil, false
+	}
+	var meta StoreMeta
+	mustUnmarshal(data, &meta)
+	return &meta, true
+}
+
+func (s *levelDBStore) PutMeta(meta *StoreMeta) {
+	s.put(metaKey, mustMarshal(meta))
+}
+
+func (s *levelDBStore) GetRecord(key []byte) ([]byte, bool) {
+	return s.get(concat(recordPrefix, key))
+}
+
+func (s *levelDBStore) PutRecord(key []byte, value []byte) {
+	s.put(concat(recordPrefix, key), value)
+}
+
+func (s *levelDBStore) DeleteRecord(key []byte) {
+	s.delete(concat(recordPrefix, key))
+}
+
+func (s *levelDBStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,53 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// ErrReplayedOrder is returned when a settlement includes an order settled before
+var ErrReplayedOrder = errors.New("replayed order")
+
+// OrderHash returns the hash of the 32-byte zk message of an order signed by its account
+func OrderHash(zkMsg []byte) common.Hash {
+	return crypto.Keccak256Hash(zkMsg)
+}
+
+// Settlement1OrderHashes returns the hashes of the 2 orders of op
+func Settlement1OrderHashes(op *types.Settlement1) (orderHash1, orderHash2 common.Hash) {
+	msg1 := buildZkMsg(op.Account1, op.Token1, op.Token2, op.Amount1, op.Rate1,
+		op.ValidSince1, op.ValidPeriod1, op.Fee1, op.OpType == types.SettlementOp11)
+	msg2 := buildZkMsg(op.Account2, op.Token2, op.Token1, op.Amount2, op.Rate2,
+		op.ValidSince2, op.ValidPeriod2, op.Fee2, op.OpType != types.SettlementOp13)
+	return OrderHash(msg1), OrderHash(msg2)
+}
+
+// Settlement2OrderHash returns the hash of the order of op settled against the left-over order loo
+func Settlement2OrderHash(op *types.Settlement2, loo *types.LeftOverOrder) common.Hash {
+	msg := buildZkMsg(op.AccountID2, loo.DestToken, loo.SrcToken, op.Amount2, op.Rate2,
+		op.ValidSince2, op.ValidPeriod2, op.Fee2, op.OpType == types.SettlementOp21)
+	return OrderHash(msg)
+}
+
+// useOrder records the order of accountID as settled, it fails if the order was settled before unless
+// the params allow replayed orders
+func (bc *Blockchain) useOrder(accountID uint32, orderHash common.Hash) error {
+	if bc.state.getAccount(accountID) == nil {
+		panic("empty account")
+	}
+	if bc.store.HasUsedOrder(accountID, orderHash) && !bc.params.AllowReplayedOrders {
+		return fmt.Errorf("order %s of account %d: %w", orderHash.Hex(), accountID, ErrReplayedOrder)
+	}
+	bc.store.PutUsedOrder(accountID, orderHash)
+	return nil
+}
+
+// IsOrderUsed returns true if the order of accountID was settled
+func (bc *Blockchain) IsOrderUsed(accountID uint32, orderHash common.Hash) bool {
+	return bc.store.HasUsedOrder(accountID, orderHash)
+}
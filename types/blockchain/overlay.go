@@ -0,0 +1,442 @@
+package blockchain
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// overlayStore buffers the writes to its parent until commit, dropping it reverts them.
+// A nil account, LOO or record and a false used order are deleted ones.
+type overlayStore struct {
+	parent     Store
+	trees      map[TreeID]*overlayNodeStore
+	accounts   map[uint32]*AccountInfo
+	usedOrders map[uint32]map[common.Hash]bool
+	loos       map[uint64]*types.LeftOverOrder
+	meta       *StoreMeta
+	records    map[string][]byte
+	// frozen is set once the overlay is a layer of a blockchain in memory, see push. It is no longer written
+	// and may be shared by the clones of the blockchain.
+	frozen bool
+}
+
+func newOverlayStore(parent Store) *overlayStore {
+	return &overlayStore{
+		parent:     parent,
+		trees:      make(map[TreeID]*overlayNodeStore),
+		accounts:   make(map[uint32]*AccountInfo),
+		usedOrders: make(map[uint32]map[common.Hash]bool),
+		loos:       make(map[uint64]*types.LeftOverOrder),
+		records:    make(map[string][]byte),
+	}
+}
+
+type overlayNode struct {
+	value   common.Hash
+	deleted bool
+}
+
+type overlayNodeStore struct {
+	parent NodeStore
+	levels []map[uint64]overlayNode
+}
+
+func (s *overlayNodeStore) GetNode(level uint, index uint64) (common.Hash, bool) {
+	if level < uint(len(s.levels)) {
+		if node, ok := s.levels[level][index]; ok {
+			return node.value, !node.deleted
+		}
+	}
+	return s.parent.GetNode(level, index)
+}
+
+func (s *overlayNodeStore) set(level uint, index uint64, node overlayNode) {
+	for level >= uint(len(s.levels)) {
+		s.levels = append(s.levels, make(map[uint64]overlayNode))
+	}
+	s.levels[level][index] = node
+}
+
+func (s *overlayNodeStore) PutNode(level uint, index uint64, value common.Hash) {
+	s.set(level, index, overlayNode{value: value})
+}
+
+func (s *overlayNodeStore) DeleteNode(level uint, index uint64) {
+	s.set(level, index, overlayNode{deleted: true})
+}
+
+func (s *overlayNodeStore) ForEachNode(level uint, fn func(index uint64, value common.Hash)) {
+	var nodes map[uint64]overlayNode
+	if level < uint(len(s.levels)) {
+		nodes = s.levels[level]
+	}
+	s.parent.ForEachNode(level, func(index uint64, value common.Hash) {
+		if _, ok := nodes[index]; !ok {
+			fn(index, value)
+		}
+	})
+	for index, node := range nodes {
+		if !node.deleted {
+			fn(index, node.value)
+		}
+	}
+}
+
+func (s *overlayStore) Tree(id TreeID) NodeStore {
+	tree, ok := s.trees[id]
+	if !ok {
+		if s.frozen {
+			return s.parent.Tree(id)
+		}
+		tree = &overlayNodeStore{parent: s.parent.Tree(id)}
+		s.trees[id] = tree
+	}
+	return tree
+}
+
+func (s *overlayStore) GetAccount(accountID uint32) (*AccountInfo, bool) {
+	if info, ok := s.accounts[accountID]; ok {
+		if info == nil {
+			return nil, false
+		}
+		return info.clone(), true
+	}
+	return s.parent.GetAccount(accountID)
+}
+
+func (s *overlayStore) PutAccount(accountID uint32, info *AccountInfo) {
+	s.accounts[accountID] = info.clone()
+}
+
+func (s *overlayStore) DeleteAccount(accountID uint32) {
+	s.accounts[accountID] = nil
+}
+
+func (s *overlayStore) ForEachAccount(fn func(accountID uint32, info *AccountInfo)) {
+	s.parent.ForEachAccount(func(accountID uint32, info *AccountInfo) {
+		if _, ok := s.accounts[accountID]; !ok {
+			fn(accountID, info)
+		}
+	})
+	for accountID, info := range s.accounts {
+		if info != nil {
+			fn(accountID, info.clone())
+		}
+	}
+}
+
+func (s *overlayStore) HasUsedOrder(accountID uint32, orderHash common.Hash) bool {
+	if used, ok := s.usedOrders[accountID][orderHash]; ok {
+		return used
+	}
+	return s.parent.HasUsedOrder(accountID, orderHash)
+}
+
+func (s *overlayStore) setUsedOrder(accountID uint32, orderHash common.Hash, used bool) {
+	if s.usedOrders[accountID] == nil {
+		s.usedOrders[accountID] = make(map[common.Hash]bool)
+	}
+	s.usedOrders[accountID][orderHash] = used
+}
+
+func (s *overlayStore) PutUsedOrder(accountID uint32, orderHash common.Hash) {
+	s.setUsedOrder(accountID, orderHash, true)
+}
+
+func (s *overlayStore) DeleteUsedOrder(accountID uint32, orderHash common.Hash) {
+	s.setUsedOrder(accountID, orderHash, false)
+}
+
+func (s *overlayStore) ForEachUsedOrder(accountID uint32, fn func(orderHash common.Hash)) {
+	orders := s.usedOrders[accountID]
+	s.parent.ForEachUsedOrder(accountID, func(orderHash common.Hash) {
+		if _, ok := orders[orderHash]; !ok {
+			fn(orderHash)
+		}
+	})
+	for orderHash, used := range orders {
+		if used {
+			fn(orderHash)
+		}
+	}
+}
+
+func (s *overlayStore) GetLOO(looID uint64) (*types.LeftOverOrder, bool) {
+	if loo, ok := s.loos[looID]; ok {
+		if loo == nil {
+			return nil, false
+		}
+		return loo.Clone(), true
+	}
+	return s.parent.GetLOO(looID)
+}
+
+func (s *overlayStore) PutLOO(looID uint64, loo *types.LeftOverOrder) {
+	s.loos[looID] = loo.Clone()
+}
+
+func (s *overlayStore) DeleteLOO(looID uint64) {
+	s.loos[looID] = nil
+}
+
+func (s *overlayStore) ForEachLOO(fn func(looID uint64, loo *types.LeftOverOrder)) {
+	s.parent.ForEachLOO(func(looID uint64, loo *types.LeftOverOrder) {
+		if _, ok := s.loos[looID]; !ok {
+			fn(looID, loo)
+		}
+	})
+	for looID, loo := range s.loos {
+		if loo != nil {
+			fn(looID, loo.Clone())
+		}
+	}
+}
+
+func (s *overlayStore) GetMeta() (*StoreMeta, bool) {
+	if s.meta != nil {
+		return s.meta.clone(), true
+	}
+	return s.parent.GetMeta()
+}
+
+func (s *overlayStore) PutMeta(meta *StoreMeta) {
+	s.meta = meta.clone()
+}
+
+func (s *overlayStore) GetRecord(key []byte) ([]byte, bool) {
+	if value, ok := s.records[string(key)]; ok {
+		return append([]byte{}, value...), value != nil
+	}
+	return s.parent.GetRecord(key)
+}
+
+func (s *overlayStore) PutRecord(key []byte, value []byte) {
+	s.records[string(key)] = append([]byte{}, value...)
+}
+
+func (s *overlayStore) DeleteRecord(key []byte) {
+	s.records[string(key)] = nil
+}
+
+func (s *overlayStore) Close() error {
+	return nil
+}
+
+// commit writes the buffered writes to the parent, at once in a LevelDB store
+func (s *overlayStore) commit() {
+	if parent, ok := s.parent.(*levelDBStore); ok {
+		parent.batch(func() { s.writeTo(parent) })
+		return
+	}
+	s.writeTo(s.parent)
+}
+
+// writeTo writes the buffered writes to target
+func (s *overlayStore) writeTo(target Store) {
+	for id, tree := range s.trees {
+		targetTree := target.Tree(id)
+		for level, nodes := range tree.levels {
+			for index, node := range nodes {
+				if node.deleted {
+					targetTree.DeleteNode(uint(level), index)
+				} else {
+					targetTree.PutNode(uint(level), index, node.value)
+				}
+			}
+		}
+	}
+	for accountID, info := range s.accounts {
+		if info == nil {
+			target.DeleteAccount(accountID)
+		} else {
+			target.PutAccount(accountID, info)
+		}
+	}
+	for accountID, orders := range s.usedOrders {
+		for orderHash, used := range orders {
+			if used {
+				target.PutUsedOrder(accountID, orderHash)
+			} else {
+				target.DeleteUsedOrder(accountID, orderHash)
+			}
+		}
+	}
+	for looID, loo := range s.loos {
+		if loo == nil {
+			target.DeleteLOO(looID)
+		} else {
+			target.PutLOO(looID, loo)
+		}
+	}
+	if s.meta != nil {
+		target.PutMeta(s.meta)
+	}
+	for key, value := range s.records {
+		if value == nil {
+			target.DeleteRecord([]byte(key))
+		} else {
+			target.PutRecord([]byte(key), value)
+		}
+	}
+}
+
+// undo returns the writes restoring the parent as it is now once s is committed.
+// The returned overlay has no parent, it is only written to other stores.
+func (s *overlayStore) undo() *overlayStore {
+	undo := newOverlayStore(nil)
+	for id, tree := range s.trees {
+		parent, undoTree := s.parent.Tree(id), &overlayNodeStore{}
+		undo.trees[id] = undoTree
+		for level, nodes := range tree.levels {
+			for index := range nodes {
+				if value, ok := parent.GetNode(uint(level), index); ok {
+					undoTree.PutNode(uint(level), index, value)
+				} else {
+					undoTree.DeleteNode(uint(level), index)
+				}
+			}
+		}
+	}
+	for accountID := range s.accounts {
+		info, _ := s.parent.GetAccount(accountID)
+		undo.accounts[accountID] = info
+	}
+	for accountID, orders := range s.usedOrders {
+		for orderHash := range orders {
+			undo.setUsedOrder(accountID, orderHash, s.parent.HasUsedOrder(accountID, orderHash))
+		}
+	}
+	for looID := range s.loos {
+		loo, _ := s.parent.GetLOO(looID)
+		undo.loos[looID] = loo
+	}
+	if s.meta != nil {
+		undo.meta, _ = s.parent.GetMeta()
+	}
+	for key := range s.records {
+		if value, ok := s.parent.GetRecord([]byte(key)); ok {
+			undo.records[key] = value
+		} else {
+			undo.records[key] = nil
+		}
+	}
+	return undo
+}
+
+// size returns the number of nodes, accounts, used orders and LOOs written to s
+func (s *overlayStore) size() int {
+	n := len(s.accounts) + len(s.loos) + len(s.records)
+	for _, tree := range s.trees {
+		for _, nodes := range tree.levels {
+			n += len(nodes)
+		}
+	}
+	for _, orders := range s.usedOrders {
+		n += len(orders)
+	}
+	return n
+}
+
+// push freezes overlay as the top layer of a blockchain in memory, so that the clones reading the layers below
+// never see its writes. It is merged with the frozen layers below it while it is at least half as large as them,
+// the reads then go through a logarithmic number of layers. It returns the new top layer.
+func push(overlay *overlayStore) *overlayStore {
+	top := overlay
+	for {
+		below, ok := top.parent.(*overlayStore)
+		if !ok || !below.frozen || 2*top.size() < below.size() {
+			break
+		}
+		merged := newOverlayStore(below.parent)
+		below.writeTo(merged)
+		top.writeTo(merged)
+		top = merged
+	}
+	top.frozen = true
+	return top
+}
+
+// overlayJSON is the encoding of the writes of an overlay, e.g. the undo of a version persisted to a store
+type overlayJSON struct {
+	Nodes          []overlayNodeJSON               `json:",omitempty"`
+	Accounts       map[uint32]*AccountInfo         `json:",omitempty"`
+	UsedOrders     map[uint32]map[common.Hash]bool `json:",omitempty"`
+	LOOs           map[uint64]*types.LeftOverOrder `json:",omitempty"`
+	Meta           *StoreMeta                      `json:",omitempty"`
+	Records        map[string]hexutil.Bytes        `json:",omitempty"`
+	DeletedRecords []hexutil.Bytes                 `json:",omitempty"`
+}
+
+type overlayNodeJSON struct {
+	Tree    TreeID
+	Level   uint
+	Index   uint64
+	Value   common.Hash
+	Deleted bool `json:",omitempty"`
+}
+
+func (s *overlayStore) MarshalJSON() ([]byte, error) {
+	enc := overlayJSON{
+		Accounts:   s.accounts,
+		UsedOrders: s.usedOrders,
+		LOOs:       s.loos,
+		Meta:       s.meta,
+		Records:    make(map[string]hexutil.Bytes),
+	}
+	for id, tree := range s.trees {
+		for level, nodes := range tree.levels {
+			for index, node := range nodes {
+				enc.Nodes = append(enc.Nodes, overlayNodeJSON{Tree: id, Level: uint(level), Index: index, Value: node.value, Deleted: node.deleted})
+			}
+		}
+	}
+	for key, value := range s.records {
+		if value == nil {
+			enc.DeletedRecords = append(enc.DeletedRecords, []byte(key))
+		} else {
+			enc.Records[hexutil.Encode([]byte(key))] = value
+		}
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes the writes of an overlay into s, which must be empty
+func (s *overlayStore) UnmarshalJSON(data []byte) error {
+	var dec overlayJSON
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+	for _, node := range dec.Nodes {
+		tree, ok := s.trees[node.Tree]
+		if !ok {
+			tree = &overlayNodeStore{}
+			s.trees[node.Tree] = tree
+		}
+		tree.set(node.Level, node.Index, overlayNode{value: node.Value, deleted: node.Deleted})
+	}
+	for accountID, info := range dec.Accounts {
+		s.accounts[accountID] = info
+	}
+	for accountID, orders := range dec.UsedOrders {
+		s.usedOrders[accountID] = orders
+	}
+	for looID, loo := range dec.LOOs {
+		s.loos[looID] = loo
+	}
+	s.meta = dec.Meta
+	for key, value := range dec.Records {
+		k, err := hexutil.Decode(key)
+		if err != nil {
+			return err
+		}
+		s.records[string(k)] = append([]byte{}, value...)
+	}
+	for _, key := range dec.DeletedRecords {
+		s.records[string(key)] = nil
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package blockchain
+
+import "github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
+
+// Params are the protocol parameters the contract under test is deployed with
+type Params struct {
+	AccountTreeDeep uint
+	StateTreeDeep   uint
+	LOOTreeDeep     uint
+	FeeTokenIndex   uint16
+	AdminIndex      uint32
+	// NumTxPerBlock is the max number of txs of a miniblock, the commitment of a miniblock is padded to it
+	NumTxPerBlock int
+	// AllowReplayedOrders skips the check of settled orders, only to build the blocks of a malicious operator.
+	// The contract can not prove a replayed order: the settled orders are not part of the state hash
+	AllowReplayedOrders bool `json:",omitempty"`
+	// Hasher names the hash of the trees and their leaves, see hasher.ByName, the empty name is Keccak
+	Hasher string `json:",omitempty"`
+}
+
+func (p *Params) hasher() hasher.Hasher {
+	h, err := hasher.ByName(p.Hasher)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// DefaultParams returns the parameters of the deployed contract
+func DefaultParams() *Params {
+	return &Params{
+		AccountTreeDeep: 11,
+		StateTreeDeep:   33,
+		LOOTreeDeep:     45,
+		FeeTokenIndex:   0,
+		AdminIndex:      0,
+		NumTxPerBlock:   8,
+	}
+}
@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// SubmittedBlock is a block as submitted to the contract by submitBlock
+type SubmittedBlock struct {
+	BlockNumber uint32
+	MiniBlocks  []hexutil.Bytes
+	Timestamp   uint32
+}
+
+// Root returns the root the contract stores for blk on top of the block prevBlockRoot, see NewBlockHeader
+func (blk *SubmittedBlock) Root(prevBlockRoot common.Hash) (common.Hash, error) {
+	if len(blk.MiniBlocks) == 0 {
+		return common.Hash{}, fmt.Errorf("empty block")
+	}
+	if len(blk.MiniBlocks) > MaxMiniBlocks {
+		return common.Hash{}, fmt.Errorf("block of %d miniblocks, at most %d", len(blk.MiniBlocks), MaxMiniBlocks)
+	}
+	var miniBlockHashes []common.Hash
+	for i, data := range blk.MiniBlocks {
+		if len(data) < 2*common.HashLength {
+			return common.Hash{}, fmt.Errorf("miniblock %d of %d bytes is shorter than its commitment and state hash", i, len(data))
+		}
+		// see MiniBlock.Hash, the pubdata of deposits does not hold the L1 deposit the tx encodes
+		txRoot := crypto.Keccak256Hash(data[2*common.HashLength:])
+		miniBlockHashes = append(miniBlockHashes, crypto.Keccak256Hash(data[:2*common.HashLength], txRoot.Bytes()))
+	}
+	last := blk.MiniBlocks[len(blk.MiniBlocks)-1]
+	header := &BlockHeader{
+		PrevBlockRoot: prevBlockRoot,
+		BlockInfoHash: util.GetMiniBlockHash(miniBlockHashes),
+		Timestamp:     blk.Timestamp,
+		BlockNumber:   blk.BlockNumber,
+		NumMiniBlocks: uint8(len(blk.MiniBlocks)),
+		StateHash:     common.BytesToHash(last[common.HashLength : 2*common.HashLength]),
+	}
+	return header.Root(), nil
+}
+
+func (blk *SubmittedBlock) decode() ([]*types.MiniBlock, error) {
+	var miniBlocks []*types.MiniBlock
+	for i, data := range blk.MiniBlocks {
+		miniBlock, err := types.DecodeMiniBlock(data)
+		if err != nil {
+			return nil, fmt.Errorf("miniblock %d: %w", i, err)
+		}
+		miniBlocks = append(miniBlocks, miniBlock)
+	}
+	return miniBlocks, nil
+}
+
+// FillDeposits replaces the deposits of miniBlock, which only hold their DepositID in the pubdata, by copies of the L1 deposits.
+// The deposits are executed in submission order: numDeposit is the number of deposits executed before miniBlock, see
+// Blockchain.NumDeposit, and the first deposit of miniBlock must be the next one. It returns the number after miniBlock.
+func FillDeposits(miniBlock *types.MiniBlock, deposits map[uint64]types.Transaction, numDeposit uint64) (uint64, error) {
+	for i, tx := range miniBlock.Txs {
+		var depositID uint64
+		switch tx := tx.(type) {
+		case *types.DepositOp:
+			depositID = tx.DepositID
+		case *types.DepositToNewOp:
+			depositID = tx.DepositID
+		default:
+			continue
+		}
+		if depositID != numDeposit {
+			return numDeposit, fmt.Errorf("tx %d: deposit %d is not the next deposit %d", i, depositID, numDeposit)
+		}
+		// the execution sets the DepositID of the tx, the deposits are shared by every replay
+		switch deposit := deposits[depositID].(type) {
+		case *types.DepositOp:
+			op := *deposit
+			miniBlock.Txs[i] = &op
+		case *types.DepositToNewOp:
+			op := *deposit
+			miniBlock.Txs[i] = &op
+		default:
+			return numDeposit, fmt.Errorf("tx %d: deposit %d not submitted", i, depositID)
+		}
+		numDeposit++
+	}
+	return numDeposit, nil
+}
+
+// Reconstruct rebuilds on bc the blocks submitted to the contract, in the order they were submitted, from their pubdata
+// and the L1 deposits. A block submitted at a number already reached replaces it and every later block, as after
+// a successful fraud proof. Every miniblock must re-encode to the data submitted, so its state hash, its commitment
+// and the root of its block match the ones stored by the contract. A block which does not is dropped, and the blocks
+// submitted on top of it are skipped until its number is submitted again, as the watchtower does. It fails if the
+// last block dropped is not replaced.
+func Reconstruct(bc *Blockchain, blocks []*SubmittedBlock, deposits map[uint64]types.Transaction) (*Rollup, error) {
+	var (
+		r = NewRollup(bc)
+		// badBlock is the number of the last block dropped, invalid its error, until a block replaces it
+		badBlock uint32
+		invalid  error
+	)
+	for _, submitted := range blocks {
+		blockNumber := submitted.BlockNumber
+		if badBlock != 0 && blockNumber > badBlock {
+			continue
+		}
+		if blockNumber == 0 || blockNumber > r.BlockNumber()+1 {
+			return r, fmt.Errorf("block %d submitted after block %d", blockNumber, r.BlockNumber())
+		}
+		if err := r.RevertTo(blockNumber - 1); err != nil {
+			return r, err
+		}
+		badBlock, invalid = 0, nil
+		if err := addSubmittedBlock(r, submitted, deposits); err != nil {
+			badBlock, invalid = blockNumber, fmt.Errorf("block %d: %w", blockNumber, err)
+		}
+	}
+	return r, invalid
+}
+
+// addSubmittedBlock adds submitted to r, or leaves r unchanged if it is not valid
+func addSubmittedBlock(r *Rollup, submitted *SubmittedBlock, deposits map[uint64]types.Transaction) error {
+	root, err := submitted.Root(r.BlockRoot(r.BlockNumber()))
+	if err != nil {
+		return err
+	}
+	miniBlocks, err := submitted.decode()
+	if err != nil {
+		return err
+	}
+	numDeposit := r.Blockchain().NumDeposit()
+	for i, miniBlock := range miniBlocks {
+		if numDeposit, err = FillDeposits(miniBlock, deposits, numDeposit); err != nil {
+			return fmt.Errorf("miniblock %d: %w", i, err)
+		}
+	}
+	blk, err := r.AddBlock(miniBlocks, submitted.Timestamp)
+	if err != nil {
+		return err
+	}
+	for i, miniBlock := range blk.MiniBlocks {
+		claimed, err := types.DecodeMiniBlock(submitted.MiniBlocks[i])
+		if err != nil {
+			return err
+		}
+		switch {
+		case miniBlock.StateHash != claimed.StateHash:
+			err = fmt.Errorf("state hash %s, submitted %s", miniBlock.StateHash.Hex(), claimed.StateHash.Hex())
+		case miniBlock.Commitment != claimed.Commitment:
+			err = fmt.Errorf("commitment %s, submitted %s", miniBlock.Commitment.Hex(), claimed.Commitment.Hex())
+		case !bytes.Equal(miniBlock.Bytes(), submitted.MiniBlocks[i]):
+			err = fmt.Errorf("pubdata %s, submitted %s", hexutil.Bytes(miniBlock.Bytes()), submitted.MiniBlocks[i])
+		}
+		if err != nil {
+			if revertErr := r.RevertTo(r.BlockNumber() - 1); revertErr != nil {
+				return revertErr
+			}
+			return fmt.Errorf("miniblock %d: %w", i, err)
+		}
+	}
+	if blk.Header.Root() != root {
+		if err := r.RevertTo(r.BlockNumber() - 1); err != nil {
+			return err
+		}
+		return fmt.Errorf("root %s, submitted %s", blk.Header.Root().Hex(), root.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+func TestReconstruct(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}
+	deposits := map[uint64]types.Transaction{
+		0: &types.DepositOp{DepositID: 0, AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)},
+		1: &types.DepositToNewOp{DepositID: 1, PubKey: make([]byte, types.PubKeyLength), WithdrawTo: common.HexToAddress("0x1"), TokenID: 2, Amount: big.NewInt(3)},
+		2: &types.DepositOp{DepositID: 2, AccountID: 8, TokenID: 1, Amount: big.NewInt(7)},
+	}
+	rollup := NewRollup(NewBlockchain(genesis, nil))
+	var blocks []*SubmittedBlock
+	for i, miniBlocks := range [][]*types.MiniBlock{
+		{{Txs: []types.Transaction{deposits[0]}}},
+		{
+			{Txs: []types.Transaction{&types.WithdrawOp{AccountID: 8, TokenID: 2, Amount: types.PackedAmount{Mantisa: 4, Exp: 7}, Fee: types.PackedFee{Mantisa: 1, Exp: 2}}}},
+			{Txs: []types.Transaction{deposits[1]}},
+		},
+		{{Txs: []types.Transaction{deposits[2], &types.ExitOp{AccountID: 8}}}},
+	} {
+		blk, err := rollup.AddBlock(miniBlocks, uint32(1600661872+i))
+		require.NoError(t, err)
+		submitted := &SubmittedBlock{BlockNumber: blk.Header.BlockNumber, Timestamp: blk.Header.Timestamp}
+		for _, miniBlock := range miniBlocks {
+			submitted.MiniBlocks = append(submitted.MiniBlocks, miniBlock.Bytes())
+		}
+		blocks = append(blocks, submitted)
+	}
+	want, err := rollup.Blockchain().Export()
+	require.NoError(t, err)
+
+	// a block with a wrong state hash is accused and submitted again
+	bad := &SubmittedBlock{BlockNumber: 2, Timestamp: blocks[1].Timestamp, MiniBlocks: []hexutil.Bytes{
+		blocks[1].MiniBlocks[0],
+		append(hexutil.Bytes{}, blocks[1].MiniBlocks[1]...),
+	}}
+	bad.MiniBlocks[1][common.HashLength] ^= 1
+	archive := []*SubmittedBlock{blocks[0], blocks[1], blocks[2], bad, blocks[1], blocks[2]}
+
+	r, err := Reconstruct(NewBlockchain(genesis, nil), archive, deposits)
+	require.NoError(t, err)
+	require.Equal(t, rollup.BlockNumber(), r.BlockNumber())
+	for i := uint32(1); i <= r.BlockNumber(); i++ {
+		require.Equal(t, rollup.BlockRoot(i), r.BlockRoot(i))
+	}
+	got, err := r.Blockchain().Export()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	// the blocks hold copies of the deposits
+	blk, err := r.Block(1)
+	require.NoError(t, err)
+	require.False(t, deposits[0] == blk.MiniBlocks[0].Txs[0])
+
+	// the blocks submitted on top of a bad block are skipped until it is submitted again
+	r, err = Reconstruct(NewBlockchain(genesis, nil), []*SubmittedBlock{blocks[0], bad, blocks[2], blocks[1], blocks[2]}, deposits)
+	require.NoError(t, err)
+	require.Equal(t, rollup.BlockNumber(), r.BlockNumber())
+	require.Equal(t, rollup.BlockRoot(r.BlockNumber()), r.BlockRoot(r.BlockNumber()))
+
+	_, err = Reconstruct(NewBlockchain(genesis, nil), []*SubmittedBlock{blocks[0], bad, blocks[2]}, deposits)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "block 2: miniblock 1: state hash")
+	_, err = Reconstruct(NewBlockchain(genesis, nil), blocks, map[uint64]types.Transaction{0: deposits[0]})
+	require.Error(t, err)
+	_, err = Reconstruct(NewBlockchain(genesis, nil), blocks[1:], deposits)
+	require.Error(t, err)
+	// deposits are executed in submission order
+	skipped := &SubmittedBlock{BlockNumber: 2, Timestamp: blocks[2].Timestamp, MiniBlocks: blocks[2].MiniBlocks}
+	_, err = Reconstruct(NewBlockchain(genesis, nil), []*SubmittedBlock{blocks[0], skipped}, deposits)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "deposit 2 is not the next deposit 1")
+}
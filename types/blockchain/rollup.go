@@ -0,0 +1,261 @@
+package blockchain
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// BlockHeader holds the fields hashed into the root of a block
+type BlockHeader struct {
+	PrevBlockRoot common.Hash
+	BlockInfoHash common.Hash
+	Timestamp     uint32
+	BlockNumber   uint32
+	NumMiniBlocks uint8
+	StateHash     common.Hash
+}
+
+// MaxMiniBlocks is the max number of miniblocks of a block, NumMiniBlocks of its header is an uint8
+const MaxMiniBlocks = math.MaxUint8
+
+// NewBlockHeader returns the header of the block made of miniBlocks, there are 1 to MaxMiniBlocks of them
+func NewBlockHeader(prevBlockRoot common.Hash, blockNumber uint32, miniBlocks []*types.MiniBlock, timestamp uint32) *BlockHeader {
+	var miniBlockHashes []common.Hash
+	for _, blk := range miniBlocks {
+		miniBlockHashes = append(miniBlockHashes, blk.Hash())
+	}
+	return &BlockHeader{
+		PrevBlockRoot: prevBlockRoot,
+		BlockInfoHash: util.GetMiniBlockHash(miniBlockHashes),
+		Timestamp:     timestamp,
+		BlockNumber:   blockNumber,
+		NumMiniBlocks: uint8(len(miniBlocks)),
+		StateHash:     miniBlocks[len(miniBlocks)-1].StateHash,
+	}
+}
+
+// Root returns keccak(prevBlockRoot, blockInfoHash, timestamp, blockNumber, numMiniBlocks, stateHash)
+func (h *BlockHeader) Root() common.Hash {
+	return crypto.Keccak256Hash(
+		h.PrevBlockRoot.Bytes(),
+		h.BlockInfoHash.Bytes(),
+		util.Uint32ToBytes(h.Timestamp),
+		util.Uint32ToBytes(h.BlockNumber),
+		[]byte{util.Uint8ToByte(h.NumMiniBlocks)},
+		h.StateHash.Bytes(),
+	)
+}
+
+// Block is a block executed by a Rollup
+type Block struct {
+	Header     *BlockHeader
+	MiniBlocks []*types.MiniBlock
+	// PrevStateData[i] is the state data before executing MiniBlocks[i]
+	PrevStateData []*StateData
+	// ExecutionProofs[i] is the execution proof of MiniBlocks[i]
+	ExecutionProofs [][]hexutil.Bytes
+	// versions[i] is the version of the blockchain after MiniBlocks[i], see Blockchain.Version
+	versions []int
+}
+
+// MiniBlockError is returned by Rollup.AddBlock when the miniblock Index of the block can not be executed
+type MiniBlockError struct {
+	Index int
+	Err   error
+}
+
+func (e *MiniBlockError) Error() string {
+	return fmt.Sprintf("miniblock %d: %v", e.Index, e.Err)
+}
+
+func (e *MiniBlockError) Unwrap() error {
+	return e.Err
+}
+
+// Rollup executes whole blocks on a Blockchain and chains their roots by block number.
+// Block numbers start from 1, the root of block 0 is zero.
+// The blocks of a blockchain in LevelDB are persisted with it, see OpenRollup.
+type Rollup struct {
+	bc             *Blockchain
+	genesisVersion int
+	blocks         []*Block
+	// finalized is the last block which can no longer be reverted, see Finalize
+	finalized uint32
+}
+
+// NewRollup returns a rollup whose genesis is bc as it is now, it replaces the rollup persisted with bc if any
+func NewRollup(bc *Blockchain) *Rollup {
+	r := &Rollup{bc: bc, genesisVersion: bc.Version()}
+	r.persist(nil)
+	return r
+}
+
+// Blockchain returns the state after the last block
+func (r *Rollup) Blockchain() *Blockchain {
+	return r.bc
+}
+
+// AddBlock executes miniBlocks as the next block and returns it.
+// The blockchain is left unchanged if a miniblock fails, the error is then a *MiniBlockError.
+func (r *Rollup) AddBlock(miniBlocks []*types.MiniBlock, timestamp uint32) (*Block, error) {
+	if len(miniBlocks) == 0 {
+		return nil, fmt.Errorf("empty block")
+	}
+	if len(miniBlocks) > MaxMiniBlocks {
+		return nil, fmt.Errorf("block of %d miniblocks, at most %d", len(miniBlocks), MaxMiniBlocks)
+	}
+	blk := &Block{MiniBlocks: miniBlocks}
+	for i, miniBlock := range miniBlocks {
+		blk.PrevStateData = append(blk.PrevStateData, r.bc.GetStateData())
+		proofs, err := r.bc.AddMiniBlock(miniBlock)
+		if err != nil {
+			if revertErr := r.RevertTo(r.BlockNumber()); revertErr != nil {
+				return nil, revertErr
+			}
+			return nil, &MiniBlockError{Index: i, Err: err}
+		}
+		blk.ExecutionProofs = append(blk.ExecutionProofs, proofs)
+		blk.versions = append(blk.versions, r.bc.Version())
+	}
+	blk.Header = NewBlockHeader(r.BlockRoot(r.BlockNumber()), r.BlockNumber()+1, miniBlocks, timestamp)
+	r.blocks = append(r.blocks, blk)
+	r.persist(func(store Store) {
+		store.PutRecord(blockKey(blk.Header.BlockNumber), mustMarshal(newBlockRecord(blk)))
+	})
+	return blk, nil
+}
+
+// RevertTo drops every block after blockNumber and restores the blockchain as it was after that block,
+// as the contract does when a fraud proof against block blockNumber+1 succeeds.
+// The Blockchain returned by r.Blockchain() is restored in place.
+func (r *Rollup) RevertTo(blockNumber uint32) error {
+	if blockNumber > r.BlockNumber() {
+		return fmt.Errorf("block %d not found", blockNumber)
+	}
+	if blockNumber < r.finalized {
+		return fmt.Errorf("block %d is finalized", blockNumber+1)
+	}
+	if err := r.bc.RevertToVersion(r.versionAfter(blockNumber)); err != nil {
+		return err
+	}
+	dropped := r.BlockNumber()
+	r.blocks = r.blocks[:blockNumber]
+	r.persist(func(store Store) {
+		for n := blockNumber + 1; n <= dropped; n++ {
+			store.DeleteRecord(blockKey(n))
+		}
+	})
+	return nil
+}
+
+// Finalize marks the blocks up to blockNumber as final, e.g. once their challenge period is over,
+// and drops the versions of the blockchain before the end of block blockNumber.
+// RevertTo, StateAfterBlock and StateAfterMiniBlock then fail for an earlier block.
+func (r *Rollup) Finalize(blockNumber uint32) error {
+	if blockNumber > r.BlockNumber() {
+		return fmt.Errorf("block %d not found", blockNumber)
+	}
+	if blockNumber <= r.finalized {
+		return nil
+	}
+	version := r.versionAfter(blockNumber)
+	if version < r.bc.pruned {
+		return fmt.Errorf("version %d of block %d is pruned", version, blockNumber)
+	}
+	// the blocks are finalized in the store before the versions are dropped
+	r.finalized = blockNumber
+	r.persist(nil)
+	return r.bc.PruneHistory(version)
+}
+
+// versionAfter returns the version of the blockchain after block blockNumber, which must be at most r.BlockNumber()
+func (r *Rollup) versionAfter(blockNumber uint32) int {
+	if blockNumber == 0 {
+		return r.genesisVersion
+	}
+	blk := r.blocks[blockNumber-1]
+	return blk.versions[len(blk.versions)-1]
+}
+
+// StateAfterBlock returns the blockchain as it was after block blockNumber, or the genesis for 0.
+// It is only valid until the next block is added or reverted, see Blockchain.StateAt.
+func (r *Rollup) StateAfterBlock(blockNumber uint32) (*Blockchain, error) {
+	if blockNumber > r.BlockNumber() {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+	return r.bc.StateAt(r.versionAfter(blockNumber))
+}
+
+// StateAfterMiniBlock returns the blockchain as it was after a miniblock, see StateAfterBlock
+func (r *Rollup) StateAfterMiniBlock(blockNumber uint32, miniBlockIndex uint) (*Blockchain, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if miniBlockIndex >= uint(len(blk.MiniBlocks)) {
+		return nil, fmt.Errorf("block %d has no miniblock %d", blockNumber, miniBlockIndex)
+	}
+	return r.bc.StateAt(blk.versions[miniBlockIndex])
+}
+
+// BlockNumber returns the number of the last block
+func (r *Rollup) BlockNumber() uint32 {
+	return uint32(len(r.blocks))
+}
+
+// Block returns the block with the given number
+func (r *Rollup) Block(blockNumber uint32) (*Block, error) {
+	if blockNumber == 0 || blockNumber > r.BlockNumber() {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+	return r.blocks[blockNumber-1], nil
+}
+
+// BlockRoot returns the root of the block with the given number,
+// zero for block 0 or a block not submitted as the contract stores it
+func (r *Rollup) BlockRoot(blockNumber uint32) common.Hash {
+	if blockNumber == 0 || blockNumber > r.BlockNumber() {
+		return common.Hash{}
+	}
+	return r.blocks[blockNumber-1].Header.Root()
+}
+
+// PrevStateHashProof proves the state hash before a miniblock: the previous miniblock of the same block,
+// the final state of the previous block, or nothing for the first miniblock of block 1.
+func (r *Rollup) PrevStateHashProof(blockNumber uint32, miniBlockIndex uint) (hexutil.Bytes, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if miniBlockIndex >= uint(len(blk.MiniBlocks)) {
+		return nil, fmt.Errorf("block %d has no miniblock %d", blockNumber, miniBlockIndex)
+	}
+	if miniBlockIndex > 0 {
+		return proof.BuildPrevStateHashMiniBlockProof(blk.MiniBlocks, miniBlockIndex-1), nil
+	}
+	if blockNumber == 1 {
+		return hexutil.Bytes{}, nil
+	}
+	prevBlk := r.blocks[blockNumber-2]
+	return proof.BuildFinalStateHashProof(prevBlk.MiniBlocks, prevBlk.Header.Timestamp), nil
+}
+
+// MiniBlockProof proves a miniblock is included in its block
+func (r *Rollup) MiniBlockProof(blockNumber uint32, miniBlockIndex uint) (hexutil.Bytes, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if miniBlockIndex >= uint(len(blk.MiniBlocks)) {
+		return nil, fmt.Errorf("block %d has no miniblock %d", blockNumber, miniBlockIndex)
+	}
+	return proof.BuildMiniBlockProof(blk.MiniBlocks, miniBlockIndex, blk.Header.Timestamp), nil
+}
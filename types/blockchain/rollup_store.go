@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// rollupKey is the key of the record of the rollup of a blockchain in a store, see rollupRecord
+var rollupKey = []byte("rollup")
+
+// blockKey is the key of the record of a block of the rollup, see blockRecord
+func blockKey(blockNumber uint32) []byte {
+	return concat([]byte("block"), uint32Bytes(blockNumber))
+}
+
+// rollupRecord is the part of a Rollup which is not in its blocks
+type rollupRecord struct {
+	GenesisVersion int
+	BlockNumber    uint32
+	Finalized      uint32
+}
+
+// blockRecord is a Block in a store
+type blockRecord struct {
+	Header          *BlockHeader
+	MiniBlocks      []miniBlockRecord
+	PrevStateData   []*StateData
+	ExecutionProofs [][]hexutil.Bytes
+	Versions        []int
+}
+
+// miniBlockRecord is a miniblock with every field of its txs, the pubdata of a deposit only holds its DepositID
+type miniBlockRecord struct {
+	Commitment common.Hash
+	StateHash  common.Hash
+	Txs        []txRecord
+}
+
+// txRecord is a tx, its pubdata tells its type
+type txRecord struct {
+	Pubdata hexutil.Bytes
+	Tx      json.RawMessage
+}
+
+func newBlockRecord(blk *Block) *blockRecord {
+	record := &blockRecord{
+		Header:          blk.Header,
+		PrevStateData:   blk.PrevStateData,
+		ExecutionProofs: blk.ExecutionProofs,
+		Versions:        blk.versions,
+	}
+	for _, miniBlock := range blk.MiniBlocks {
+		miniBlockRecord := miniBlockRecord{Commitment: miniBlock.Commitment, StateHash: miniBlock.StateHash}
+		for _, tx := range miniBlock.Txs {
+			miniBlockRecord.Txs = append(miniBlockRecord.Txs, txRecord{Pubdata: tx.ToBytes(), Tx: mustMarshal(tx)})
+		}
+		record.MiniBlocks = append(record.MiniBlocks, miniBlockRecord)
+	}
+	return record
+}
+
+// block decodes the block of a blockchain whose params are params
+func (record *blockRecord) block(params *Params) (*Block, error) {
+	blk := &Block{
+		Header:          record.Header,
+		PrevStateData:   record.PrevStateData,
+		ExecutionProofs: record.ExecutionProofs,
+		versions:        record.Versions,
+	}
+	for _, stateData := range blk.PrevStateData {
+		stateData.hasher = params.hasher()
+	}
+	for i, miniBlockRecord := range record.MiniBlocks {
+		miniBlock := &types.MiniBlock{Commitment: miniBlockRecord.Commitment, StateHash: miniBlockRecord.StateHash}
+		for j, txRecord := range miniBlockRecord.Txs {
+			tx, _, err := types.DecodeTx(txRecord.Pubdata)
+			if err != nil {
+				return nil, fmt.Errorf("miniblock %d tx %d: %w", i, j, err)
+			}
+			if err := json.Unmarshal(txRecord.Tx, tx); err != nil {
+				return nil, fmt.Errorf("miniblock %d tx %d: %w", i, j, err)
+			}
+			miniBlock.Txs = append(miniBlock.Txs, tx)
+		}
+		blk.MiniBlocks = append(blk.MiniBlocks, miniBlock)
+	}
+	return blk, nil
+}
+
+// persist writes the record of r, with the records written by fn if not nil, at once
+// if its blockchain is in LevelDB, see Blockchain.persistent
+func (r *Rollup) persist(fn func(store Store)) {
+	if !r.bc.persistent() {
+		return
+	}
+	overlay := newOverlayStore(r.bc.store)
+	if fn != nil {
+		fn(overlay)
+	}
+	overlay.PutRecord(rollupKey, mustMarshal(&rollupRecord{
+		GenesisVersion: r.genesisVersion,
+		BlockNumber:    r.BlockNumber(),
+		Finalized:      r.finalized,
+	}))
+	r.bc.apply(overlay)
+}
+
+// OpenRollup returns the rollup persisted with the blockchain of store, see OpenBlockchain, as it was after its last block.
+// The blockchain is written before the blocks, the miniblocks of a block which was not recorded are reverted.
+func OpenRollup(store Store) (*Rollup, error) {
+	bc, err := OpenBlockchain(store)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := store.GetRecord(rollupKey)
+	if !ok {
+		return nil, fmt.Errorf("store holds no rollup")
+	}
+	var record rollupRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	r := &Rollup{bc: bc, genesisVersion: record.GenesisVersion, finalized: record.Finalized}
+	for blockNumber := uint32(1); blockNumber <= record.BlockNumber; blockNumber++ {
+		data, ok := store.GetRecord(blockKey(blockNumber))
+		if !ok {
+			return nil, fmt.Errorf("block %d not found", blockNumber)
+		}
+		var blkRecord blockRecord
+		if err := json.Unmarshal(data, &blkRecord); err != nil {
+			return nil, fmt.Errorf("block %d: %w", blockNumber, err)
+		}
+		blk, err := blkRecord.block(bc.params)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", blockNumber, err)
+		}
+		r.blocks = append(r.blocks, blk)
+	}
+	// the blocks reverted before the record was written are dropped
+	for r.BlockNumber() > r.finalized && r.versionAfter(r.BlockNumber()) > bc.Version() {
+		r.blocks = r.blocks[:len(r.blocks)-1]
+	}
+	if r.versionAfter(r.BlockNumber()) != bc.Version() {
+		if err := r.RevertTo(r.BlockNumber()); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
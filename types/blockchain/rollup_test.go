@@ -0,0 +1,317 @@
+package blockchain
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+func TestRollup_AddBlock(t *testing.T) {
+	bc := NewBlockchain(&Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}, nil)
+	genesisStateData := bc.GetStateData()
+	rollup := NewRollup(bc)
+	require.Equal(t, common.Hash{}, rollup.BlockRoot(0))
+
+	block1, err := rollup.AddBlock([]*types.MiniBlock{
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(1000)}}},
+	}, 1600661872)
+	require.NoError(t, err)
+	block2, err := rollup.AddBlock([]*types.MiniBlock{
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(2000)}}},
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 0, TokenID: 1, Amount: big.NewInt(3000)}}},
+	}, 1600661900)
+	require.NoError(t, err)
+
+	require.Equal(t, uint32(2), rollup.BlockNumber())
+	require.Equal(t, uint32(1), block1.Header.BlockNumber)
+	require.Equal(t, common.Hash{}, block1.Header.PrevBlockRoot)
+	require.Equal(t, rollup.BlockRoot(1), block2.Header.PrevBlockRoot)
+	require.NotEqual(t, rollup.BlockRoot(1), rollup.BlockRoot(2))
+	require.Equal(t, uint8(2), block2.Header.NumMiniBlocks)
+	require.Equal(t, bc.GetStateData().Hash(), block2.Header.StateHash)
+
+	require.Equal(t, genesisStateData, block1.PrevStateData[0])
+	require.Equal(t, block1.MiniBlocks[0].StateHash, block2.PrevStateData[0].Hash())
+	require.Equal(t, block2.MiniBlocks[0].StateHash, block2.PrevStateData[1].Hash())
+
+	for _, c := range []struct {
+		blockNumber    uint32
+		miniBlockIndex uint
+		expected       hexutil.Bytes
+	}{
+		{1, 0, hexutil.Bytes{}},
+		{2, 0, proof.BuildFinalStateHashProof(block1.MiniBlocks, 1600661872)},
+		{2, 1, proof.BuildPrevStateHashMiniBlockProof(block2.MiniBlocks, 0)},
+	} {
+		prevStateHashProof, err := rollup.PrevStateHashProof(c.blockNumber, c.miniBlockIndex)
+		require.NoError(t, err)
+		require.Equal(t, c.expected, prevStateHashProof)
+	}
+	miniBlockProof, err := rollup.MiniBlockProof(2, 1)
+	require.NoError(t, err)
+	require.Equal(t, proof.BuildMiniBlockProof(block2.MiniBlocks, 1, 1600661900), miniBlockProof)
+
+	// the block number and miniblock index may come from a submitted block
+	_, err = rollup.Block(3)
+	require.Error(t, err)
+	require.Equal(t, common.Hash{}, rollup.BlockRoot(3))
+	_, err = rollup.MiniBlockProof(2, 2)
+	require.Error(t, err)
+	_, err = rollup.PrevStateHashProof(0, 0)
+	require.Error(t, err)
+
+	// NumMiniBlocks of the header is an uint8
+	_, err = rollup.AddBlock(make([]*types.MiniBlock, MaxMiniBlocks+1), 1600661900)
+	require.Error(t, err)
+	require.Equal(t, uint32(2), rollup.BlockNumber())
+	submitted := &SubmittedBlock{BlockNumber: 3, MiniBlocks: make([]hexutil.Bytes, MaxMiniBlocks+1)}
+	_, err = submitted.Root(rollup.BlockRoot(2))
+	require.Error(t, err)
+}
+
+func TestRollup_RevertTo(t *testing.T) {
+	bc := NewBlockchain(&Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}, nil)
+	genesisStateData := bc.GetStateData()
+	rollup := NewRollup(bc)
+
+	deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}
+	_, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit}}}, 1600661872)
+	require.NoError(t, err)
+	stateData1, root1 := bc.GetStateData(), rollup.BlockRoot(1)
+
+	withdraw := &types.WithdrawOp{AccountID: 8, TokenID: 2, Amount: types.PackedAmount{Mantisa: 4, Exp: 7}, Fee: types.PackedFee{Mantisa: 1, Exp: 2}}
+	block2, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{withdraw}}}, 1600661872)
+	require.NoError(t, err)
+	_, err = rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(1)}}}}, 1600661872)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), bc.numDeposit)
+	require.Equal(t, uint(1), bc.numWithdraw)
+
+	require.NoError(t, rollup.RevertTo(1))
+	require.Equal(t, uint32(1), rollup.BlockNumber())
+	require.Equal(t, stateData1, bc.GetStateData())
+	require.Equal(t, root1, rollup.BlockRoot(1))
+	require.Equal(t, uint64(1), bc.numDeposit)
+	require.Equal(t, uint(0), bc.numWithdraw)
+
+	// resubmitting the same block gives the same root
+	_, err = rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{withdraw}}}, 1600661872)
+	require.NoError(t, err)
+	require.Equal(t, block2.Header.Root(), rollup.BlockRoot(2))
+	require.Equal(t, uint(0), withdraw.WithdrawID)
+
+	require.NoError(t, rollup.RevertTo(0))
+	require.Equal(t, uint32(0), rollup.BlockNumber())
+	require.Equal(t, genesisStateData, bc.GetStateData())
+	require.Error(t, rollup.RevertTo(1))
+}
+
+func TestRollup_Finalize(t *testing.T) {
+	bc := NewBlockchain(&Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}, nil)
+	rollup := NewRollup(bc)
+	for i := 0; i < 3; i++ {
+		deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(int64(i + 1))}
+		_, err := rollup.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{deposit}}, {}}, 1600661872)
+		require.NoError(t, err)
+	}
+	state2, err := rollup.StateAfterBlock(2)
+	require.NoError(t, err)
+	stateData2 := state2.GetStateData()
+
+	require.Error(t, rollup.Finalize(4))
+	require.NoError(t, rollup.Finalize(2))
+	require.Equal(t, 6, bc.Version())
+	require.Len(t, bc.history, 2)
+	_, err = rollup.StateAfterBlock(1)
+	require.Error(t, err)
+	_, err = rollup.StateAfterMiniBlock(2, 0)
+	require.Error(t, err)
+	require.Error(t, rollup.RevertTo(1))
+	// finalizing an earlier block keeps the versions
+	require.NoError(t, rollup.Finalize(1))
+	require.Len(t, bc.history, 2)
+
+	require.NoError(t, rollup.RevertTo(2))
+	require.Equal(t, uint32(2), rollup.BlockNumber())
+	require.Equal(t, stateData2, bc.GetStateData())
+	require.Equal(t, 4, bc.Version())
+	require.Error(t, bc.PruneHistory(3))
+}
+
+func TestRollup_StateAfter(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}
+	bc := NewBlockchain(genesis, nil)
+	rollup := NewRollup(bc)
+	blocks := [][]*types.MiniBlock{
+		{{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}}}},
+		{
+			{Txs: []types.Transaction{&types.WithdrawOp{AccountID: 8, TokenID: 2, Amount: types.PackedAmount{Mantisa: 4, Exp: 7}, Fee: types.PackedFee{Mantisa: 1, Exp: 2}}}},
+			{Txs: []types.Transaction{&types.DepositOp{AccountID: 0, TokenID: 1, Amount: big.NewInt(3000)}}},
+		},
+	}
+	// the same miniblocks executed one by one
+	reference := NewBlockchain(genesis, nil)
+	genesisDump, err := reference.Export()
+	require.NoError(t, err)
+	var dumps [][]*StateDump
+	var commitmentProofs [][]hexutil.Bytes
+	for _, miniBlocks := range blocks {
+		var blockDumps []*StateDump
+		var blockProofs []hexutil.Bytes
+		for _, miniBlock := range miniBlocks {
+			miniBlock := *miniBlock
+			_, err := reference.AddMiniBlock(&miniBlock)
+			require.NoError(t, err)
+			dump, err := reference.Export()
+			require.NoError(t, err)
+			blockDumps = append(blockDumps, dump)
+			blockProofs = append(blockProofs, reference.BuildCommitmentProof(&miniBlock))
+		}
+		dumps = append(dumps, blockDumps)
+		commitmentProofs = append(commitmentProofs, blockProofs)
+		_, err := rollup.AddBlock(miniBlocks, 1600661872)
+		require.NoError(t, err)
+	}
+	lastDump, err := bc.Export()
+	require.NoError(t, err)
+
+	state, err := rollup.StateAfterBlock(0)
+	require.NoError(t, err)
+	dump, err := state.Export()
+	require.NoError(t, err)
+	require.Equal(t, genesisDump, dump)
+	for i, miniBlocks := range blocks {
+		for j, miniBlock := range miniBlocks {
+			state, err := rollup.StateAfterMiniBlock(uint32(i+1), uint(j))
+			require.NoError(t, err)
+			require.Equal(t, miniBlock.StateHash, state.GetStateData().Hash())
+			dump, err := state.Export()
+			require.NoError(t, err)
+			require.Equal(t, dumps[i][j], dump)
+			require.Equal(t, commitmentProofs[i][j], state.BuildCommitmentProof(miniBlock))
+		}
+	}
+
+	// executing ahead of an earlier state leaves bc unchanged
+	state, err = rollup.StateAfterBlock(1)
+	require.NoError(t, err)
+	_, err = state.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 3, Amount: big.NewInt(1)}}})
+	require.NoError(t, err)
+	require.Equal(t, 2, state.Version())
+	dump, err = bc.Export()
+	require.NoError(t, err)
+	require.Equal(t, lastDump, dump)
+
+	_, err = bc.StateAt(bc.Version() + 1)
+	require.Error(t, err)
+	_, err = rollup.StateAfterMiniBlock(2, 2)
+	require.Error(t, err)
+	_, err = rollup.StateAfterBlock(3)
+	require.Error(t, err)
+}
+
+func TestOpenRollup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "l2-rollup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}
+	deposit := func(amount int64) *types.MiniBlock {
+		return &types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(amount)}}}
+	}
+	reopen := func(store Store) (Store, *Rollup) {
+		require.NoError(t, store.Close())
+		store, err := NewLevelDBStore(dir)
+		require.NoError(t, err)
+		rollup, err := OpenRollup(store)
+		require.NoError(t, err)
+		return store, rollup
+	}
+
+	store, err := NewLevelDBStore(dir)
+	require.NoError(t, err)
+	_, err = OpenRollup(store)
+	require.Error(t, err)
+	bc, err := NewBlockchainWithStore(store, genesis, nil)
+	require.NoError(t, err)
+	rollup := NewRollup(bc)
+	reference := NewRollup(NewBlockchain(genesis, nil))
+	for i := int64(0); i < 3; i++ {
+		_, err := rollup.AddBlock([]*types.MiniBlock{deposit(i + 1), {}}, 1600661872)
+		require.NoError(t, err)
+		_, err = reference.AddBlock([]*types.MiniBlock{deposit(i + 1), {}}, 1600661872)
+		require.NoError(t, err)
+	}
+	require.NoError(t, rollup.Finalize(1))
+	require.NoError(t, reference.Finalize(1))
+	require.NoError(t, rollup.RevertTo(2))
+	require.NoError(t, reference.RevertTo(2))
+
+	store, rollup = reopen(store)
+	require.Equal(t, reference.BlockNumber(), rollup.BlockNumber())
+	require.Equal(t, reference.Blockchain().Version(), rollup.Blockchain().Version())
+	for blockNumber := uint32(1); blockNumber <= reference.BlockNumber(); blockNumber++ {
+		require.Equal(t, reference.BlockRoot(blockNumber), rollup.BlockRoot(blockNumber))
+		expected, err := reference.Block(blockNumber)
+		require.NoError(t, err)
+		blk, err := rollup.Block(blockNumber)
+		require.NoError(t, err)
+		require.Equal(t, expected, blk)
+	}
+	// the reopened rollup reverts to the blocks persisted, but not to a finalized one
+	require.Error(t, rollup.RevertTo(0))
+	require.NoError(t, rollup.RevertTo(1))
+	require.NoError(t, reference.RevertTo(1))
+	require.Equal(t, reference.Blockchain().GetStateData(), rollup.Blockchain().GetStateData())
+	_, err = rollup.AddBlock([]*types.MiniBlock{deposit(9)}, 1600661900)
+	require.NoError(t, err)
+	_, err = reference.AddBlock([]*types.MiniBlock{deposit(9)}, 1600661900)
+	require.NoError(t, err)
+
+	// a miniblock executed without its block being recorded is reverted
+	_, err = rollup.Blockchain().AddMiniBlock(deposit(5))
+	require.NoError(t, err)
+	store, rollup = reopen(store)
+	require.Equal(t, uint32(2), rollup.BlockNumber())
+	require.Equal(t, reference.BlockRoot(2), rollup.BlockRoot(2))
+	require.Equal(t, reference.Blockchain().GetStateData(), rollup.Blockchain().GetStateData())
+	require.NoError(t, store.Close())
+}
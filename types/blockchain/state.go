@@ -2,49 +2,112 @@ package blockchain
 
 import (
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 
 	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
 )
 
+// State is the accounts of a Blockchain and their tree, in its Store
 type State struct {
-	accounts map[uint32]*Account
-	tree     *MerkleTree
+	store  Store
+	params *Params
+	tree   *MerkleTree
 }
 
-func NewState() *State {
+// NewState returns an empty state in memory
+func NewState(params *Params) *State {
+	return newState(NewMemoryStore(), params)
+}
+
+func newState(store Store, params *Params) *State {
 	return &State{
-		accounts: make(map[uint32]*Account),
-		tree:     NewTree(StateTreeDeep),
+		store:  store,
+		params: params,
+		tree:   NewTreeWithStore(params.StateTreeDeep, store.Tree(StateTreeID), params.hasher()),
+	}
+}
+
+func (s *State) account(accountID uint32, info *AccountInfo) *Account {
+	return &Account{
+		pubKey:          info.PubKey,
+		withdrawTo:      info.WithdrawTo,
+		tree:            NewTreeWithStore(s.params.AccountTreeDeep, s.store.Tree(AccountTreeID(accountID)), s.params.hasher()),
+		isConfirmedExit: info.IsConfirmedExit,
 	}
 }
 
+// getAccount returns the account, or nil if it does not exist.
+// The balances are updated in the store, other changes must be put back with putAccount.
+func (s *State) getAccount(accountID uint32) *Account {
+	info, ok := s.store.GetAccount(accountID)
+	if !ok {
+		return nil
+	}
+	return s.account(accountID, info)
+}
+
+// newAccount creates the account without balances
+func (s *State) newAccount(accountID uint32, pubKey hexutil.Bytes, withdrawTo common.Address) *Account {
+	account := s.account(accountID, &AccountInfo{PubKey: pubKey, WithdrawTo: withdrawTo})
+	s.putAccount(accountID, account)
+	return account
+}
+
+func (s *State) putAccount(accountID uint32, account *Account) {
+	s.store.PutAccount(accountID, &AccountInfo{
+		PubKey:          account.pubKey,
+		WithdrawTo:      account.withdrawTo,
+		IsConfirmedExit: account.isConfirmedExit,
+	})
+}
+
+func (s *State) forEachAccount(fn func(accountID uint32, account *Account)) {
+	s.store.ForEachAccount(func(accountID uint32, info *AccountInfo) {
+		fn(accountID, s.account(accountID, info))
+	})
+}
+
 type StateData struct {
 	StateRoot  common.Hash
 	LOORoot    common.Hash
 	AccountMax uint32
 	LOOMax     uint64
+	hasher     hasher.Hasher
 }
 
+// Bytes returns the packed encoding of the state data, which is the preimage of Hash
+func (sData *StateData) Bytes() []byte {
+	var out []byte
+	out = append(out, sData.StateRoot.Bytes()...)
+	out = append(out, sData.LOORoot.Bytes()...)
+	out = append(out, util.Uint32ToBytes(sData.AccountMax)...)
+	out = append(out, util.Uint48ToBytes(sData.LOOMax)...)
+	return out
+}
+
+// Hash returns the hash of the state data by the hasher of the blockchain it was taken from.
+// A state data decoded from JSON or calldata has no hasher, Hash panics for it: see HashWith.
 func (sData *StateData) Hash() common.Hash {
-	return crypto.Keccak256Hash(
-		sData.StateRoot.Bytes(), sData.LOORoot.Bytes(),
-		util.Uint32ToBytes(sData.AccountMax), util.Uint48ToBytes(sData.LOOMax),
-	)
+	if sData.hasher == nil {
+		panic("state data has no hasher, hash it with HashWith")
+	}
+	return sData.HashWith(sData.hasher)
+}
+
+// HashWith returns the hash of the state data by h, e.g. the hasher named by Params.Hasher
+func (sData *StateData) HashWith(h hasher.Hasher) common.Hash {
+	return h.Hash(sData.Bytes())
 }
 
-func NewStateFromAlloc(acountAlloc map[uint32]GenesisAccount) *State{
-	var state = NewState();
+func NewStateFromAlloc(acountAlloc map[uint32]GenesisAccount, params *Params) *State{
+	var state = NewState(params);
 	for accountID, accountAlloc := range acountAlloc {
-		account := NewAccount(accountAlloc.Pubkey, accountAlloc.Address)
+		account := state.newAccount(accountID, accountAlloc.Pubkey, accountAlloc.Address)
 		for tokenID, tokenAmount := range accountAlloc.Tokens {
 			account.Update(tokenID, tokenAmount)
 		}
-		state.accounts[accountID] = account
-		accountHash := crypto.Keccak256Hash(
-			account.tree.RootHash().Bytes(),
-			account.GetPubAccountHash().Bytes(),
-		)
+		accountHash := params.hasher().HashPair(account.tree.RootHash(), account.GetPubAccountHash())
 		state.tree.Update(uint64(accountID), accountHash)
 	}
 	return state
@@ -25,7 +25,7 @@ func TestStateRoot(t *testing.T) {
 		}
 	)
 
-	state := NewStateFromAlloc(accountAlloc);
+	state := NewStateFromAlloc(accountAlloc, DefaultParams());
 	stateRoot:= state.tree.RootHash();
 	log.Printf("root tree is %s", stateRoot.String())
 
@@ -0,0 +1,294 @@
+package blockchain
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// TreeKind is the kind of a merkle tree of a Store
+type TreeKind byte
+
+const (
+	StateTree TreeKind = iota + 1
+	LOOTree
+	AccountTree
+)
+
+// TreeID names a merkle tree of a Store, AccountID is only set for an AccountTree
+type TreeID struct {
+	Kind      TreeKind
+	AccountID uint32
+}
+
+var (
+	StateTreeID = TreeID{Kind: StateTree}
+	LOOTreeID   = TreeID{Kind: LOOTree}
+)
+
+func AccountTreeID(accountID uint32) TreeID {
+	return TreeID{Kind: AccountTree, AccountID: accountID}
+}
+
+// AccountInfo is an account without its balances, which are in its AccountTree
+type AccountInfo struct {
+	PubKey          hexutil.Bytes
+	WithdrawTo      common.Address
+	IsConfirmedExit bool
+}
+
+// StoreMeta is the part of a Blockchain which is not in its trees, accounts and LOOs
+type StoreMeta struct {
+	Params      *Params
+	AccountMax  uint32
+	LOOMax      uint64
+	NumDeposit  uint64
+	NumWithdraw uint
+	// Pruned is the number of versions dropped by Blockchain.PruneHistory
+	Pruned int `json:",omitempty"`
+}
+
+// NodeStore holds the nodes of a MerkleTree which are not the root of an empty subtree
+type NodeStore interface {
+	GetNode(level uint, index uint64) (common.Hash, bool)
+	PutNode(level uint, index uint64, value common.Hash)
+	DeleteNode(level uint, index uint64)
+	// ForEachNode calls fn on every node of level
+	ForEachNode(level uint, fn func(index uint64, value common.Hash))
+}
+
+// Store holds the trees, the accounts, the used orders and the LOOs of a Blockchain,
+// and the records persisting its versions and the blocks of its Rollup in a LevelDB store.
+// Getters return copies, a changed account or LOO must be put back.
+// Stores panic on I/O errors, like the trees on invalid keys.
+type Store interface {
+	Tree(id TreeID) NodeStore
+
+	GetAccount(accountID uint32) (*AccountInfo, bool)
+	PutAccount(accountID uint32, info *AccountInfo)
+	DeleteAccount(accountID uint32)
+	ForEachAccount(fn func(accountID uint32, info *AccountInfo))
+
+	HasUsedOrder(accountID uint32, orderHash common.Hash) bool
+	PutUsedOrder(accountID uint32, orderHash common.Hash)
+	DeleteUsedOrder(accountID uint32, orderHash common.Hash)
+	ForEachUsedOrder(accountID uint32, fn func(orderHash common.Hash))
+
+	GetLOO(looID uint64) (*types.LeftOverOrder, bool)
+	PutLOO(looID uint64, loo *types.LeftOverOrder)
+	DeleteLOO(looID uint64)
+	ForEachLOO(fn func(looID uint64, loo *types.LeftOverOrder))
+
+	GetMeta() (*StoreMeta, bool)
+	PutMeta(meta *StoreMeta)
+
+	GetRecord(key []byte) ([]byte, bool)
+	PutRecord(key []byte, value []byte)
+	DeleteRecord(key []byte)
+
+	Close() error
+}
+
+func (info *AccountInfo) clone() *AccountInfo {
+	out := *info
+	out.PubKey = append(hexutil.Bytes{}, info.PubKey...)
+	return &out
+}
+
+func (meta *StoreMeta) clone() *StoreMeta {
+	out := *meta
+	if meta.Params != nil {
+		params := *meta.Params
+		out.Params = &params
+	}
+	return &out
+}
+
+// memoryNodeStore holds the nodes of each level in a map
+type memoryNodeStore struct {
+	levels []map[uint64]common.Hash
+}
+
+func (s *memoryNodeStore) GetNode(level uint, index uint64) (common.Hash, bool) {
+	if level >= uint(len(s.levels)) {
+		return common.Hash{}, false
+	}
+	v, ok := s.levels[level][index]
+	return v, ok
+}
+
+func (s *memoryNodeStore) PutNode(level uint, index uint64, value common.Hash) {
+	for level >= uint(len(s.levels)) {
+		s.levels = append(s.levels, make(map[uint64]common.Hash))
+	}
+	s.levels[level][index] = value
+}
+
+func (s *memoryNodeStore) DeleteNode(level uint, index uint64) {
+	if level < uint(len(s.levels)) {
+		delete(s.levels[level], index)
+	}
+}
+
+func (s *memoryNodeStore) ForEachNode(level uint, fn func(index uint64, value common.Hash)) {
+	if level >= uint(len(s.levels)) {
+		return
+	}
+	for index, value := range s.levels[level] {
+		fn(index, value)
+	}
+}
+
+type memoryStore struct {
+	trees      map[TreeID]*memoryNodeStore
+	accounts   map[uint32]*AccountInfo
+	usedOrders map[uint32]map[common.Hash]struct{}
+	loos       map[uint64]*types.LeftOverOrder
+	meta       *StoreMeta
+	records    map[string][]byte
+}
+
+// memoryTree is a tree of a memoryStore, its nodes are only added to the store by the first write
+// so that reading the store does not change it
+type memoryTree struct {
+	store *memoryStore
+	id    TreeID
+}
+
+func (t memoryTree) GetNode(level uint, index uint64) (common.Hash, bool) {
+	if nodes, ok := t.store.trees[t.id]; ok {
+		return nodes.GetNode(level, index)
+	}
+	return common.Hash{}, false
+}
+
+func (t memoryTree) PutNode(level uint, index uint64, value common.Hash) {
+	nodes, ok := t.store.trees[t.id]
+	if !ok {
+		nodes = &memoryNodeStore{}
+		t.store.trees[t.id] = nodes
+	}
+	nodes.PutNode(level, index, value)
+}
+
+func (t memoryTree) DeleteNode(level uint, index uint64) {
+	if nodes, ok := t.store.trees[t.id]; ok {
+		nodes.DeleteNode(level, index)
+	}
+}
+
+func (t memoryTree) ForEachNode(level uint, fn func(index uint64, value common.Hash)) {
+	if nodes, ok := t.store.trees[t.id]; ok {
+		nodes.ForEachNode(level, fn)
+	}
+}
+
+// NewMemoryStore returns a Store in maps
+func NewMemoryStore() Store {
+	return &memoryStore{
+		trees:      make(map[TreeID]*memoryNodeStore),
+		accounts:   make(map[uint32]*AccountInfo),
+		usedOrders: make(map[uint32]map[common.Hash]struct{}),
+		loos:       make(map[uint64]*types.LeftOverOrder),
+		records:    make(map[string][]byte),
+	}
+}
+
+func (s *memoryStore) Tree(id TreeID) NodeStore {
+	return memoryTree{store: s, id: id}
+}
+
+func (s *memoryStore) GetAccount(accountID uint32) (*AccountInfo, bool) {
+	info, ok := s.accounts[accountID]
+	if !ok {
+		return nil, false
+	}
+	return info.clone(), true
+}
+
+func (s *memoryStore) PutAccount(accountID uint32, info *AccountInfo) {
+	s.accounts[accountID] = info.clone()
+}
+
+func (s *memoryStore) DeleteAccount(accountID uint32) {
+	delete(s.accounts, accountID)
+}
+
+func (s *memoryStore) ForEachAccount(fn func(accountID uint32, info *AccountInfo)) {
+	for accountID, info := range s.accounts {
+		fn(accountID, info.clone())
+	}
+}
+
+func (s *memoryStore) HasUsedOrder(accountID uint32, orderHash common.Hash) bool {
+	_, ok := s.usedOrders[accountID][orderHash]
+	return ok
+}
+
+func (s *memoryStore) PutUsedOrder(accountID uint32, orderHash common.Hash) {
+	if s.usedOrders[accountID] == nil {
+		s.usedOrders[accountID] = make(map[common.Hash]struct{})
+	}
+	s.usedOrders[accountID][orderHash] = struct{}{}
+}
+
+func (s *memoryStore) DeleteUsedOrder(accountID uint32, orderHash common.Hash) {
+	delete(s.usedOrders[accountID], orderHash)
+}
+
+func (s *memoryStore) ForEachUsedOrder(accountID uint32, fn func(orderHash common.Hash)) {
+	for orderHash := range s.usedOrders[accountID] {
+		fn(orderHash)
+	}
+}
+
+func (s *memoryStore) GetLOO(looID uint64) (*types.LeftOverOrder, bool) {
+	loo, ok := s.loos[looID]
+	if !ok {
+		return nil, false
+	}
+	return loo.Clone(), true
+}
+
+func (s *memoryStore) PutLOO(looID uint64, loo *types.LeftOverOrder) {
+	s.loos[looID] = loo.Clone()
+}
+
+func (s *memoryStore) DeleteLOO(looID uint64) {
+	delete(s.loos, looID)
+}
+
+func (s *memoryStore) ForEachLOO(fn func(looID uint64, loo *types.LeftOverOrder)) {
+	for looID, loo := range s.loos {
+		fn(looID, loo.Clone())
+	}
+}
+
+func (s *memoryStore) GetMeta() (*StoreMeta, bool) {
+	if s.meta == nil {
+		return nil, false
+	}
+	return s.meta.clone(), true
+}
+
+func (s *memoryStore) PutMeta(meta *StoreMeta) {
+	s.meta = meta.clone()
+}
+
+func (s *memoryStore) GetRecord(key []byte) ([]byte, bool) {
+	value, ok := s.records[string(key)]
+	return append([]byte{}, value...), ok
+}
+
+func (s *memoryStore) PutRecord(key []byte, value []byte) {
+	s.records[string(key)] = append([]byte{}, value...)
+}
+
+func (s *memoryStore) DeleteRecord(key []byte) {
+	delete(s.records, string(key))
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,184 @@
+package blockchain
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+func TestLevelDBStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "l2-store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			1: {Tokens: map[uint16]*big.Int{0: big.NewInt(50), 1: big.NewInt(1000)}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			2: {Tokens: map[uint16]*big.Int{2: big.NewInt(1000)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+			3: {Tokens: map[uint16]*big.Int{1: big.NewInt(5)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+		},
+		AccountMax: 3,
+		LooAlloc: map[uint64]*types.LeftOverOrder{
+			0: {AccountID: 2, SrcToken: 2, DestToken: 1, Amount: big.NewInt(10), Fee: big.NewInt(0), Rate: big.NewInt(1e18), ValidSince: 1600661872, ValidPeriod: 86400},
+		},
+		LooMax: 1,
+	}
+	miniBlocks := func() []*types.MiniBlock {
+		return []*types.MiniBlock{
+			{Txs: []types.Transaction{
+				&types.Settlement1{
+					OpType:   types.SettlementOp12,
+					Token1:   1,
+					Token2:   2,
+					Account1: 1,
+					Account2: 2,
+					Rate1:    types.PackedAmount{Mantisa: 1, Exp: 18},
+					Rate2:    types.PackedAmount{Mantisa: 1, Exp: 18},
+					Amount1:  types.PackedAmount{Mantisa: 100},
+					Amount2:  types.PackedAmount{Mantisa: 40},
+				},
+				&types.ExitOp{AccountID: 3},
+			}},
+			{Txs: []types.Transaction{
+				&types.Settlement2{OpType: types.SettlementOp21, LooID1: 0, AccountID2: 1, Amount2: types.PackedAmount{Mantisa: 10},
+					Rate2: types.PackedAmount{Mantisa: 1, Exp: 18}, ValidSince2: 1600661872},
+				&types.WithdrawOp{AccountID: 1, TokenID: 2, Amount: types.PackedAmount{Mantisa: 5}, Fee: types.PackedFee{Mantisa: 1}},
+			}},
+			{Txs: []types.Transaction{
+				&types.DepositToNewOp{PubKey: make([]byte, types.PubKeyLength), WithdrawTo: common.HexToAddress("0x1"), TokenID: 1, Amount: big.NewInt(3)},
+				&types.DepositOp{AccountID: 2, TokenID: 1, Amount: big.NewInt(7)},
+			}},
+		}
+	}
+
+	bc := NewBlockchain(genesis, nil)
+	var proofs [][]byte
+	for _, miniBlock := range miniBlocks() {
+		miniBlockProofs, err := bc.AddMiniBlock(miniBlock)
+		require.NoError(t, err)
+		for _, proof := range miniBlockProofs {
+			proofs = append(proofs, proof)
+		}
+	}
+	want, err := bc.Export()
+	require.NoError(t, err)
+	require.Len(t, want.LOOs, 2)
+	require.Contains(t, want.Accounts, uint32(4))
+
+	store, err := NewLevelDBStore(dir)
+	require.NoError(t, err)
+	diskBC, err := NewBlockchainWithStore(store, genesis, nil)
+	require.NoError(t, err)
+	_, err = NewBlockchainWithStore(store, genesis, nil)
+	require.Error(t, err)
+
+	var diskProofs [][]byte
+	for i, miniBlock := range miniBlocks() {
+		if i == 1 {
+			// a failing miniblock leaves the store unchanged
+			before, err := diskBC.Export()
+			require.NoError(t, err)
+			_, err = diskBC.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{
+				&types.DepositOp{AccountID: 2, TokenID: 1, Amount: big.NewInt(7)},
+				&types.WithdrawOp{AccountID: 3, TokenID: 1, Amount: types.PackedAmount{Mantisa: 6}},
+			}})
+			require.Error(t, err)
+			after, err := diskBC.Export()
+			require.NoError(t, err)
+			require.Equal(t, before, after)
+
+			// the blockchain continues after reopening the store
+			require.NoError(t, store.Close())
+			store, err = NewLevelDBStore(dir)
+			require.NoError(t, err)
+			diskBC, err = OpenBlockchain(store)
+			require.NoError(t, err)
+		}
+		miniBlockProofs, err := diskBC.AddMiniBlock(miniBlock)
+		require.NoError(t, err)
+		for _, proof := range miniBlockProofs {
+			diskProofs = append(diskProofs, proof)
+		}
+	}
+	require.Equal(t, proofs, diskProofs)
+	got, err := diskBC.Export()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.Equal(t, bc.GetStateData(), diskBC.GetStateData())
+
+	// a clone reads a snapshot of the store, it does not see the later miniblocks and keeps its own in memory
+	deposit := func(amount int64) *types.MiniBlock {
+		return &types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 2, TokenID: 1, Amount: big.NewInt(amount)}}}
+	}
+	clone := diskBC.Clone()
+	_, err = diskBC.AddMiniBlock(deposit(7))
+	require.NoError(t, err)
+	got, err = clone.Export()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	_, err = clone.AddMiniBlock(deposit(9))
+	require.NoError(t, err)
+	forked := bc.Clone()
+	_, err = forked.AddMiniBlock(deposit(9))
+	require.NoError(t, err)
+	want, err = forked.Export()
+	require.NoError(t, err)
+	got, err = clone.Export()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.NoError(t, clone.RevertToVersion(3))
+	require.NoError(t, diskBC.RevertToVersion(3))
+	require.Equal(t, bc.GetStateData(), diskBC.GetStateData())
+	require.Equal(t, bc.GetStateData(), clone.GetStateData())
+
+	// the versions are persisted, the pruned ones are dropped
+	require.NoError(t, diskBC.PruneHistory(1))
+	require.NoError(t, store.Close())
+	store, err = NewLevelDBStore(dir)
+	require.NoError(t, err)
+	diskBC, err = OpenBlockchain(store)
+	require.NoError(t, err)
+	require.Equal(t, 3, diskBC.Version())
+	require.Error(t, diskBC.RevertToVersion(0))
+	state, err := bc.StateAt(1)
+	require.NoError(t, err)
+	want, err = state.Export()
+	require.NoError(t, err)
+	require.NoError(t, diskBC.RevertToVersion(1))
+	require.NoError(t, store.Close())
+	store, err = NewLevelDBStore(dir)
+	require.NoError(t, err)
+	diskBC, err = OpenBlockchain(store)
+	require.NoError(t, err)
+	require.Equal(t, 1, diskBC.Version())
+	got, err = diskBC.Export()
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.NoError(t, store.Close())
+}
+
+func TestMemoryStore_Tree(t *testing.T) {
+	store := NewMemoryStore().(*memoryStore)
+	tree := store.Tree(AccountTreeID(1))
+	// reading a tree does not write the store, which may be read from several goroutines
+	_, ok := tree.GetNode(0, 0)
+	require.False(t, ok)
+	tree.ForEachNode(0, func(uint64, common.Hash) { t.Fatal("empty tree") })
+	require.Empty(t, store.trees)
+
+	other := store.Tree(AccountTreeID(1))
+	tree.PutNode(0, 3, common.HexToHash("0x1"))
+	value, ok := other.GetNode(0, 3)
+	require.True(t, ok)
+	require.Equal(t, common.HexToHash("0x1"), value)
+	other.DeleteNode(0, 3)
+	_, ok = tree.GetNode(0, 3)
+	require.False(t, ok)
+}
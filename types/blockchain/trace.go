@@ -0,0 +1,218 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+// Tracer is called after every tx of a miniblock executed by a Blockchain, see SetTracer
+type Tracer interface {
+	CaptureTx(trace *TxTrace)
+}
+
+// TxTrace is the execution of a tx, the total fee of a miniblock is traced as a last tx of op totalFee
+type TxTrace struct {
+	// MiniBlock is the version of the blockchain before the miniblock, see Blockchain.Version
+	MiniBlock int
+	Index     int
+	Op        string
+	Balances  []AccountBalanceChange `json:",omitempty"`
+	LOOs      []LOODiff              `json:",omitempty"`
+	// Values are the amounts and fees computed by the tx
+	Values []TraceValue `json:",omitempty"`
+	// Fields are the fields of the execution proof of the tx, in order
+	Fields []ProofField `json:",omitempty"`
+	Fee    *big.Int     `json:",omitempty"`
+	// Error is set if the tx reverts the miniblock
+	Error string `json:",omitempty"`
+}
+
+// AccountBalanceChange is a balance of an account updated by a tx
+type AccountBalanceChange struct {
+	AccountID uint32
+	BalanceChange
+}
+
+// TraceValue is a named amount computed by a tx
+type TraceValue struct {
+	Name  string
+	Value *big.Int
+}
+
+// ProofField is a field of an execution proof at Offset bytes
+type ProofField struct {
+	Name   string
+	Offset int
+	Size   int
+}
+
+// SetTracer sets the tracer of the next miniblocks, nil stops tracing. It is kept by the clones of bc.
+func (bc *Blockchain) SetTracer(tracer Tracer) {
+	bc.tracer = tracer
+}
+
+func opName(tx types.Transaction) string {
+	switch tx.(type) {
+	case *types.DepositOp:
+		return "deposit"
+	case *types.DepositToNewOp:
+		return "depositToNew"
+	case *types.Settlement1:
+		return "settlement1"
+	case *types.Settlement2:
+		return "settlement2"
+	case *types.Settlement3:
+		return "settlement3"
+	case *types.WithdrawOp:
+		return "withdraw"
+	case *types.ExitOp:
+		return "exit"
+	}
+	return fmt.Sprintf("%T", tx)
+}
+
+// startTrace starts the trace of the tx at index of the miniblock, if bc has a tracer
+func (bc *Blockchain) startTrace(index int, op string) {
+	if bc.tracer != nil {
+		bc.trace = &TxTrace{MiniBlock: bc.Version(), Index: index, Op: op}
+	}
+}
+
+// endTrace passes the trace of the tx to the tracer
+func (bc *Blockchain) endTrace(fee *big.Int, err error) {
+	if bc.trace == nil {
+		return
+	}
+	if fee != nil {
+		bc.trace.Fee = new(big.Int).Set(fee)
+	}
+	if err != nil {
+		bc.trace.Error = err.Error()
+	}
+	bc.tracer.CaptureTx(bc.trace)
+	bc.trace = nil
+}
+
+func (bc *Blockchain) traceBalance(accountID uint32, tokenID uint16, before, after *big.Int) {
+	if bc.trace != nil {
+		bc.trace.Balances = append(bc.trace.Balances, AccountBalanceChange{
+			AccountID:     accountID,
+			BalanceChange: BalanceChange{TokenID: tokenID, Before: before, After: after},
+		})
+	}
+}
+
+func (bc *Blockchain) traceValue(name string, value *big.Int) {
+	if bc.trace != nil && value != nil {
+		bc.trace.Values = append(bc.trace.Values, TraceValue{Name: name, Value: new(big.Int).Set(value)})
+	}
+}
+
+// appendField appends a field to the execution proof of the tx, recording its offset in the trace
+func (bc *Blockchain) appendField(proof hexutil.Bytes, name string, data []byte) hexutil.Bytes {
+	if bc.trace != nil {
+		offset := 0
+		if n := len(bc.trace.Fields); n > 0 {
+			offset = bc.trace.Fields[n-1].Offset + bc.trace.Fields[n-1].Size
+		}
+		bc.trace.Fields = append(bc.trace.Fields, ProofField{Name: name, Offset: offset, Size: len(data)})
+	}
+	return append(proof, data...)
+}
+
+func (bc *Blockchain) appendSiblingsField(proof hexutil.Bytes, name string, siblings []common.Hash) hexutil.Bytes {
+	return bc.appendField(proof, name, appendSiblings(nil, siblings))
+}
+
+// appendTokenField appends the balance of a token and its siblings
+func (bc *Blockchain) appendTokenField(proof hexutil.Bytes, name string, tokenAmount common.Hash, siblings []common.Hash) hexutil.Bytes {
+	proof = bc.appendField(proof, name+"Balance", tokenAmount.Bytes())
+	return bc.appendSiblingsField(proof, name+"Siblings", siblings)
+}
+
+func (bc *Blockchain) traceSettlementValues(amount1, amount2, fee1, fee2 *big.Int) {
+	bc.traceValue("amount1", amount1)
+	bc.traceValue("amount2", amount2)
+	bc.traceValue("fee1", fee1)
+	bc.traceValue("fee2", fee2)
+}
+
+// jsonTracer writes a trace as a JSON line
+type jsonTracer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONTracer returns a tracer writing every tx to w as a line of JSON
+func NewJSONTracer(w io.Writer) Tracer {
+	return &jsonTracer{enc: json.NewEncoder(w)}
+}
+
+func (t *jsonTracer) CaptureTx(trace *TxTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.enc.Encode(trace); err != nil {
+		panic(err)
+	}
+}
+
+// tableTracer writes a trace as an aligned table
+type tableTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewTableTracer returns a tracer writing every tx to w as a readable table
+func NewTableTracer(w io.Writer) Tracer {
+	return &tableTracer{w: w}
+}
+
+func formatLOO(loo *types.LeftOverOrder) string {
+	if loo == nil {
+		return "-"
+	}
+	return fmt.Sprintf("account %d %d->%d amount %s fee %s rate %s", loo.AccountID, loo.SrcToken, loo.DestToken, loo.Amount, loo.Fee, loo.Rate)
+}
+
+func (t *tableTracer) CaptureTx(trace *TxTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "miniblock %d tx %d %s", trace.MiniBlock, trace.Index, trace.Op)
+	if trace.Fee != nil {
+		fmt.Fprintf(&b, " fee %s", trace.Fee)
+	}
+	if trace.Error != "" {
+		fmt.Fprintf(&b, " error: %s", trace.Error)
+	}
+	b.WriteString("\n")
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, change := range trace.Balances {
+		fmt.Fprintf(w, "  balance\taccount %d token %d\t%s\t-> %s\n", change.AccountID, change.TokenID, change.Before, change.After)
+	}
+	for _, change := range trace.LOOs {
+		fmt.Fprintf(w, "  loo\t%d\t%s\t-> %s\n", change.LOOID, formatLOO(change.Before), formatLOO(change.After))
+	}
+	for _, value := range trace.Values {
+		fmt.Fprintf(w, "  value\t%s\t%s\t\n", value.Name, value.Value)
+	}
+	for _, field := range trace.Fields {
+		fmt.Fprintf(w, "  proof\t%s\t[%d:%d]\t\n", field.Name, field.Offset, field.Offset+field.Size)
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+	if _, err := io.WriteString(t.w, b.String()); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,119 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+)
+
+type recordTracer []*TxTrace
+
+func (r *recordTracer) CaptureTx(trace *TxTrace) {
+	*r = append(*r, trace)
+}
+
+func TestBlockchain_SetTracer(t *testing.T) {
+	genesis := &Genesis{
+		AccountAlloc: map[uint32]GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			1: {Tokens: map[uint16]*big.Int{1: big.NewInt(1000)}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			2: {Tokens: map[uint16]*big.Int{2: big.NewInt(1000)}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+		},
+		AccountMax: 2,
+	}
+	bc := NewBlockchain(genesis, nil)
+	var traces recordTracer
+	bc.SetTracer(&traces)
+	miniBlock := &types.MiniBlock{Txs: []types.Transaction{
+		&types.DepositOp{AccountID: 2, TokenID: 3, Amount: big.NewInt(7)},
+		&types.Settlement1{
+			OpType:   types.SettlementOp11,
+			Token1:   1,
+			Token2:   2,
+			Account1: 1,
+			Account2: 2,
+			Rate1:    types.PackedAmount{Mantisa: 1, Exp: 18},
+			Rate2:    types.PackedAmount{Mantisa: 1, Exp: 18},
+			Amount1:  types.PackedAmount{Mantisa: 100},
+			Amount2:  types.PackedAmount{Mantisa: 40},
+		},
+	}}
+	proofs, err := bc.AddMiniBlock(miniBlock)
+	require.NoError(t, err)
+	require.Len(t, traces, 3)
+
+	// the fields cover the whole proof of every tx
+	for i, trace := range traces {
+		require.Equal(t, i, trace.Index)
+		size := 0
+		for _, field := range trace.Fields {
+			require.Equal(t, size, field.Offset)
+			size += field.Size
+		}
+		require.Equal(t, len(proofs[i]), size)
+	}
+	field := func(trace *TxTrace, name string) hexutil.Bytes {
+		for _, field := range trace.Fields {
+			if field.Name == name {
+				return proofs[trace.Index][field.Offset : field.Offset+field.Size]
+			}
+		}
+		t.Fatalf("no field %s", name)
+		return nil
+	}
+
+	deposit := traces[0]
+	require.Equal(t, "deposit", deposit.Op)
+	require.Equal(t, hexutil.Bytes(common.BigToHash(big.NewInt(7)).Bytes()), field(deposit, "amount"))
+	require.Len(t, deposit.Balances, 1)
+	require.Equal(t, uint32(2), deposit.Balances[0].AccountID)
+	require.Equal(t, uint16(3), deposit.Balances[0].TokenID)
+	require.Equal(t, "0 -> 7", deposit.Balances[0].Before.String()+" -> "+deposit.Balances[0].After.String())
+
+	settlement := traces[1]
+	require.Equal(t, "settlement1", settlement.Op)
+	require.Len(t, settlement.Balances, 6)
+	require.Equal(t, uint32(1), settlement.Balances[0].AccountID)
+	require.Equal(t, "1000", settlement.Balances[0].Before.String())
+	require.Equal(t, uint32(2), settlement.Balances[5].AccountID)
+	require.Equal(t, "amount1", settlement.Values[0].Name)
+	require.Len(t, settlement.LOOs, 1)
+	require.Nil(t, settlement.LOOs[0].Before)
+	loo, ok := bc.store.GetLOO(settlement.LOOs[0].LOOID)
+	require.True(t, ok)
+	require.Equal(t, loo, settlement.LOOs[0].After)
+	require.Equal(t, "totalFee", traces[2].Op)
+
+	// a reverting tx is traced with its error
+	traces = nil
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{
+		&types.WithdrawOp{AccountID: 2, TokenID: 3, Amount: types.PackedAmount{Mantisa: 8}},
+	}})
+	require.Error(t, err)
+	require.Len(t, traces, 1)
+	require.Equal(t, "withdraw", traces[0].Op)
+	require.Equal(t, err.Error(), "tx 0: "+traces[0].Error)
+
+	// the writers keep every tx
+	var jsonOut, tableOut bytes.Buffer
+	bc.SetTracer(NewJSONTracer(&jsonOut))
+	_, err = bc.AddMiniBlock(miniBlock)
+	require.Error(t, err)
+	lines := bytes.Split(bytes.TrimSpace(jsonOut.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var decoded TxTrace
+	require.NoError(t, json.Unmarshal(lines[0], &decoded))
+	require.Equal(t, "deposit", decoded.Op)
+	bc.SetTracer(NewTableTracer(&tableOut))
+	_, err = bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{miniBlock.Txs[0]}})
+	require.NoError(t, err)
+	require.Contains(t, tableOut.String(), "miniblock 1 tx 0 deposit")
+	require.Contains(t, tableOut.String(), "account 2 token 3")
+}
@@ -1,189 +1,181 @@
 package blockchain
 
 import (
+	"sort"
+
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
 )
 
 const zeroHash = "0x0"
 
-type MerkleTree struct {
-	deep uint
-	root *node
-}
+// maxTreeDeep is the deepest tree, keys are uint64
+const maxTreeDeep = 65
 
-func NewTree(deep uint) *MerkleTree {
-	return &MerkleTree{
-		deep: deep,
-		root: newNode(common.HexToHash(zeroHash), deep-1, nil),
+// zeroHashes[h] is the root of an empty subtree of height h, zero with any hasher, see hashNode
+var zeroHashes = func() []common.Hash {
+	hashes := make([]common.Hash, maxTreeDeep)
+	for h := 1; h < maxTreeDeep; h++ {
+		hashes[h] = hashNode(hasher.Keccak, hashes[h-1], hashes[h-1])
 	}
+	return hashes
+}()
+
+// MerkleTree is a sparse merkle tree of deep levels, the leaves are at level 0 and the root at level deep-1.
+// Only the nodes which are not the root of an empty subtree are stored,
+// the node i of level h is the root of the leaves i<<h to (i+1)<<h-1.
+type MerkleTree struct {
+	deep   uint
+	nodes  NodeStore
+	hasher hasher.Hasher
 }
 
-func (tr *MerkleTree) GetProof(k uint64) (common.Hash, []common.Hash) {
-	return tr.root.getProof(k)
+// NewTree returns an empty Keccak tree in memory
+func NewTree(deep uint) *MerkleTree {
+	return NewTreeWithStore(deep, &memoryNodeStore{}, hasher.Keccak)
 }
 
-func (tr *MerkleTree) GetProofBatch(k []uint64) (values []common.Hash, siblings []common.Hash) {
-	var nodes []*node
-	var tmpKey []uint64
-	tmpKey = append(tmpKey, k...)
-	for i := 0; i < len(k); i++ {
-		node := tr.getNode(k[i])
-		if node == nil {
-			panic("invalid node batch proof")
-		} else {
-			values = append(values, node.value)
-		}
-		nodes = append(nodes, node)
+// NewTreeWithStore returns the tree of the nodes of store hashed by h
+func NewTreeWithStore(deep uint, store NodeStore, h hasher.Hasher) *MerkleTree {
+	if deep == 0 || deep > maxTreeDeep {
+		panic("invalid tree deep")
 	}
-
-	for deep := uint(0); deep < tr.deep-1; deep++ {
-		var tmpKeys2 []uint64
-		var tmpNodes []*node
-		for i := 0; i < len(tmpKey); {
-			if (i != len(tmpKey)-1) && (tmpKey[i]/2 == tmpKey[i+1]/2) {
-				tmpKeys2 = append(tmpKeys2, tmpKey[i]/2)
-				tmpNodes = append(tmpNodes, nodes[i].parent)
-				i += 2
-				continue
-			}
-
-			if tmpKey[i]%2 == 0 {
-				siblings = append(siblings, nodes[i].parent.rightHash())
-			} else {
-				siblings = append(siblings, nodes[i].parent.leftHash())
-			}
-			tmpKeys2 = append(tmpKeys2, tmpKey[i]/2)
-			tmpNodes = append(tmpNodes, nodes[i].parent)
-			i++
-		}
-		tmpKey = tmpKeys2
-		nodes = tmpNodes
+	return &MerkleTree{
+		deep:   deep,
+		nodes:  store,
+		hasher: h,
 	}
-	return values, siblings
 }
 
-func (tr *MerkleTree) getNode(k uint64) *node {
-	return tr.root.getNode(k)
-}
-
-func (tr *MerkleTree) Update(k uint64, v common.Hash) {
-	tr.root.update(k, v)
-}
-
-func (tr *MerkleTree) RootHash() common.Hash {
-	return tr.root.value
+func (tr *MerkleTree) get(h uint, i uint64) common.Hash {
+	if v, ok := tr.nodes.GetNode(h, i); ok {
+		return v
+	}
+	return zeroHashes[h]
 }
 
-type node struct {
-	deep   uint
-	value  common.Hash
-	left   *node
-	right  *node
-	parent *node
+func (tr *MerkleTree) set(h uint, i uint64, v common.Hash) {
+	if v == zeroHashes[h] {
+		tr.nodes.DeleteNode(h, i)
+		return
+	}
+	tr.nodes.PutNode(h, i, v)
 }
 
-func newNode(value common.Hash, deep uint, parent *node) *node {
-	return &node{
-		deep:   deep,
-		value:  value,
-		parent: parent,
+// key drops the bits of k above the leaf level
+func (tr *MerkleTree) key(k uint64) uint64 {
+	if tr.deep-1 == 64 {
+		return k
 	}
+	return k & (1<<(tr.deep-1) - 1)
 }
 
-func (n *node) leftHash() common.Hash {
-	if n.left == nil {
-		return common.HexToHash(zeroHash)
-	}
-	return n.left.value
+// GetProof returns the leaf at k and its siblings from the leaf level up
+func (tr *MerkleTree) GetProof(k uint64) (common.Hash, []common.Hash) {
+	siblings := make([]common.Hash, tr.deep-1)
+	return tr.FillProof(k, siblings), siblings
 }
 
-func (n *node) rightHash() common.Hash {
-	if n.right == nil {
-		return common.HexToHash(zeroHash)
+// FillProof writes the siblings of the leaf at k into siblings, which must have deep-1 items, and returns the leaf
+func (tr *MerkleTree) FillProof(k uint64, siblings []common.Hash) common.Hash {
+	k = tr.key(k)
+	for h := uint(0); h < tr.deep-1; h++ {
+		siblings[h] = tr.get(h, (k>>h)^1)
 	}
-	return n.right.value
+	return tr.get(0, k)
 }
 
-func (n *node) getProof(key uint64) (value common.Hash, siblings []common.Hash) {
-	if n.deep == 0 {
-		return n.value, nil
+// GetProofBatch returns the leaves at the sorted keys k and the siblings to rebuild the root from them,
+// level by level from the leaves up, skipping the siblings which are rebuilt from the keys
+func (tr *MerkleTree) GetProofBatch(k []uint64) (values []common.Hash, siblings []common.Hash) {
+	keys := make([]uint64, len(k))
+	for i := range k {
+		keys[i] = tr.key(k[i])
+		values = append(values, tr.get(0, keys[i]))
 	}
 
-	isLeft := ((key >> (n.deep - 1)) & 1) == 0
-	if isLeft {
-		if n.left == nil { // key in null branch
-			for i := 0; i < int(n.deep)-1; i++ {
-				siblings = append(siblings, common.HexToHash(zeroHash))
-			}
-			value = common.HexToHash(zeroHash)
-		} else {
-			value, siblings = n.left.getProof(key)
-		}
-		siblings = append(siblings, n.rightHash())
-	} else {
-		if n.right == nil {
-			for i := 0; i < int(n.deep)-1; i++ {
-				siblings = append(siblings, common.HexToHash(zeroHash))
+	for h := uint(0); h < tr.deep-1; h++ {
+		parents := keys[:0]
+		for i := 0; i < len(keys); {
+			if (i != len(keys)-1) && (keys[i]/2 == keys[i+1]/2) {
+				parents = append(parents, keys[i]/2)
+				i += 2
+				continue
 			}
-			value = common.HexToHash(zeroHash)
-		} else {
-			value, siblings = n.right.getProof(key)
+			siblings = append(siblings, tr.get(h, keys[i]^1))
+			parents = append(parents, keys[i]/2)
+			i++
 		}
-		siblings = append(siblings, n.leftHash())
+		keys = parents
 	}
-	return
+	return values, siblings
 }
 
-func (n *node) getNode(key uint64) *node {
-	if n.deep == 0 {
-		return n
-	}
-	isLeft := ((key >> (n.deep - 1)) & 1) == 0
-	if isLeft {
-		if n.left == nil { // key in null branch
-			return nil
-		} else {
-			return n.left.getNode(key)
-		}
-	} else {
-		if n.right == nil {
-			return nil
-		} else {
-			return n.right.getNode(key)
-		}
+func (tr *MerkleTree) Update(k uint64, v common.Hash) {
+	k = tr.key(k)
+	tr.set(0, k, v)
+	for h := uint(1); h < tr.deep; h++ {
+		k >>= 1
+		tr.set(h, k, hashNode(tr.hasher, tr.get(h-1, 2*k), tr.get(h-1, 2*k+1)))
 	}
 }
 
-func (n *node) update(key uint64, value common.Hash) {
-	if n.deep == 0 {
-		n.value = value
-		return
+// UpdateBatch sets every leaf of leaves, hashing each touched node once
+func (tr *MerkleTree) UpdateBatch(leaves map[uint64]common.Hash) {
+	keys := make([]uint64, 0, len(leaves))
+	for k, v := range leaves {
+		k = tr.key(k)
+		tr.set(0, k, v)
+		keys = append(keys, k)
 	}
-
-	isLeft := ((key >> (n.deep - 1)) & 1) == 0
-	if isLeft {
-		if n.left == nil {
-			n.left = newNode(common.HexToHash(zeroHash), n.deep-1, n)
-		}
-		n.left.update(key, value)
-	} else {
-		if n.right == nil {
-			n.right = newNode(common.HexToHash(zeroHash), n.deep-1, n)
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for h := uint(1); h < tr.deep; h++ {
+		parents := keys[:0]
+		for _, k := range keys {
+			if k /= 2; len(parents) > 0 && parents[len(parents)-1] == k {
+				continue
+			}
+			parents = append(parents, k)
+			tr.set(h, k, hashNode(tr.hasher, tr.get(h-1, 2*k), tr.get(h-1, 2*k+1)))
 		}
-		n.right.update(key, value)
+		keys = parents
 	}
-	// update the root value
-	n.value = GetRoot(n.leftHash(), n.rightHash())
 }
 
-//func GetRootTree(key uint64 , child common.Hash, siblings []common.Hash) common.Hash {
-//	for _, siblings.
-//}
+func (tr *MerkleTree) RootHash() common.Hash {
+	return tr.get(tr.deep-1, 0)
+}
 
+// hashNode returns the parent of 2 nodes, the parent of 2 zero nodes is zero
+func hashNode(h hasher.Hasher, left common.Hash, right common.Hash) common.Hash {
+	if left == (common.Hash{}) && right == (common.Hash{}) {
+		return common.Hash{}
+	}
+	return h.HashPair(left, right)
+}
+
+// GetRoot returns the parent of 2 nodes of a Keccak tree
 func GetRoot(left common.Hash, right common.Hash) common.Hash {
-	if left == common.HexToHash(zeroHash) && right == common.HexToHash(zeroHash) {
-		return common.HexToHash(zeroHash)
+	return hashNode(hasher.Keccak, left, right)
+}
+
+// Clone returns a deep copy of the tree in memory
+func (tr *MerkleTree) Clone() *MerkleTree {
+	out := NewTreeWithStore(tr.deep, &memoryNodeStore{}, tr.hasher)
+	for h := uint(0); h < tr.deep; h++ {
+		tr.nodes.ForEachNode(h, func(i uint64, v common.Hash) {
+			out.nodes.PutNode(h, i, v)
+		})
 	}
-	return crypto.Keccak256Hash(left.Bytes(), right.Bytes())
+	return out
+}
+
+// Leaves returns the value of every non zero leaf by key
+func (tr *MerkleTree) Leaves() map[uint64]common.Hash {
+	leaves := make(map[uint64]common.Hash)
+	tr.nodes.ForEachNode(0, func(k uint64, v common.Hash) {
+		leaves[k] = v
+	})
+	return leaves
 }
@@ -1,9 +1,14 @@
 package blockchain
 
 import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -66,3 +71,144 @@ func TestMerkleTree_GetProofBatch(t *testing.T) {
 	recoverRootHash := getRootHashFromBatchProof(keys, values, siblings, 4)
 	require.Equal(t, recoverRootHash.Hex(), tr.RootHash().Hex())
 }
+
+func TestMerkleTree_Leaves(t *testing.T) {
+	tree := NewTree(11)
+	tree.Update(0, common.HexToHash("0x1"))
+	tree.Update(5, common.HexToHash("0x2"))
+	tree.Update(1023, common.HexToHash("0x3"))
+	tree.Update(7, common.HexToHash("0x4"))
+	tree.Update(7, common.HexToHash(zeroHash))
+	require.Equal(t, map[uint64]common.Hash{
+		0:    common.HexToHash("0x1"),
+		5:    common.HexToHash("0x2"),
+		1023: common.HexToHash("0x3"),
+	}, tree.Leaves())
+}
+
+// referenceRoot computes the root of the leaves at height h under key k node by node
+func referenceRoot(leaves map[uint64]common.Hash, k uint64, h uint) common.Hash {
+	if h == 0 {
+		return leaves[k]
+	}
+	return GetRoot(referenceRoot(leaves, 2*k, h-1), referenceRoot(leaves, 2*k+1, h-1))
+}
+
+func rootFromProof(k uint64, value common.Hash, siblings []common.Hash) common.Hash {
+	for _, sibling := range siblings {
+		if k&1 == 0 {
+			value = GetRoot(value, sibling)
+		} else {
+			value = GetRoot(sibling, value)
+		}
+		k >>= 1
+	}
+	return value
+}
+
+func TestMerkleTree_Update(t *testing.T) {
+	a, b := common.HexToHash("0x1"), common.HexToHash("0x2")
+	require.Equal(t, crypto.Keccak256Hash(a.Bytes(), b.Bytes()), GetRoot(a, b))
+	require.Equal(t, common.Hash{}, GetRoot(common.Hash{}, common.Hash{}))
+
+	const deep = 13
+	rnd := rand.New(rand.NewSource(1))
+	leaves := make(map[uint64]common.Hash)
+	tree := NewTree(deep)
+	for i := 0; i < 300; i++ {
+		k := uint64(rnd.Intn(1 << (deep - 1)))
+		v := common.BigToHash(big.NewInt(rnd.Int63()))
+		if i%5 == 0 {
+			v = common.Hash{}
+		}
+		leaves[k] = v
+		tree.Update(k, v)
+	}
+	require.Equal(t, referenceRoot(leaves, 0, deep-1), tree.RootHash())
+
+	batch := NewTree(deep)
+	batch.UpdateBatch(leaves)
+	require.Equal(t, tree.RootHash(), batch.RootHash())
+	require.Equal(t, tree.nodes, batch.nodes)
+
+	for _, k := range []uint64{0, 1, 77, 1<<(deep-1) - 1} {
+		value, siblings := tree.GetProof(k)
+		require.Len(t, siblings, deep-1)
+		require.Equal(t, leaves[k], value)
+		require.Equal(t, tree.RootHash(), rootFromProof(k, value, siblings))
+	}
+
+	for k := range leaves {
+		tree.Update(k, common.Hash{})
+	}
+	require.Equal(t, common.Hash{}, tree.RootHash())
+	for _, nodes := range tree.nodes.(*memoryNodeStore).levels {
+		require.Empty(t, nodes)
+	}
+}
+
+// benchmarkTree returns a state tree of numLeaves accounts, ids are given in order like AccountMax does
+func benchmarkTree(b *testing.B, numLeaves int) (*MerkleTree, []uint64) {
+	if numLeaves >= 1e7 && testing.Short() {
+		b.Skip("skip 10^7 leaves in short mode")
+	}
+	leaves := make(map[uint64]common.Hash, numLeaves)
+	for k := 0; k < numLeaves; k++ {
+		leaves[uint64(k)] = common.BigToHash(big.NewInt(int64(k) + 1))
+	}
+	tree := NewTree(DefaultParams().StateTreeDeep)
+	tree.UpdateBatch(leaves)
+	// random order to defeat the caches
+	keys := rand.New(rand.NewSource(1)).Perm(numLeaves)
+	out := make([]uint64, numLeaves)
+	for i, k := range keys {
+		out[i] = uint64(k)
+	}
+	return tree, out
+}
+
+var benchmarkSizes = []int{1e3, 1e4, 1e5, 1e6, 1e7}
+
+func BenchmarkMerkleTree_Update(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("leaves=%d", size), func(b *testing.B) {
+			tree, keys := benchmarkTree(b, size)
+			v := common.HexToHash("0x1234")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Update(keys[i%len(keys)], v)
+			}
+		})
+	}
+}
+
+func BenchmarkMerkleTree_GetProof(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("leaves=%d", size), func(b *testing.B) {
+			tree, keys := benchmarkTree(b, size)
+			siblings := make([]common.Hash, tree.deep-1)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.FillProof(keys[i%len(keys)], siblings)
+			}
+		})
+	}
+}
+
+func BenchmarkMerkleTree_GetProofBatch(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("leaves=%d", size), func(b *testing.B) {
+			tree, keys := benchmarkTree(b, size)
+			batch := make([]uint64, 16)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				copy(batch, keys[(i*len(batch))%(len(keys)-len(batch)):])
+				sort.Slice(batch, func(i, j int) bool { return batch[i] < batch[j] })
+				tree.GetProofBatch(batch)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+)
+
+// pubdata length in bytes of every op type
+var txLengths = map[OpType]int{
+	SettlementOp11: 50,
+	SettlementOp12: 50,
+	SettlementOp13: 50,
+	SettlementOp21: 30,
+	SettlementOp22: 30,
+	SettlementOp3:  12,
+	DepositToNew:   6,
+	Deposit:        6,
+	Withdraw:       37,
+	Exit:           37,
+}
+
+func (t OpType) String() string {
+	switch t {
+	case SettlementOp11:
+		return "Settlement11"
+	case SettlementOp12:
+		return "Settlement12"
+	case SettlementOp13:
+		return "Settlement13"
+	case SettlementOp21:
+		return "Settlement21"
+	case SettlementOp22:
+		return "Settlement22"
+	case SettlementOp3:
+		return "Settlement3"
+	case DepositToNew:
+		return "DepositToNew"
+	case Deposit:
+		return "Deposit"
+	case Withdraw:
+		return "Withdraw"
+	case Exit:
+		return "Exit"
+	}
+	return fmt.Sprintf("OpType(%d)", uint8(t))
+}
+
+// DecodeMiniBlock parses the miniblock data submitted to the contract, see MiniBlock.Bytes
+func DecodeMiniBlock(data []byte) (*MiniBlock, error) {
+	if len(data) < 2*ethCommon.HashLength {
+		return nil, fmt.Errorf("miniblock of %d bytes is shorter than its commitment and state hash", len(data))
+	}
+	blk := &MiniBlock{
+		Commitment: ethCommon.BytesToHash(data[:ethCommon.HashLength]),
+		StateHash:  ethCommon.BytesToHash(data[ethCommon.HashLength : 2*ethCommon.HashLength]),
+	}
+	offset := 2 * ethCommon.HashLength
+	for offset < len(data) {
+		tx, n, err := DecodeTx(data[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("tx %d at offset %d: %w", len(blk.Txs), offset, err)
+		}
+		blk.Txs = append(blk.Txs, tx)
+		offset += n
+	}
+	return blk, nil
+}
+
+// DecodeTx parses the pubdata of the first tx of data and returns the number of bytes read.
+// The pubdata of a deposit only holds its DepositID, the other fields are the ones of the L1 deposit.
+func DecodeTx(data []byte) (tx Transaction, n int, err error) {
+	if len(data) == 0 {
+		return nil, 0, fmt.Errorf("empty pubdata")
+	}
+	opType := OpType(data[0] >> 4)
+	n, ok := txLengths[opType]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown op type %d", opType)
+	}
+	if len(data) < n {
+		return nil, 0, fmt.Errorf("%s pubdata of %d bytes, expect %d", opType, len(data), n)
+	}
+	data = data[:n]
+
+	switch opType {
+	case SettlementOp11, SettlementOp12, SettlementOp13:
+		head := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+		periods := uint48(data[43:49])<<8 | uint64(data[49])
+		tx = &Settlement1{
+			OpType:       opType,
+			Token1:       uint16(head>>10) & (1<<TokenIDBits - 1),
+			Token2:       uint16(head) & (1<<TokenIDBits - 1),
+			Account1:     binary.BigEndian.Uint32(data[3:]),
+			Account2:     binary.BigEndian.Uint32(data[7:]),
+			Amount1:      decodePackedAmount(data[11:]),
+			Amount2:      decodePackedAmount(data[16:]),
+			Rate1:        decodePackedAmount(data[21:]),
+			Rate2:        decodePackedAmount(data[26:]),
+			Fee1:         decodePackedFee(data[31:]),
+			Fee2:         decodePackedFee(data[33:]),
+			ValidSince1:  binary.BigEndian.Uint32(data[35:]),
+			ValidSince2:  binary.BigEndian.Uint32(data[39:]),
+			ValidPeriod1: uint32(periods >> ValidPeriodBits),
+			ValidPeriod2: uint32(periods) & (1<<ValidPeriodBits - 1),
+		}
+	case SettlementOp21, SettlementOp22:
+		tx = &Settlement2{
+			OpType:       opType,
+			LooID1:       uint48(data) & (1<<LooIDBits - 1),
+			AccountID2:   binary.BigEndian.Uint32(data[6:]),
+			Amount2:      decodePackedAmount(data[10:]),
+			Rate2:        decodePackedAmount(data[15:]),
+			Fee2:         decodePackedFee(data[20:]),
+			ValidSince2:  binary.BigEndian.Uint32(data[22:]),
+			ValidPeriod2: binary.BigEndian.Uint32(data[26:]) >> 4,
+		}
+	case SettlementOp3:
+		tx = &Settlement3{
+			LooID1: uint48(data) & (1<<LooIDBits - 1),
+			LooID2: uint48(data[6:]) >> 4,
+		}
+	case DepositToNew:
+		tx = &DepositToNewOp{DepositID: uint48(data) & (1<<DepositIDBits - 1)}
+	case Deposit:
+		tx = &DepositOp{DepositID: uint48(data) & (1<<DepositIDBits - 1)}
+	case Withdraw:
+		tx = &WithdrawOp{
+			TokenID:    (binary.BigEndian.Uint16(data) >> 2) & (1<<TokenIDBits - 1),
+			Amount:     decodePackedAmount(data[2:]),
+			DestAddr:   ethCommon.BytesToAddress(data[7:27]),
+			AccountID:  binary.BigEndian.Uint32(data[27:]),
+			ValidSince: binary.BigEndian.Uint32(data[31:]),
+			Fee:        decodePackedFee(data[35:]),
+		}
+	case Exit:
+		tx = &ExitOp{
+			AccountID:   binary.BigEndian.Uint32(data[1:]),
+			AccountRoot: ethCommon.BytesToHash(data[5:37]),
+		}
+	}
+	return tx, n, nil
+}
+
+func uint48(b []byte) uint64 {
+	var x uint64
+	for _, c := range b[:6] {
+		x = x<<8 | uint64(c)
+	}
+	return x
+}
+
+func decodePackedAmount(b []byte) PackedAmount {
+	return PackedAmount{Mantisa: binary.BigEndian.Uint32(b), Exp: b[4]}
+}
+
+func decodePackedFee(b []byte) PackedFee {
+	x := binary.BigEndian.Uint16(b)
+	return PackedFee{Mantisa: x >> FeeExpBits, Exp: uint8(x & (1<<FeeExpBits - 1))}
+}
@@ -0,0 +1,120 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeMiniBlock(t *testing.T) {
+	blk := &MiniBlock{
+		Commitment: ethCommon.HexToHash("0x01"),
+		StateHash:  ethCommon.HexToHash("0x02"),
+		Txs: []Transaction{
+			&Settlement1{
+				OpType:       SettlementOp12,
+				Token1:       1<<TokenIDBits - 1,
+				Token2:       2,
+				Account1:     14,
+				Account2:     1<<32 - 1,
+				Rate1:        PackedAmount{Mantisa: 1, Exp: 18},
+				Rate2:        PackedAmount{Mantisa: 3, Exp: 255},
+				Amount1:      PackedAmount{Mantisa: 1<<32 - 1, Exp: 14},
+				Amount2:      PackedAmount{Mantisa: 3, Exp: 14},
+				Fee1:         PackedFee{Mantisa: 1<<FeeMantisaBits - 1, Exp: 1<<FeeExpBits - 1},
+				Fee2:         PackedFee{Mantisa: 1, Exp: 6},
+				ValidSince1:  1600661872,
+				ValidSince2:  1600661873,
+				ValidPeriod1: 1<<ValidPeriodBits - 1,
+				ValidPeriod2: 86400,
+			},
+			&Settlement2{
+				OpType:       SettlementOp22,
+				LooID1:       1<<LooIDBits - 1,
+				AccountID2:   7,
+				Amount2:      PackedAmount{Mantisa: 5, Exp: 2},
+				Rate2:        PackedAmount{Mantisa: 2, Exp: 18},
+				Fee2:         PackedFee{Mantisa: 3, Exp: 1},
+				ValidSince2:  1600661872,
+				ValidPeriod2: 1<<ValidPeriodBits - 1,
+			},
+			&Settlement3{LooID1: 3, LooID2: 1<<LooIDBits - 1},
+			&DepositOp{DepositID: 1<<DepositIDBits - 1, Amount: big.NewInt(0)},
+			&DepositToNewOp{DepositID: 4, PubKey: make([]byte, PubKeyLength), Amount: big.NewInt(0)},
+			&WithdrawOp{
+				TokenID:    1<<TokenIDBits - 1,
+				Amount:     PackedAmount{Mantisa: 9, Exp: 3},
+				DestAddr:   ethCommon.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea"),
+				AccountID:  2,
+				ValidSince: 1600661872,
+				Fee:        PackedFee{Mantisa: 1, Exp: 2},
+			},
+			&ExitOp{AccountID: 5, AccountRoot: ethCommon.HexToHash("0x03")},
+		},
+	}
+	decoded, err := DecodeMiniBlock(blk.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, blk.Commitment, decoded.Commitment)
+	require.Equal(t, blk.StateHash, decoded.StateHash)
+	require.Len(t, decoded.Txs, len(blk.Txs))
+	for i, tx := range decoded.Txs {
+		switch tx := tx.(type) {
+		case *DepositOp:
+			require.Equal(t, blk.Txs[i].(*DepositOp).DepositID, tx.DepositID)
+		case *DepositToNewOp:
+			require.Equal(t, blk.Txs[i].(*DepositToNewOp).DepositID, tx.DepositID)
+		default:
+			require.Equal(t, blk.Txs[i], tx)
+		}
+	}
+
+	_, err = DecodeMiniBlock(blk.Bytes()[:len(blk.Bytes())-1])
+	require.Error(t, err)
+	_, _, err = DecodeTx([]byte{0xf0})
+	require.Error(t, err)
+}
+
+func TestDisassembleMiniBlock(t *testing.T) {
+	blk := &MiniBlock{
+		Commitment: ethCommon.HexToHash("0x01"),
+		StateHash:  ethCommon.HexToHash("0x02"),
+		Txs: []Transaction{
+			&Settlement2{
+				OpType:       SettlementOp21,
+				LooID1:       3,
+				AccountID2:   7,
+				Amount2:      PackedAmount{Mantisa: 5, Exp: 2},
+				Rate2:        PackedAmount{Mantisa: 2, Exp: 18},
+				Fee2:         PackedFee{Mantisa: 3, Exp: 1},
+				ValidSince2:  1600661872,
+				ValidPeriod2: 86400,
+			},
+			&WithdrawOp{
+				TokenID:    4,
+				Amount:     PackedAmount{Mantisa: 9, Exp: 3},
+				DestAddr:   ethCommon.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea"),
+				AccountID:  2,
+				ValidSince: 1600661872,
+				Fee:        PackedFee{Mantisa: 1, Exp: 2},
+			},
+		},
+	}
+	sections, err := DisassembleMiniBlock(blk.Bytes())
+	require.NoError(t, err)
+	require.Len(t, sections, 3)
+	require.Equal(t, Field{Name: "stateHash", Offset: 32, Size: 32, Value: blk.StateHash.Hex()}, sections[0].Fields[1])
+
+	require.Equal(t, "tx 0 Settlement21", sections[1].Name)
+	require.Equal(t, blk.Txs[0], sections[1].Tx)
+	require.Equal(t, Field{Name: "amount2", Offset: 74, Size: 5, Value: "500 (5e2)"}, sections[1].Fields[3])
+
+	require.Equal(t, "tx 1 Withdraw", sections[2].Name)
+	require.Equal(t, 94, sections[2].Offset)
+	require.Equal(t, Field{Name: "destAddr", Offset: 101, Size: 20, Value: "0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea"}, sections[2].Fields[3])
+	require.Equal(t, Field{Name: "fee", Offset: 129, Size: 2, Value: "100 (1e2)"}, sections[2].Fields[6])
+
+	_, err = DisassembleMiniBlock(blk.Bytes()[:len(blk.Bytes())-1])
+	require.Error(t, err)
+}
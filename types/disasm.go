@@ -0,0 +1,212 @@
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// zk message layout, see blockchain.BuildSettlement1ZkMsg and blockchain.BuildWithdrawZkMsg
+const (
+	ZkMsgLength       = 128
+	zkMsgPartLength   = 2 * ethCommon.HashLength
+	zkMsgOrderType    = 1
+	zkMsgWithdrawType = 9
+)
+
+// Field is a field of encoded data, Offset and Size are the bytes holding it.
+// Bit packed fields share their bytes with the fields next to them.
+type Field struct {
+	Name   string
+	Offset int
+	Size   int
+	Value  string
+}
+
+// Section is a group of fields, the header or a tx of a miniblock or a message of a zk message
+type Section struct {
+	Name   string
+	Offset int
+	Size   int
+	// Tx is the decoded tx of a section of a miniblock
+	Tx     Transaction `json:",omitempty"`
+	Fields []Field
+}
+
+type fieldList struct {
+	offset int
+	fields []Field
+}
+
+func (l *fieldList) add(name string, offset, size int, value interface{}) {
+	l.fields = append(l.fields, Field{Name: name, Offset: l.offset + offset, Size: size, Value: fmt.Sprint(value)})
+}
+
+func formatPackedAmount(a PackedAmount) string {
+	return fmt.Sprintf("%s (%de%d)", a.Big(), a.Mantisa, a.Exp)
+}
+
+func formatPackedFee(f PackedFee) string {
+	return fmt.Sprintf("%s (%de%d)", f.Big(), f.Mantisa, f.Exp)
+}
+
+// DisassembleMiniBlock decodes the miniblock data submitted to the contract like DecodeMiniBlock,
+// with the bytes of every field
+func DisassembleMiniBlock(data []byte) ([]Section, error) {
+	if len(data) < 2*ethCommon.HashLength {
+		return nil, fmt.Errorf("miniblock of %d bytes is shorter than its commitment and state hash", len(data))
+	}
+	header := fieldList{}
+	header.add("commitment", 0, ethCommon.HashLength, ethCommon.BytesToHash(data[:ethCommon.HashLength]).Hex())
+	header.add("stateHash", ethCommon.HashLength, ethCommon.HashLength, ethCommon.BytesToHash(data[ethCommon.HashLength:2*ethCommon.HashLength]).Hex())
+	sections := []Section{{Name: "header", Size: 2 * ethCommon.HashLength, Fields: header.fields}}
+
+	offset := 2 * ethCommon.HashLength
+	for offset < len(data) {
+		tx, fields, n, err := DisassembleTx(data[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("tx %d at offset %d: %w", len(sections)-1, offset, err)
+		}
+		for i := range fields {
+			fields[i].Offset += offset
+		}
+		sections = append(sections, Section{
+			Name:   fmt.Sprintf("tx %d %s", len(sections)-1, OpType(data[offset]>>4)),
+			Offset: offset,
+			Size:   n,
+			Tx:     tx,
+			Fields: fields,
+		})
+		offset += n
+	}
+	return sections, nil
+}
+
+// DisassembleTx decodes the pubdata of the first tx of data like DecodeTx, with the bytes of every field
+func DisassembleTx(data []byte) (tx Transaction, fields []Field, n int, err error) {
+	tx, n, err = DecodeTx(data)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	l := fieldList{}
+	opType := OpType(data[0] >> 4)
+	l.add("opType", 0, 1, opType)
+	switch tx := tx.(type) {
+	case *Settlement1:
+		l.add("token1", 0, 2, tx.Token1)
+		l.add("token2", 1, 2, tx.Token2)
+		l.add("account1", 3, 4, tx.Account1)
+		l.add("account2", 7, 4, tx.Account2)
+		l.add("amount1", 11, 5, formatPackedAmount(tx.Amount1))
+		l.add("amount2", 16, 5, formatPackedAmount(tx.Amount2))
+		l.add("rate1", 21, 5, formatPackedAmount(tx.Rate1))
+		l.add("rate2", 26, 5, formatPackedAmount(tx.Rate2))
+		l.add("fee1", 31, 2, formatPackedFee(tx.Fee1))
+		l.add("fee2", 33, 2, formatPackedFee(tx.Fee2))
+		l.add("validSince1", 35, 4, tx.ValidSince1)
+		l.add("validSince2", 39, 4, tx.ValidSince2)
+		l.add("validPeriod1", 43, 4, tx.ValidPeriod1)
+		l.add("validPeriod2", 46, 4, tx.ValidPeriod2)
+	case *Settlement2:
+		l.add("looID1", 0, 6, tx.LooID1)
+		l.add("accountID2", 6, 4, tx.AccountID2)
+		l.add("amount2", 10, 5, formatPackedAmount(tx.Amount2))
+		l.add("rate2", 15, 5, formatPackedAmount(tx.Rate2))
+		l.add("fee2", 20, 2, formatPackedFee(tx.Fee2))
+		l.add("validSince2", 22, 4, tx.ValidSince2)
+		l.add("validPeriod2", 26, 4, tx.ValidPeriod2)
+	case *Settlement3:
+		l.add("looID1", 0, 6, tx.LooID1)
+		l.add("looID2", 6, 6, tx.LooID2)
+	case *DepositToNewOp:
+		l.add("depositID", 0, 6, tx.DepositID)
+	case *DepositOp:
+		l.add("depositID", 0, 6, tx.DepositID)
+	case *WithdrawOp:
+		l.add("tokenID", 0, 2, tx.TokenID)
+		l.add("amount", 2, 5, formatPackedAmount(tx.Amount))
+		l.add("destAddr", 7, ethCommon.AddressLength, tx.DestAddr.Hex())
+		l.add("accountID", 27, 4, tx.AccountID)
+		l.add("validSince", 31, 4, tx.ValidSince)
+		l.add("fee", 35, 2, formatPackedFee(tx.Fee))
+	case *ExitOp:
+		l.add("accountID", 1, 4, tx.AccountID)
+		l.add("accountRoot", 5, ethCommon.HashLength, tx.AccountRoot.Hex())
+	}
+	return tx, l.fields, n, nil
+}
+
+// DisassembleZkMsg decodes the message signed by the users of a settlement1, a settlement2 or a withdraw,
+// see blockchain.BuildSettlement1ZkMsg, blockchain.BuildSettlement2ZkMsg and blockchain.BuildWithdrawZkMsg
+func DisassembleZkMsg(data []byte) ([]Section, error) {
+	if len(data) != ZkMsgLength {
+		return nil, fmt.Errorf("zk message of %d bytes, expect %d", len(data), ZkMsgLength)
+	}
+	var sections []Section
+	for offset := 0; offset < len(data); offset += zkMsgPartLength {
+		part := data[offset : offset+zkMsgPartLength]
+		if offset > 0 && isZero(part) {
+			if !isZero(data[offset:]) {
+				return nil, fmt.Errorf("non zero padding at offset %d", offset)
+			}
+			break
+		}
+		l := fieldList{offset: offset}
+		var name string
+		switch part[0] {
+		case zkMsgOrderType:
+			name = "order"
+			packed := binary.BigEndian.Uint64(part[19:])
+			fee := uint16(packed >> 20)
+			if part[27]&^128 != 0 || !isZero(part[28:32]) {
+				return nil, fmt.Errorf("order at offset %d: non zero padding", offset)
+			}
+			l.add("type", 0, 1, part[0])
+			l.add("accountID", 1, 4, binary.BigEndian.Uint32(part[1:]))
+			l.add("amount", 5, 5, formatPackedAmount(decodePackedAmount(part[5:])))
+			l.add("rate", 10, 5, formatPackedAmount(decodePackedAmount(part[10:])))
+			l.add("validSince", 15, 4, binary.BigEndian.Uint32(part[15:]))
+			l.add("validPeriod", 19, 4, packed>>36)
+			l.add("fee", 22, 3, formatPackedFee(PackedFee{Mantisa: fee >> FeeExpBits, Exp: uint8(fee & (1<<FeeExpBits - 1))}))
+			l.add("srcToken", 24, 2, (packed>>TokenIDBits)&(1<<TokenIDBits-1))
+			l.add("destToken", 25, 2, packed&(1<<TokenIDBits-1))
+			l.add("partiallyFillable", 27, 1, part[27] == 128)
+		case zkMsgWithdrawType:
+			name = "withdraw"
+			packed := binary.BigEndian.Uint32(part[14:])
+			if packed&(1<<6-1) != 0 || !isZero(part[18:32]) {
+				return nil, fmt.Errorf("withdraw: non zero padding")
+			}
+			l.add("type", 0, 1, part[0])
+			l.add("accountID", 1, 4, binary.BigEndian.Uint32(part[1:]))
+			l.add("amount", 5, 5, formatPackedAmount(decodePackedAmount(part[5:])))
+			l.add("validSince", 10, 4, binary.BigEndian.Uint32(part[10:]))
+			l.add("tokenID", 14, 2, packed>>22)
+			l.add("fee", 15, 3, formatPackedFee(PackedFee{Mantisa: uint16(packed>>12) & (1<<FeeMantisaBits - 1), Exp: uint8(packed>>6) & (1<<FeeExpBits - 1)}))
+		default:
+			return nil, fmt.Errorf("unknown zk message type %d at offset %d", part[0], offset)
+		}
+		// the public key is stored little endian
+		pubKey := make([]byte, PubKeyLength)
+		for i := range pubKey {
+			pubKey[i] = part[zkMsgPartLength-1-i]
+		}
+		l.add("pubKey", ethCommon.HashLength, PubKeyLength, hexutil.Bytes(pubKey))
+		sections = append(sections, Section{Name: name, Offset: offset, Size: zkMsgPartLength, Fields: l.fields})
+	}
+	if len(sections) == 2 && (sections[0].Name != "order" || sections[1].Name != "order") {
+		return nil, fmt.Errorf("%s after %s, expect two orders", sections[1].Name, sections[0].Name)
+	}
+	return sections, nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
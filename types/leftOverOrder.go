@@ -5,9 +5,9 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
 
 	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/hasher"
 )
 
 type LeftOverOrder struct {
@@ -34,8 +34,14 @@ func (loo *LeftOverOrder) Bytes() hexutil.Bytes {
 	return out
 }
 
+// Hash returns the Keccak hash of the LOO, the leaf of the LOO tree of the deployed contract
 func (loo *LeftOverOrder) Hash() common.Hash {
-	return crypto.Keccak256Hash(
+	return loo.HashWith(hasher.Keccak)
+}
+
+// HashWith returns the hash of the LOO by h
+func (loo *LeftOverOrder) HashWith(h hasher.Hasher) common.Hash {
+	return h.Hash(
 		util.Uint32ToBytes(loo.AccountID), util.Uint16ToByte(loo.SrcToken), util.Uint16ToByte(loo.DestToken),
 		common.BigToHash(loo.Amount).Bytes(), common.BigToHash(loo.Fee).Bytes(), common.BigToHash(loo.Rate).Bytes(),
 		util.Uint32ToBytes(loo.ValidSince), util.Uint32ToBytes(loo.ValidPeriod),
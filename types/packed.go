@@ -0,0 +1,108 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// bit widths of the packed number fields
+const (
+	AmountMantisaBits = 32
+	AmountExpBits     = 8
+	FeeMantisaBits    = 10
+	FeeExpBits        = 6
+)
+
+var (
+	ErrNegative = errors.New("negative value")
+	ErrInexact  = errors.New("value can not be represented exactly")
+	ErrOverflow = errors.New("value overflows")
+)
+
+// RoundingMode tells how PackAmount and PackFee handle values which can not be represented exactly
+type RoundingMode uint8
+
+const (
+	// RoundExact returns ErrInexact
+	RoundExact RoundingMode = iota
+	// RoundDown returns the largest representable value below
+	RoundDown
+	// RoundUp returns the smallest representable value above
+	RoundUp
+)
+
+var ten = big.NewInt(10)
+
+// PackAmount returns the canonical PackedAmount of x, see packNumber
+func PackAmount(x *big.Int, mode RoundingMode) (PackedAmount, error) {
+	mantisa, exp, err := packNumber(x, AmountMantisaBits, AmountExpBits, mode)
+	if err != nil {
+		return PackedAmount{}, fmt.Errorf("pack amount %s: %w", x.String(), err)
+	}
+	return PackedAmount{Mantisa: uint32(mantisa), Exp: exp}, nil
+}
+
+// PackFee returns the canonical PackedFee of x, see packNumber
+func PackFee(x *big.Int, mode RoundingMode) (PackedFee, error) {
+	mantisa, exp, err := packNumber(x, FeeMantisaBits, FeeExpBits, mode)
+	if err != nil {
+		return PackedFee{}, fmt.Errorf("pack fee %s: %w", x.String(), err)
+	}
+	return PackedFee{Mantisa: uint16(mantisa), Exp: exp}, nil
+}
+
+// Validate reports a mantisa or exponent which does not fit in its field
+func (f PackedFee) Validate() error {
+	if f.Mantisa >= 1<<FeeMantisaBits {
+		return fmt.Errorf("fee mantisa %d: %w %d bits", f.Mantisa, ErrOverflow, FeeMantisaBits)
+	}
+	if f.Exp >= 1<<FeeExpBits {
+		return fmt.Errorf("fee exp %d: %w %d bits", f.Exp, ErrOverflow, FeeExpBits)
+	}
+	return nil
+}
+
+// packNumber returns mantisa * 10^exp = x rounded with mode, using the smallest exponent for which
+// the mantisa fits in mantisaBits. The result is canonical: trailing zeros of the mantisa are moved
+// into the exponent while it fits, and zero is packed as 0 * 10^0.
+func packNumber(x *big.Int, mantisaBits uint, expBits uint, mode RoundingMode) (mantisa uint64, exp uint8, err error) {
+	if x.Sign() < 0 {
+		return 0, 0, ErrNegative
+	}
+	var (
+		maxMantisa = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), mantisaBits), big.NewInt(1))
+		maxExp     = uint64(1)<<expBits - 1
+		divisor    = big.NewInt(1)
+		m          = new(big.Int)
+		r          = new(big.Int)
+		e          uint64
+	)
+	for ; ; e++ {
+		if e > maxExp {
+			return 0, 0, ErrOverflow
+		}
+		m.QuoRem(x, divisor, r)
+		if r.Sign() != 0 {
+			switch mode {
+			case RoundExact:
+				return 0, 0, ErrInexact
+			case RoundUp:
+				m.Add(m, big.NewInt(1))
+			}
+		}
+		if m.Cmp(maxMantisa) <= 0 {
+			break
+		}
+		divisor.Mul(divisor, ten)
+	}
+
+	if m.Sign() == 0 {
+		return 0, 0, nil
+	}
+	for e < maxExp && new(big.Int).Mod(m, ten).Sign() == 0 {
+		m.Div(m, ten)
+		e++
+	}
+	return m.Uint64(), uint8(e), nil
+}
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackAmount(t *testing.T) {
+	maxMantisa := uint64(1)<<AmountMantisaBits - 1
+	tests := []struct {
+		value    string
+		mode     RoundingMode
+		expected PackedAmount
+		err      error
+	}{
+		{"0", RoundExact, PackedAmount{0, 0}, nil},
+		{"45242000", RoundExact, PackedAmount{45242, 3}, nil},
+		{"4294967295", RoundExact, PackedAmount{4294967295, 0}, nil},
+		{"4294967296", RoundExact, PackedAmount{}, ErrInexact},
+		{"4294967296", RoundDown, PackedAmount{429496729, 1}, nil},
+		{"4294967296", RoundUp, PackedAmount{42949673, 2}, nil},
+		{"42949672950", RoundExact, PackedAmount{4294967295, 1}, nil},
+		{"42949672951", RoundUp, PackedAmount{42949673, 3}, nil},
+		{"1000000000000000000", RoundExact, PackedAmount{1, 18}, nil},
+		{"-1", RoundDown, PackedAmount{}, ErrNegative},
+	}
+	for _, test := range tests {
+		x, _ := new(big.Int).SetString(test.value, 10)
+		packed, err := PackAmount(x, test.mode)
+		if test.err != nil {
+			require.True(t, errors.Is(err, test.err), test.value)
+			continue
+		}
+		require.NoError(t, err, test.value)
+		require.Equal(t, test.expected, packed, test.value)
+		require.LessOrEqual(t, uint64(packed.Mantisa), maxMantisa)
+		switch test.mode {
+		case RoundExact:
+			require.Equal(t, x, packed.Big())
+		case RoundDown:
+			require.True(t, packed.Big().Cmp(x) <= 0)
+		case RoundUp:
+			require.True(t, packed.Big().Cmp(x) >= 0)
+		}
+	}
+
+	maxAmount := new(big.Int).Mul(new(big.Int).SetUint64(maxMantisa), new(big.Int).Exp(big.NewInt(10), big.NewInt(255), nil))
+	_, err := PackAmount(maxAmount, RoundExact)
+	require.NoError(t, err)
+	_, err = PackAmount(new(big.Int).Add(maxAmount, big.NewInt(1)), RoundDown)
+	require.NoError(t, err)
+	_, err = PackAmount(new(big.Int).Add(maxAmount, big.NewInt(1)), RoundUp)
+	require.True(t, errors.Is(err, ErrOverflow))
+}
+
+func TestPackFee(t *testing.T) {
+	tests := []struct {
+		value    int64
+		mode     RoundingMode
+		expected PackedFee
+		err      error
+	}{
+		{0, RoundExact, PackedFee{0, 0}, nil},
+		{340, RoundExact, PackedFee{34, 1}, nil},
+		{1023, RoundExact, PackedFee{1023, 0}, nil},
+		{1024, RoundExact, PackedFee{}, ErrInexact},
+		{1024, RoundDown, PackedFee{102, 1}, nil},
+		{1024, RoundUp, PackedFee{103, 1}, nil},
+		{10230, RoundUp, PackedFee{1023, 1}, nil},
+		{10231, RoundUp, PackedFee{103, 2}, nil},
+	}
+	for _, test := range tests {
+		packed, err := PackFee(big.NewInt(test.value), test.mode)
+		if test.err != nil {
+			require.True(t, errors.Is(err, test.err), test.value)
+			continue
+		}
+		require.NoError(t, err, test.value)
+		require.Equal(t, test.expected, packed, test.value)
+		require.NoError(t, packed.Validate())
+	}
+
+	maxFee := new(big.Int).Mul(big.NewInt(1023), new(big.Int).Exp(big.NewInt(10), big.NewInt(63), nil))
+	_, err := PackFee(maxFee, RoundExact)
+	require.NoError(t, err)
+	_, err = PackFee(new(big.Int).Mul(maxFee, big.NewInt(10)), RoundDown)
+	require.True(t, errors.Is(err, ErrOverflow))
+}
+
+func TestPackedFee_Validate(t *testing.T) {
+	require.NoError(t, (&PackedFee{Mantisa: 1023, Exp: 63}).Validate())
+	require.True(t, errors.Is((&PackedFee{Mantisa: 1024}).Validate(), ErrOverflow))
+	require.True(t, errors.Is((&PackedFee{Exp: 64}).Validate(), ErrOverflow))
+	require.Panics(t, func() { (&PackedFee{Mantisa: 1024}).toBytes() })
+}
@@ -0,0 +1,412 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+)
+
+// Method returns the name of the contract method a step of this type calls
+func (t StepType) Method() string {
+	switch t {
+	case SubmitBlock:
+		return "submitBlock"
+	case AccuseBlockFraudProof:
+		return "accuseBlockFraudProof"
+	case SubmitDeposit:
+		return "submitDeposit"
+	case CompleteWithdraw:
+		return "completeWithdraw"
+	case SubmitExit:
+		return "submitExit"
+	case CompleteExit:
+		return "completeExit"
+	case SubmitDepositToNew:
+		return "submitDepositToNew"
+	case AccuseCommitmentFraudProof:
+		return "accuseCommitmentFraudProof"
+	default:
+		return ""
+	}
+}
+
+// Pack returns the calldata (selector and arguments) of the contract call made by the step.
+// Each ABI input is filled from the field of step.Data with the same name, ignoring case and underscores.
+func (s *Step) Pack(contract *abi.ABI) ([]byte, error) {
+	name := s.Action.Method()
+	method, ok := contract.Methods[name]
+	if !ok {
+		return nil, fmt.Errorf("method %q not found in abi", name)
+	}
+	args, err := PackArgs(method.Inputs, s.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return append(append([]byte{}, method.ID...), args...), nil
+}
+
+// PackArgs encodes the fields of data as args. data is a struct, a pointer to struct or a map[string]interface{}.
+func PackArgs(args abi.Arguments, data interface{}) ([]byte, error) {
+	var values []interface{}
+	for _, arg := range args {
+		field, ok := fieldByName(reflect.ValueOf(data), arg.Name)
+		if !ok {
+			return nil, fmt.Errorf("no value for argument %q", arg.Name)
+		}
+		value, err := convertArg(field, arg.Type)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", arg.Name, err)
+		}
+		values = append(values, value.Interface())
+	}
+	return args.Pack(values...)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+func fieldByName(v reflect.Value, name string) (reflect.Value, bool) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if normalizeName(key.String()) == normalizeName(name) {
+				return v.MapIndex(key), true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if normalizeName(v.Type().Field(i).Name) == normalizeName(name) {
+				return v.Field(i), true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// CallAction returns the type of the steps calling the contract method of calldata
+func CallAction(contract *abi.ABI, calldata []byte) (StepType, bool) {
+	for t := NoOp; t <= CheckBlockRoots; t++ {
+		method, ok := contract.Methods[t.Method()]
+		if ok && len(calldata) >= len(method.ID) && bytes.Equal(calldata[:len(method.ID)], method.ID) {
+			return t, true
+		}
+	}
+	return NoOp, false
+}
+
+// UnpackCall decodes the calldata of the contract call made by the steps of type action into the fields of out,
+// a pointer to struct, with the same name as the ABI inputs ignoring case and underscores, like PackArgs.
+// The fields without input are left unchanged.
+func UnpackCall(contract *abi.ABI, action StepType, calldata []byte, out interface{}) error {
+	method, ok := contract.Methods[action.Method()]
+	if !ok {
+		return fmt.Errorf("method %q not found in abi", action.Method())
+	}
+	if len(calldata) < len(method.ID) || !bytes.Equal(calldata[:len(method.ID)], method.ID) {
+		return fmt.Errorf("not a call to %s", method.Sig)
+	}
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, calldata[len(method.ID):]); err != nil {
+		return err
+	}
+	for name, value := range args {
+		field, ok := fieldByName(reflect.ValueOf(out), name)
+		if !ok {
+			continue
+		}
+		if err := setArg(field, reflect.ValueOf(value)); err != nil {
+			return fmt.Errorf("argument %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setArg sets dst to v, a value unpacked by the abi package
+func setArg(dst, v reflect.Value) error {
+	if x, ok := v.Interface().(*big.Int); ok {
+		switch {
+		case dst.Type() == bigType:
+			dst.Set(reflect.ValueOf(new(big.Int).Set(x)))
+			return nil
+		case dst.Kind() >= reflect.Uint && dst.Kind() <= reflect.Uint64:
+			if !x.IsUint64() || dst.OverflowUint(x.Uint64()) {
+				return fmt.Errorf("%s overflows %v", x, dst.Type())
+			}
+			dst.SetUint(x.Uint64())
+			return nil
+		}
+	}
+	switch dst.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+			if dst.OverflowUint(v.Uint()) {
+				return fmt.Errorf("%d overflows %v", v.Uint(), dst.Type())
+			}
+			dst.SetUint(v.Uint())
+			return nil
+		}
+	case reflect.Bool:
+		if v.Kind() == reflect.Bool {
+			dst.SetBool(v.Bool())
+			return nil
+		}
+	case reflect.Array:
+		if b, ok := toBytes(v); ok && dst.Type().Elem().Kind() == reflect.Uint8 && len(b) == dst.Len() {
+			reflect.Copy(dst, reflect.ValueOf(b))
+			return nil
+		}
+	case reflect.Slice:
+		if b, ok := toBytes(v); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			out := reflect.MakeSlice(dst.Type(), v.Len(), v.Len())
+			for i := 0; i < v.Len(); i++ {
+				if err := setArg(out.Index(i), v.Index(i)); err != nil {
+					return fmt.Errorf("element %d: %w", i, err)
+				}
+			}
+			dst.Set(out)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use %v as %v", v.Type(), dst.Type())
+}
+
+// UnpackSubmitBlock decodes the calldata of a submitBlock call, see UnpackCall
+func UnpackSubmitBlock(contract *abi.ABI, calldata []byte) (*blockchain.SubmittedBlock, error) {
+	var blk blockchain.SubmittedBlock
+	if err := UnpackCall(contract, SubmitBlock, calldata, &blk); err != nil {
+		return nil, err
+	}
+	if len(blk.MiniBlocks) == 0 {
+		return nil, fmt.Errorf("submitBlock without miniblocks")
+	}
+	return &blk, nil
+}
+
+var bigType = reflect.TypeOf(&big.Int{})
+
+// convertArg converts a value of the test suit into the go type the abi package packs as t
+func convertArg(v reflect.Value, t abi.Type) (reflect.Value, error) {
+	if !v.IsValid() {
+		return reflect.Value{}, fmt.Errorf("missing value")
+	}
+	switch x := v.Interface().(type) {
+	case *big.Int:
+		return convertBig(x, t)
+	case types.PackedAmount:
+		return convertBig(x.Big(), t)
+	case *types.PackedAmount:
+		return convertBig(x.Big(), t)
+	case types.PackedFee:
+		return convertBig(x.Big(), t)
+	case *types.PackedFee:
+		return convertBig(x.Big(), t)
+	}
+
+	v = indirect(v)
+	switch t.T {
+	case abi.UintTy, abi.IntTy:
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return convertBig(new(big.Int).SetUint64(v.Uint()), t)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return convertBig(big.NewInt(v.Int()), t)
+		}
+	case abi.BoolTy:
+		if v.Kind() == reflect.Bool {
+			return v, nil
+		}
+	case abi.AddressTy:
+		if address, ok := v.Interface().(common.Address); ok {
+			return reflect.ValueOf(address), nil
+		}
+	case abi.FixedBytesTy:
+		if b, ok := toBytes(v); ok && len(b) == t.Size {
+			out := reflect.New(t.GetType()).Elem()
+			reflect.Copy(out, reflect.ValueOf(b))
+			return out, nil
+		}
+	case abi.BytesTy:
+		if b, ok := toBytes(v); ok {
+			return reflect.ValueOf(b), nil
+		}
+	case abi.SliceTy, abi.ArrayTy:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			break
+		}
+		if t.T == abi.ArrayTy && v.Len() != t.Size {
+			return reflect.Value{}, fmt.Errorf("expect %d elements, got %d", t.Size, v.Len())
+		}
+		out := reflect.MakeSlice(reflect.SliceOf(t.Elem.GetType()), v.Len(), v.Len())
+		if t.T == abi.ArrayTy {
+			out = reflect.New(t.GetType()).Elem()
+		}
+		for i := 0; i < v.Len(); i++ {
+			elem, err := convertArg(v.Index(i), *t.Elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+	case abi.TupleTy:
+		out := reflect.New(t.TupleType).Elem()
+		for i, name := range t.TupleRawNames {
+			field, ok := fieldByName(v, name)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("no value for tuple field %q", name)
+			}
+			elem, err := convertArg(field, *t.TupleElems[i])
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("tuple field %q: %w", name, err)
+			}
+			out.Field(i).Set(elem)
+		}
+		return out, nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %v as %s", v.Type(), t.String())
+}
+
+func convertBig(x *big.Int, t abi.Type) (reflect.Value, error) {
+	if t.T != abi.UintTy && t.T != abi.IntTy {
+		return reflect.Value{}, fmt.Errorf("cannot use number as %s", t.String())
+	}
+	if x.Sign() < 0 || x.BitLen() > t.Size {
+		return reflect.Value{}, fmt.Errorf("%s overflows %s", x.String(), t.String())
+	}
+	if t.GetType() == bigType {
+		return reflect.ValueOf(new(big.Int).Set(x)), nil
+	}
+	return reflect.ValueOf(x.Uint64()).Convert(t.GetType()), nil
+}
+
+// toBytes returns the raw bytes of byte slices, byte arrays and types with a Bytes method
+// such as common.Hash, *types.MiniBlock or *blockchain.StateData
+func toBytes(v reflect.Value) ([]byte, bool) {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return v.Bytes(), true
+	}
+	if v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 {
+		out := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(out), v)
+		return out, true
+	}
+	if !v.CanAddr() {
+		tmp := reflect.New(v.Type())
+		tmp.Elem().Set(v)
+		v = tmp.Elem()
+	}
+	method := v.Addr().MethodByName("Bytes")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil, false
+	}
+	out := method.Call(nil)[0]
+	if out.Kind() != reflect.Slice || out.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+// ABIEnv is the environment variable holding the path of the contract ABI used by EncodeCalldataFromEnv
+const ABIEnv = "L2_CONTRACT_ABI"
+
+// LoadABI reads a contract ABI from a JSON file holding either the ABI itself
+// or a compiler artifact with an "abi" field
+func LoadABI(path string) (*abi.ABI, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var artifact struct {
+			ABI json.RawMessage `json:"abi"`
+		}
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return nil, fmt.Errorf("invalid artifact %s: %w", path, err)
+		}
+		data = artifact.ABI
+	}
+	contractABI, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid abi %s: %w", path, err)
+	}
+	return &contractABI, nil
+}
+
+// EncodeCalldata sets the calldata of every step of the suit which calls the contract
+func (s *Suit) EncodeCalldata(contract *abi.ABI) error {
+	for i := range s.Steps {
+		if s.Steps[i].Action.Method() == "" {
+			continue
+		}
+		calldata, err := s.Steps[i].Pack(contract)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		s.Steps[i].Calldata = calldata
+	}
+	return nil
+}
+
+// EncodeCalldataFromEnv encodes the calldata of suits with the ABI file set by L2_CONTRACT_ABI.
+// It does nothing if the variable is not set.
+func EncodeCalldataFromEnv(suits ...*Suit) error {
+	path := os.Getenv(ABIEnv)
+	if path == "" {
+		return nil
+	}
+	contract, err := LoadABI(path)
+	if err != nil {
+		return err
+	}
+	for _, suit := range suits {
+		if err := suit.EncodeCalldata(contract); err != nil {
+			return fmt.Errorf("%s: %w", suit.Msg, err)
+		}
+	}
+	return nil
+}
+
+// CalldataFromEnv returns the calldata of the contract calls made by steps with the ABI file set by L2_CONTRACT_ABI,
+// for the suits of the generators which are not a Suit. It returns nil if the variable is not set.
+func CalldataFromEnv(steps ...Step) ([]hexutil.Bytes, error) {
+	suit := &Suit{Steps: steps}
+	if os.Getenv(ABIEnv) == "" {
+		return nil, nil
+	}
+	if err := EncodeCalldataFromEnv(suit); err != nil {
+		return nil, err
+	}
+	var calldata []hexutil.Bytes
+	for _, step := range suit.Steps {
+		if step.Calldata != nil {
+			calldata = append(calldata, step.Calldata)
+		}
+	}
+	return calldata, nil
+}
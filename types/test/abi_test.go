@@ -0,0 +1,83 @@
+package test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+)
+
+const testABI = `[
+  {"type": "function", "name": "submitBlock", "inputs": [
+    {"name": "_blockNumber", "type": "uint32"},
+    {"name": "_miniBlocks", "type": "bytes[]"},
+    {"name": "_timestamp", "type": "uint32"}
+  ]},
+  {"type": "function", "name": "completeExit", "inputs": [
+    {"name": "accountID", "type": "uint32"},
+    {"name": "tokenIDs", "type": "uint16[]"},
+    {"name": "tokenAmounts", "type": "uint256[]"},
+    {"name": "siblings", "type": "bytes32[]"}
+  ]}
+]`
+
+func TestStep_Pack(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(testABI))
+	require.NoError(t, err)
+
+	miniBlock := &types.MiniBlock{
+		Txs:        []types.Transaction{&types.DepositOp{DepositID: 5, Amount: big.NewInt(1)}},
+		StateHash:  common.HexToHash("0x1234"),
+		Commitment: common.HexToHash("0x5678"),
+	}
+	step := Step{Action: SubmitBlock, Data: SubmitBlockStep{
+		BlockNumber: 3,
+		MiniBlocks:  []*types.MiniBlock{miniBlock},
+		Timestamp:   1600661872,
+	}}
+	data, err := step.Pack(&contractABI)
+	require.NoError(t, err)
+	expected, err := contractABI.Pack("submitBlock", uint32(3), [][]byte{miniBlock.Bytes()}, uint32(1600661872))
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	submitted, err := UnpackSubmitBlock(&contractABI, data)
+	require.NoError(t, err)
+	require.Equal(t, &blockchain.SubmittedBlock{
+		BlockNumber: 3,
+		MiniBlocks:  []hexutil.Bytes{miniBlock.Bytes()},
+		Timestamp:   1600661872,
+	}, submitted)
+
+	step = Step{Action: CompleteExit, Data: &CompleteExitStep{
+		AccountID:    36,
+		TokenIDs:     []uint16{2, 4},
+		TokenAmounts: []*big.Int{big.NewInt(45242000), big.NewInt(135000)},
+		Siblings:     []common.Hash{common.HexToHash("0x1"), common.HexToHash("0x2")},
+	}}
+	data, err = step.Pack(&contractABI)
+	require.NoError(t, err)
+	expected, err = contractABI.Pack("completeExit", uint32(36), []uint16{2, 4},
+		[]*big.Int{big.NewInt(45242000), big.NewInt(135000)},
+		[][32]byte{common.HexToHash("0x1"), common.HexToHash("0x2")})
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	var completeExit CompleteExitStep
+	require.NoError(t, UnpackCall(&contractABI, CompleteExit, data, &completeExit))
+	require.Equal(t, step.Data, &completeExit)
+	action, ok := CallAction(&contractABI, data)
+	require.True(t, ok)
+	require.Equal(t, CompleteExit, action)
+
+	step = Step{Action: SubmitExit, Data: &SubmitExitStep{}}
+	_, err = step.Pack(&contractABI)
+	require.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"sort"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+)
+
+// NewExodusSteps returns the steps taking every fund out of the state after block blockNumber of r, as users do when the
+// operator stops: a submitExit for every account not exited yet, then a completeExit of the non zero tokens of every account
+func NewExodusSteps(r *blockchain.Rollup, blockNumber uint32) ([]Step, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	state, err := r.StateAfterBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	dump, err := state.Export()
+	if err != nil {
+		return nil, err
+	}
+	var accountIDs []uint32
+	for accountID := range dump.Accounts {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Slice(accountIDs, func(i, j int) bool { return accountIDs[i] < accountIDs[j] })
+
+	var submitSteps, completeSteps []Step
+	for _, accountID := range accountIDs {
+		account := dump.Accounts[accountID]
+		if !account.IsConfirmedExit {
+			submitSteps = append(submitSteps, Step{Action: SubmitExit, Data: newSubmitExitStep(state, blk, accountID)})
+		}
+		var tokenIDs []uint16
+		for tokenID, amount := range account.Tokens {
+			if amount.Sign() > 0 {
+				tokenIDs = append(tokenIDs, tokenID)
+			}
+		}
+		if len(tokenIDs) == 0 {
+			continue
+		}
+		sort.Slice(tokenIDs, func(i, j int) bool { return tokenIDs[i] < tokenIDs[j] })
+		completeSteps = append(completeSteps, Step{Action: CompleteExit, Data: NewCompleteExitStep(state, accountID, tokenIDs)})
+	}
+	return append(submitSteps, completeSteps...), nil
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+)
+
+func TestNewExodusSteps(t *testing.T) {
+	bc := blockchain.NewBlockchain(&blockchain.Genesis{
+		AccountAlloc: map[uint32]blockchain.GenesisAccount{
+			0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+			3: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0x052f46FeB45822E7f117536386C51B6Bd3125157")},
+			8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+		},
+		AccountMax: 100,
+	}, nil)
+	r := blockchain.NewRollup(bc)
+	_, err := r.AddBlock([]*types.MiniBlock{
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}}},
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 0, TokenID: 1, Amount: big.NewInt(7)}}},
+	}, 1600661872)
+	require.NoError(t, err)
+	blk, err := r.AddBlock([]*types.MiniBlock{
+		{Txs: []types.Transaction{&types.ExitOp{AccountID: 8}}},
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 0, TokenID: 1, Amount: big.NewInt(5)}}},
+		{Txs: []types.Transaction{&types.DepositOp{AccountID: 0, TokenID: 4, Amount: big.NewInt(9)}}},
+	}, 1600661900)
+	require.NoError(t, err)
+	// a later block the exodus does not see
+	_, err = r.AddBlock([]*types.MiniBlock{{Txs: []types.Transaction{&types.DepositOp{AccountID: 3, TokenID: 1, Amount: big.NewInt(1)}}}}, 1600661950)
+	require.NoError(t, err)
+
+	steps, err := NewExodusSteps(r, 2)
+	require.NoError(t, err)
+	require.Len(t, steps, 4)
+
+	// account 8 exited in block 2
+	state, err := r.StateAfterBlock(2)
+	require.NoError(t, err)
+	for i, accountID := range []uint32{0, 3} {
+		require.Equal(t, SubmitExit, steps[i].Action)
+		step := steps[i].Data.(SubmitExitStep)
+		balanceRoot, exitProof := state.BuildSubmitExitProof(accountID)
+		require.Equal(t, accountID, step.AccountID)
+		require.Equal(t, balanceRoot, step.BalanceRoot)
+		require.Equal(t, uint32(2), step.BlockNumber)
+		require.Equal(t, uint32(1600661900), step.Timestamp)
+		require.Equal(t, []byte(exitProof), []byte(step.Proof[:len(exitProof)]))
+		// the block info of the 3 miniblocks of block 2
+		blockInfo := append(blk.Header.BlockInfoHash.Bytes(), util.Uint8ToByte(3))
+		require.Equal(t, blockInfo, []byte(step.Proof[len(exitProof):]))
+		require.NotEqual(t, blk.MiniBlocks[0].Hash(), blk.Header.BlockInfoHash)
+	}
+
+	require.Equal(t, CompleteExit, steps[2].Action)
+	require.Equal(t, uint32(0), steps[2].Data.(CompleteExitStep).AccountID)
+	require.Equal(t, []uint16{1, 4}, steps[2].Data.(CompleteExitStep).TokenIDs)
+	require.Equal(t, "12", steps[2].Data.(CompleteExitStep).TokenAmounts[0].String())
+	require.Equal(t, CompleteExit, steps[3].Action)
+	require.Equal(t, NewCompleteExitStep(state, 8, []uint16{0, 2}), steps[3].Data)
+
+	_, err = NewExodusSteps(r, 4)
+	require.Error(t, err)
+}
@@ -27,11 +27,17 @@ const (
 	CompleteExit
 	SubmitDepositToNew
 	AccuseCommitmentFraudProof
+	// CheckBlockRoots is not a contract call, it checks the block roots stored by the contract
+	CheckBlockRoots
 )
 
 type Step struct {
 	Action StepType
 	Data   interface{}
+	// Revert is set when the contract is expected to reject the step
+	Revert bool `json:",omitempty"`
+	// Calldata is the ABI-encoded call of the step, see Suit.EncodeCalldata
+	Calldata hexutil.Bytes `json:",omitempty"`
 }
 
 type SubmitBlockStep struct {
@@ -58,6 +64,12 @@ type AccuseBlockFraudProofStep struct {
 	ExecutionProof     []hexutil.Bytes
 }
 
+// CheckBlockRootsStep holds the expected roots of block 1 to len(BlockRoots),
+// later blocks are expected to be reverted
+type CheckBlockRootsStep struct {
+	BlockRoots []common.Hash
+}
+
 type CompleteExitStep struct {
 	AccountID    uint32
 	TokenIDs     []uint16
@@ -73,3 +85,150 @@ type AccuseCommitmentFraudProofStep struct {
 	MiniBlockProof   hexutil.Bytes
 	CommitmentProofs []hexutil.Bytes
 }
+
+// NewSubmitBlockStep returns the step submitting a block executed by a blockchain.Rollup
+func NewSubmitBlockStep(blk *blockchain.Block) SubmitBlockStep {
+	return SubmitBlockStep{
+		BlockNumber: blk.Header.BlockNumber,
+		MiniBlocks:  blk.MiniBlocks,
+		Timestamp:   blk.Header.Timestamp,
+	}
+}
+
+// NewAccuseBlockFraudProofStep returns the step accusing a miniblock of a block executed by r
+func NewAccuseBlockFraudProofStep(r *blockchain.Rollup, blockNumber uint32, miniBlockIndex uint) (AccuseBlockFraudProofStep, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return AccuseBlockFraudProofStep{}, err
+	}
+	miniBlockProof, err := r.MiniBlockProof(blockNumber, miniBlockIndex)
+	if err != nil {
+		return AccuseBlockFraudProofStep{}, err
+	}
+	prevStateHashProof, err := r.PrevStateHashProof(blockNumber, miniBlockIndex)
+	if err != nil {
+		return AccuseBlockFraudProofStep{}, err
+	}
+	return AccuseBlockFraudProofStep{
+		BlockNumber:        uint(blockNumber),
+		MiniBlockNumber:    miniBlockIndex,
+		MiniBlock:          blk.MiniBlocks[miniBlockIndex],
+		PrevStateData:      blk.PrevStateData[miniBlockIndex],
+		MiniBlockProof:     miniBlockProof,
+		PrevStateHashProof: prevStateHashProof,
+		ExecutionProof:     blk.ExecutionProofs[miniBlockIndex],
+	}, nil
+}
+
+// NewAccuseCommitmentFraudProofStep returns the step accusing the commitment of a miniblock of a block executed by r,
+// proven against the state right after the miniblock
+func NewAccuseCommitmentFraudProofStep(r *blockchain.Rollup, blockNumber uint32, miniBlockIndex uint) (AccuseCommitmentFraudProofStep, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return AccuseCommitmentFraudProofStep{}, err
+	}
+	miniBlockProof, err := r.MiniBlockProof(blockNumber, miniBlockIndex)
+	if err != nil {
+		return AccuseCommitmentFraudProofStep{}, err
+	}
+	state, err := r.StateAfterMiniBlock(blockNumber, miniBlockIndex)
+	if err != nil {
+		return AccuseCommitmentFraudProofStep{}, err
+	}
+	return AccuseCommitmentFraudProofStep{
+		BlockNumber:      uint(blockNumber),
+		MiniBlockNumber:  miniBlockIndex,
+		MiniBlock:        blk.MiniBlocks[miniBlockIndex],
+		PostStateData:    state.GetStateData(),
+		MiniBlockProof:   miniBlockProof,
+		CommitmentProofs: []hexutil.Bytes{state.BuildCommitmentProof(blk.MiniBlocks[miniBlockIndex])},
+	}, nil
+}
+
+// NewSubmitMiniBlockSteps returns the steps submitting the deposits of miniBlock, then miniBlock alone as block blockNumber
+func NewSubmitMiniBlockSteps(miniBlock *types.MiniBlock, blockNumber, timestamp uint32) []Step {
+	var steps []Step
+	for _, tx := range miniBlock.Txs {
+		switch tx.(type) {
+		case *types.DepositOp:
+			steps = append(steps, Step{Action: SubmitDeposit, Data: tx})
+		case *types.DepositToNewOp:
+			steps = append(steps, Step{Action: SubmitDepositToNew, Data: tx})
+		}
+	}
+	return append(steps, Step{Action: SubmitBlock, Data: SubmitBlockStep{
+		BlockNumber: blockNumber,
+		MiniBlocks:  []*types.MiniBlock{miniBlock},
+		Timestamp:   timestamp,
+	}})
+}
+
+// NewFraudProofSteps returns the steps of a fraud proof suit: blocks are submitted as blocks 1, 2, ... in order,
+// and a block with a Proof is accused right after it is submitted
+func NewFraudProofSteps(blocks ...blockchain.BlockData) []Step {
+	var steps []Step
+	for i, blk := range blocks {
+		steps = append(steps, Step{Action: SubmitBlock, Data: SubmitBlockStep{
+			BlockNumber: uint32(i + 1),
+			MiniBlocks:  blk.MiniBlocks,
+			Timestamp:   blk.Timestamp,
+		}})
+		if blk.Proof == nil {
+			continue
+		}
+		steps = append(steps, Step{Action: AccuseBlockFraudProof, Data: AccuseBlockFraudProofStep{
+			BlockNumber:        uint(i + 1),
+			MiniBlockNumber:    uint(blk.MiniBlockNumber),
+			MiniBlock:          blk.MiniBlocks[blk.MiniBlockNumber],
+			PrevStateData:      blk.Proof.PrevStateData,
+			MiniBlockProof:     blk.Proof.MiniBlockProof,
+			PrevStateHashProof: blk.Proof.PrevStateHashProof,
+			ExecutionProof:     blk.Proof.ExecutionProof,
+		}})
+	}
+	return steps
+}
+
+// NewSubmitExitStep returns the step submitting the exit of an account, proven against the state after block blockNumber of r
+func NewSubmitExitStep(r *blockchain.Rollup, blockNumber uint32, accountID uint32) (SubmitExitStep, error) {
+	blk, err := r.Block(blockNumber)
+	if err != nil {
+		return SubmitExitStep{}, err
+	}
+	state, err := r.StateAfterBlock(blockNumber)
+	if err != nil {
+		return SubmitExitStep{}, err
+	}
+	return newSubmitExitStep(state, blk, accountID), nil
+}
+
+// newSubmitExitStep returns the step submitting the exit of an account, state is the state after blk
+func newSubmitExitStep(state *blockchain.Blockchain, blk *blockchain.Block, accountID uint32) SubmitExitStep {
+	balanceRoot, exitProof := state.BuildSubmitExitProof(accountID)
+	// the block info of blk, hashed into its root with the timestamp and number the step holds
+	exitProof = append(exitProof, blk.Header.BlockInfoHash.Bytes()...)
+	exitProof = append(exitProof, blk.Header.NumMiniBlocks)
+	return SubmitExitStep{
+		AccountID:   accountID,
+		BalanceRoot: balanceRoot,
+		Timestamp:   blk.Header.Timestamp,
+		BlockNumber: blk.Header.BlockNumber,
+		Proof:       exitProof,
+	}
+}
+
+// NewCompleteExitStep returns the step completing the exit of an account with the balances of tokenIDs in bc
+func NewCompleteExitStep(bc *blockchain.Blockchain, accountID uint32, tokenIDs []uint16) CompleteExitStep {
+	step := CompleteExitStep{AccountID: accountID, TokenIDs: tokenIDs}
+	step.TokenAmounts, step.Siblings = bc.BuildCompleteExit(accountID, tokenIDs)
+	return step
+}
+
+// NewCheckBlockRootsStep returns the step checking the roots of every block of r
+func NewCheckBlockRootsStep(r *blockchain.Rollup) CheckBlockRootsStep {
+	step := CheckBlockRootsStep{BlockRoots: []common.Hash{}}
+	for i := uint32(1); i <= r.BlockNumber(); i++ {
+		step.BlockRoots = append(step.BlockRoots, r.BlockRoot(i))
+	}
+	return step
+}
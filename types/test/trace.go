@@ -0,0 +1,51 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+)
+
+// TraceEnv is the format of the execution traces written next to the suits, json or table
+const TraceEnv = "L2_TRACE"
+
+// TracerFromEnv returns the tracer set by L2_TRACE, writing to the file of suitPath with the extension
+// .trace.jsonl or .trace.txt, and the function closing it.
+// It returns a nil tracer if the variable is not set.
+func TracerFromEnv(suitPath string) (blockchain.Tracer, func() error, error) {
+	var (
+		format    = os.Getenv(TraceEnv)
+		extension string
+		newTracer func(f *os.File) blockchain.Tracer
+	)
+	switch format {
+	case "":
+		return nil, func() error { return nil }, nil
+	case "json":
+		extension = ".trace.jsonl"
+		newTracer = func(f *os.File) blockchain.Tracer { return blockchain.NewJSONTracer(f) }
+	case "table":
+		extension = ".trace.txt"
+		newTracer = func(f *os.File) blockchain.Tracer { return blockchain.NewTableTracer(f) }
+	default:
+		return nil, nil, fmt.Errorf("unknown %s %q, expect json or table", TraceEnv, format)
+	}
+	f, err := os.Create(strings.TrimSuffix(suitPath, ".json") + extension)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newTracer(f), f.Close, nil
+}
+
+// Trace calls build with the tracer of suitPath, see TracerFromEnv, and closes it once build returns.
+// A generator sets it on every blockchain build makes, the tracer is nil if L2_TRACE is not set.
+func Trace(suitPath string, build func(tracer blockchain.Tracer)) error {
+	tracer, closeTrace, err := TracerFromEnv(suitPath)
+	if err != nil {
+		return err
+	}
+	build(tracer)
+	return closeTrace()
+}
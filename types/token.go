@@ -0,0 +1,251 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	util "github.com/KyberNetwork/l2-contract-test-suite/common"
+)
+
+// Token is a token listed by the contract, amounts of the token in the rollup are in base units
+type Token struct {
+	ID       uint16
+	Symbol   string
+	Decimals uint8
+	Address  common.Address
+}
+
+// ParseUnits converts a decimal number of tokens, e.g. "1.5", to base units
+func (t *Token) ParseUnits(s string) (*big.Int, error) {
+	x, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if x.Sign() < 0 {
+		return nil, fmt.Errorf("amount %s: %w", s, ErrNegative)
+	}
+	x = x.Mul(x, new(big.Rat).SetInt(t.unit()))
+	if !x.IsInt() {
+		return nil, fmt.Errorf("amount %s %s has more than %d decimals", s, t.Symbol, t.Decimals)
+	}
+	return new(big.Int).Set(x.Num()), nil
+}
+
+// FormatUnits returns the decimal number of tokens of x base units, without trailing zeros
+func (t *Token) FormatUnits(x *big.Int) string {
+	s := new(big.Rat).SetFrac(x, t.unit()).FloatString(int(t.Decimals))
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// Format returns x base units as tokens followed by the symbol, e.g. "1.5 ETH"
+func (t *Token) Format(x *big.Int) string {
+	return t.FormatUnits(x) + " " + t.Symbol
+}
+
+func (t *Token) unit() *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Decimals)), nil)
+}
+
+// TokenRegistry maps token ids of the rollup to the listed tokens
+type TokenRegistry struct {
+	tokens   map[uint16]*Token
+	bySymbol map[string]*Token
+}
+
+func NewTokenRegistry(tokens []Token) (*TokenRegistry, error) {
+	r := &TokenRegistry{
+		tokens:   make(map[uint16]*Token),
+		bySymbol: make(map[string]*Token),
+	}
+	for i := range tokens {
+		token := tokens[i]
+		if err := checkBits("token id", uint64(token.ID), TokenIDBits); err != nil {
+			return nil, err
+		}
+		if token.Symbol == "" || strings.ContainsAny(token.Symbol, " /") {
+			return nil, fmt.Errorf("invalid symbol %q of token %d", token.Symbol, token.ID)
+		}
+		if _, ok := r.tokens[token.ID]; ok {
+			return nil, fmt.Errorf("duplicate token id %d", token.ID)
+		}
+		if _, ok := r.bySymbol[token.Symbol]; ok {
+			return nil, fmt.Errorf("duplicate token symbol %s", token.Symbol)
+		}
+		r.tokens[token.ID] = &token
+		r.bySymbol[token.Symbol] = &token
+	}
+	return r, nil
+}
+
+// LoadTokenRegistry reads a registry from a JSON list of tokens
+func LoadTokenRegistry(path string) (*TokenRegistry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token registry %s: %w", path, err)
+	}
+	return NewTokenRegistry(tokens)
+}
+
+// Tokens returns every token ordered by id
+func (r *TokenRegistry) Tokens() []Token {
+	var tokens []Token
+	for _, token := range r.tokens {
+		tokens = append(tokens, *token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens
+}
+
+func (r *TokenRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Tokens())
+}
+
+func (r *TokenRegistry) UnmarshalJSON(data []byte) error {
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+	registry, err := NewTokenRegistry(tokens)
+	if err != nil {
+		return err
+	}
+	*r = *registry
+	return nil
+}
+
+func (r *TokenRegistry) ByID(id uint16) (*Token, bool) {
+	token, ok := r.tokens[id]
+	return token, ok
+}
+
+func (r *TokenRegistry) BySymbol(symbol string) (*Token, bool) {
+	token, ok := r.bySymbol[symbol]
+	return token, ok
+}
+
+func (r *TokenRegistry) bySymbolErr(symbol string) (*Token, error) {
+	token, ok := r.bySymbol[symbol]
+	if !ok {
+		return nil, fmt.Errorf("unknown token %s", symbol)
+	}
+	return token, nil
+}
+
+// ParseAmount parses an amount of tokens followed by the symbol, e.g. "1.5 ETH"
+func (r *TokenRegistry) ParseAmount(s string) (*Token, *big.Int, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return nil, nil, fmt.Errorf("invalid amount %q, expect \"<amount> <symbol>\"", s)
+	}
+	token, err := r.bySymbolErr(fields[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	amount, err := token.ParseUnits(fields[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, amount, nil
+}
+
+// FormatAmount returns an amount of token id in token units, or in base units if the token is unknown
+func (r *TokenRegistry) FormatAmount(id uint16, x *big.Int) string {
+	token, ok := r.tokens[id]
+	if !ok {
+		return fmt.Sprintf("%s of token %d", x.String(), id)
+	}
+	return token.Format(x)
+}
+
+// OrderSpec is an order selling Amount base units of Src for Dst at Rate,
+// the amount of base units of Dst per base unit of Src scaled by 1e18
+type OrderSpec struct {
+	Src    *Token
+	Dst    *Token
+	Amount *big.Int
+	Rate   *big.Rat
+}
+
+// ParseOrder parses an order written as "<amount> <src> at <price> <quote>/<base>", e.g. "1.5 ETH at 2000 USDT/ETH".
+// The price is the number of quote tokens per base token, the src token is either the base or the quote.
+func (r *TokenRegistry) ParseOrder(s string) (*OrderSpec, error) {
+	parts := strings.Split(s, " at ")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid order %q, expect \"<amount> <src> at <price> <quote>/<base>\"", s)
+	}
+	src, amount, err := r.ParseAmount(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(parts[1])
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid price %q, expect \"<price> <quote>/<base>\"", parts[1])
+	}
+	price, ok := new(big.Rat).SetString(fields[0])
+	if !ok || price.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid price %q", fields[0])
+	}
+	pair := strings.Split(fields[1], "/")
+	if len(pair) != 2 {
+		return nil, fmt.Errorf("invalid pair %q, expect \"<quote>/<base>\"", fields[1])
+	}
+	quote, err := r.bySymbolErr(pair[0])
+	if err != nil {
+		return nil, err
+	}
+	base, err := r.bySymbolErr(pair[1])
+	if err != nil {
+		return nil, err
+	}
+
+	var dst *Token
+	switch src {
+	case base:
+		dst = quote
+	case quote:
+		dst = base
+		price = price.Inv(price)
+	default:
+		return nil, fmt.Errorf("token %s is not in pair %s", src.Symbol, fields[1])
+	}
+	// dst base units per src base unit
+	rate := new(big.Rat).Mul(price, new(big.Rat).SetFrac(dst.unit(), src.unit()))
+	rate = rate.Mul(rate, new(big.Rat).SetInt(util.Precision))
+	return &OrderSpec{Src: src, Dst: dst, Amount: amount, Rate: rate}, nil
+}
+
+// Pack returns the packed amount and rate of o, rounded with mode
+func (o *OrderSpec) Pack(mode RoundingMode) (amount PackedAmount, rate PackedAmount, err error) {
+	if amount, err = PackAmount(o.Amount, mode); err != nil {
+		return PackedAmount{}, PackedAmount{}, err
+	}
+	intRate := new(big.Int)
+	switch {
+	case o.Rate.IsInt():
+		intRate.Set(o.Rate.Num())
+	case mode == RoundDown:
+		intRate.Div(o.Rate.Num(), o.Rate.Denom())
+	case mode == RoundUp:
+		intRate.Div(o.Rate.Num(), o.Rate.Denom())
+		intRate.Add(intRate, big.NewInt(1))
+	default:
+		return PackedAmount{}, PackedAmount{}, fmt.Errorf("rate %s: %w", o.Rate.FloatString(18), ErrInexact)
+	}
+	if rate, err = PackAmount(intRate, mode); err != nil {
+		return PackedAmount{}, PackedAmount{}, err
+	}
+	return amount, rate, nil
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRegistry_ParseOrder(t *testing.T) {
+	registry, err := LoadTokenRegistry("../testsample/tokens.json")
+	require.NoError(t, err)
+
+	order, err := registry.ParseOrder("1.5 ETH at 2000 USDT/ETH")
+	require.NoError(t, err)
+	require.Equal(t, "ETH", order.Src.Symbol)
+	require.Equal(t, "USDT", order.Dst.Symbol)
+	amount, rate, err := order.Pack(RoundExact)
+	require.NoError(t, err)
+	require.Equal(t, PackedAmount{Mantisa: 15, Exp: 17}, amount)
+	// 2000 * 1e6 / 1e18 USDT base units per ETH base unit, scaled by 1e18
+	require.Equal(t, PackedAmount{Mantisa: 2, Exp: 9}, rate)
+
+	order, err = registry.ParseOrder("3000 USDT at 2000 USDT/ETH")
+	require.NoError(t, err)
+	require.Equal(t, "ETH", order.Dst.Symbol)
+	amount, rate, err = order.Pack(RoundExact)
+	require.NoError(t, err)
+	require.Equal(t, PackedAmount{Mantisa: 3, Exp: 9}, amount)
+	require.Equal(t, PackedAmount{Mantisa: 5, Exp: 26}, rate)
+
+	// 1 / 3 ETH per USDT can not be represented exactly
+	order, err = registry.ParseOrder("3 USDT at 3 USDT/ETH")
+	require.NoError(t, err)
+	_, _, err = order.Pack(RoundExact)
+	require.True(t, errors.Is(err, ErrInexact))
+	_, down, err := order.Pack(RoundDown)
+	require.NoError(t, err)
+	_, up, err := order.Pack(RoundUp)
+	require.NoError(t, err)
+	require.True(t, down.Big().Cmp(up.Big()) < 0)
+
+	for _, s := range []string{
+		"1.5 ETH",
+		"1.5 DAI at 2000 USDT/ETH",
+		"1.5 KNC at 2000 USDT/ETH",
+		"1.5 ETH at -1 USDT/ETH",
+		"0.0000001 USDT at 1 USDT/ETH",
+	} {
+		_, err := registry.ParseOrder(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestTokenRegistry_FormatAmount(t *testing.T) {
+	registry, err := NewTokenRegistry([]Token{{ID: 0, Symbol: "ETH", Decimals: 18}, {ID: 1, Symbol: "USDT", Decimals: 6}})
+	require.NoError(t, err)
+	require.Equal(t, "1.5 ETH", registry.FormatAmount(0, big.NewInt(15e17)))
+	require.Equal(t, "0.000001 USDT", registry.FormatAmount(1, big.NewInt(1)))
+	require.Equal(t, "3000 USDT", registry.FormatAmount(1, big.NewInt(3e9)))
+	require.Equal(t, "7 of token 2", registry.FormatAmount(2, big.NewInt(7)))
+
+	_, amount, err := registry.ParseAmount("0.25 ETH")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(25e16), amount)
+
+	data, err := json.Marshal(registry)
+	require.NoError(t, err)
+	var decoded TokenRegistry
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, registry.Tokens(), decoded.Tokens())
+
+	_, err = NewTokenRegistry([]Token{{ID: 0, Symbol: "ETH"}, {ID: 1, Symbol: "ETH"}})
+	require.Error(t, err)
+	_, err = NewTokenRegistry([]Token{{ID: 1 << TokenIDBits, Symbol: "ETH"}})
+	require.Error(t, err)
+}
@@ -7,11 +7,14 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 
 	"github.com/KyberNetwork/l2-contract-test-suite/common"
+	"github.com/KyberNetwork/l2-contract-test-suite/common/settlement"
 )
 
 type Transaction interface {
-	// ToBytes returns pubData, submit to blockchain
+	// ToBytes returns pubData, submit to blockchain. It panics if the tx is invalid.
 	ToBytes() []byte
+	// Validate checks every field fits in its width in the pubdata
+	Validate() error
 }
 
 type OpType uint8
@@ -30,10 +33,6 @@ const (
 	Exit                         // 10
 )
 
-var (
-	precision = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
-)
-
 /// 10 bit for mantisa, 6 bit for
 type PackedFee struct {
 	Mantisa uint16 `json:"mantisa,string"`
@@ -42,6 +41,9 @@ type PackedFee struct {
 
 // 10 bit for mantisa, 6 bit for exp
 func (f *PackedFee) toBytes() []byte {
+	if err := f.Validate(); err != nil {
+		panic(err)
+	}
 	out := uint16(0)
 	out = out | (f.Mantisa << 6)
 	out = out | (uint16(f.Exp))
@@ -79,6 +81,15 @@ func (a PackedAmount) Big() *big.Int {
 	return tmp
 }
 
+// Uint256 returns mantisa * 10**exp as the contract computes it, or an ArithmeticError if it overflows uint256
+func (a PackedAmount) Uint256() (*big.Int, error) {
+	tmp, err := common.CheckedExp(big.NewInt(10), big.NewInt(int64(a.Exp)))
+	if err != nil {
+		return nil, err
+	}
+	return common.CheckedMul(big.NewInt(int64(a.Mantisa)), tmp)
+}
+
 func (a *PackedAmount) MarshalText() ([]byte, error) {
 	return []byte("0x" + a.Big().Text(16)), nil
 }
@@ -101,77 +112,59 @@ type Settlement1 struct {
 	ValidPeriod2 uint32
 }
 
-func calAmountOut(amount *big.Int, rate *big.Int) *big.Int {
-	return new(big.Int).Div(new(big.Int).Mul(amount, rate), precision)
-}
-
-func calAmountIn(amount *big.Int, rate *big.Int) *big.Int {
-	tmp := new(big.Int).Mul(amount, precision)
-	tmp = new(big.Int).Add(tmp, rate)
-	tmp = new(big.Int).Sub(tmp, big.NewInt(1))
-	return tmp.Div(tmp, rate)
-}
-
-func (s *Settlement1) GetSettlementValue() (amount1 *big.Int, amount2 *big.Int, fee1 *big.Int, fee2 *big.Int, loo *LeftOverOrder) {
-	var (
-		orderAmount1 = s.Amount1.Big()
-		orderRate1   = s.Rate1.Big()
-		orderAmount2 = s.Amount2.Big()
-		orderRate2   = s.Rate2.Big()
-	)
-
-	if s.ValidSince1 <= s.ValidSince2 {
-		amount2 = calAmountOut(orderAmount1, orderRate1)
-		if amount2.Cmp(orderAmount2) == 1 {
-			amount2.Set(orderAmount2)
-			amount1 = calAmountIn(orderAmount2, orderRate1)
-		} else {
-			amount1 = new(big.Int).Set(orderAmount1)
-		}
-	} else {
-		amount1 = calAmountOut(orderAmount2, orderRate2)
-		if amount1.Cmp(orderAmount1) == 1 {
-			amount1.Set(orderAmount1)
-			amount2 = calAmountIn(orderAmount1, orderAmount2)
-		} else {
-			amount2 = new(big.Int).Set(orderAmount2)
+// orders returns the orders of s, or an ArithmeticError if a packed amount overflows uint256
+func (s *Settlement1) orders() (order1, order2 *settlement.Order, err error) {
+	var values [4]*big.Int
+	for i, a := range []PackedAmount{s.Amount1, s.Rate1, s.Amount2, s.Rate2} {
+		if values[i], err = a.Uint256(); err != nil {
+			return nil, nil, err
 		}
 	}
+	order1 = &settlement.Order{Amount: values[0], Rate: values[1], Fee: s.Fee1.Big(), ValidSince: s.ValidSince1}
+	order2 = &settlement.Order{Amount: values[2], Rate: values[3], Fee: s.Fee2.Big(), ValidSince: s.ValidSince2}
+	return order1, order2, nil
+}
 
-	fee1 = s.Fee1.Big()
-	fee2 = s.Fee2.Big()
+func (s *Settlement1) GetSettlementValue() (amount1 *big.Int, amount2 *big.Int, fee1 *big.Int, fee2 *big.Int, loo *LeftOverOrder, err error) {
+	order1, order2, err := s.orders()
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	fill1, fill2, err := settlement.Match(order1, order2)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
 
-	if amount1.Cmp(orderAmount1) < 0 { //left-over order at order1
-		fee1 = new(big.Int).Div(new(big.Int).Mul(s.Fee1.Big(), amount1), orderAmount1)
+	if fill1.IsPartial() { //left-over order at order1
 		loo = &LeftOverOrder{
 			AccountID:   s.Account1,
 			SrcToken:    s.Token1,
 			DestToken:   s.Token2,
-			Amount:      new(big.Int).Sub(orderAmount1, amount1),
-			Rate:        orderRate1,
-			Fee:         new(big.Int).Sub(s.Fee1.Big(), fee1),
+			Amount:      fill1.RemainingAmount,
+			Rate:        order1.Rate,
+			Fee:         fill1.RemainingFee,
 			ValidSince:  s.ValidSince1,
 			ValidPeriod: s.ValidPeriod1,
 		}
 	}
 
-	if amount2.Cmp(orderAmount2) < 0 { //left-over order at order1
-		fee2 = new(big.Int).Div(new(big.Int).Mul(s.Fee2.Big(), amount2), orderAmount2)
+	if fill2.IsPartial() { //left-over order at order2
 		loo = &LeftOverOrder{
 			AccountID:   s.Account2,
 			SrcToken:    s.Token2,
 			DestToken:   s.Token1,
-			Amount:      new(big.Int).Sub(orderAmount2, amount2),
-			Rate:        orderRate2,
-			Fee:         new(big.Int).Sub(s.Fee2.Big(), fee2),
+			Amount:      fill2.RemainingAmount,
+			Rate:        order2.Rate,
+			Fee:         fill2.RemainingFee,
 			ValidSince:  s.ValidSince2,
 			ValidPeriod: s.ValidPeriod2,
 		}
 	}
-	return
+	return fill1.Amount, fill2.Amount, fill1.Fee, fill2.Fee, loo, nil
 }
 
 func (s *Settlement1) ToBytes() []byte {
+	mustValidate(s)
 	var out []byte
 	// the first 3 bytes, 4 bit opType, 10 bits token1, 10 bits token2
 	head := uint32(0)
@@ -211,6 +204,7 @@ type Settlement2 struct {
 }
 
 func (s *Settlement2) ToBytes() []byte {
+	mustValidate(s)
 	var out []byte
 	// the first 6 bytes, 4 bit opType, 44 bits LeftOverID
 	head := uint64(0)
@@ -226,58 +220,40 @@ func (s *Settlement2) ToBytes() []byte {
 	return out
 }
 
-func (s *Settlement2) GetSettlementValue(loo1 *LeftOverOrder) (amount1 *big.Int, amount2 *big.Int, fee1 *big.Int, fee2 *big.Int, loo2 *LeftOverOrder) {
-	var (
-		orderAmount1 = new(big.Int).Set(loo1.Amount)
-		orderRate1   = new(big.Int).Set(loo1.Rate)
-		orderAmount2 = s.Amount2.Big()
-		orderRate2   = s.Rate2.Big()
-	)
-
-	if loo1.ValidSince <= s.ValidSince2 {
-		amount2 = calAmountOut(orderAmount1, orderRate1)
-		if amount2.Cmp(orderAmount2) == 1 {
-			amount2.Set(orderAmount2)
-			amount1 = calAmountIn(orderAmount2, orderRate1)
-		} else {
-			amount1 = new(big.Int).Set(orderAmount1)
-		}
-	} else {
-		amount1 = calAmountOut(orderAmount2, orderRate2)
-		if amount1.Cmp(orderAmount1) == 1 {
-			amount1.Set(orderAmount1)
-			amount2 = calAmountIn(orderAmount1, orderRate2)
-		} else {
-			amount2 = new(big.Int).Set(orderAmount2)
-		}
+// GetSettlementValue settles the left-over order loo1 against s, loo1 is updated to its remaining amount and fee
+func (s *Settlement2) GetSettlementValue(loo1 *LeftOverOrder) (amount1 *big.Int, amount2 *big.Int, fee1 *big.Int, fee2 *big.Int, loo2 *LeftOverOrder, err error) {
+	amount, err := s.Amount2.Uint256()
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
 	}
-
-	if amount1.Cmp(orderAmount1) < 0 {
-		fee1 = new(big.Int).Div(new(big.Int).Mul(loo1.Fee, amount1), orderAmount1)
-	} else {
-		fee1 = new(big.Int).Set(loo1.Fee)
+	rate, err := s.Rate2.Uint256()
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	fill1, fill2, err := settlement.Match(
+		&settlement.Order{Amount: loo1.Amount, Rate: loo1.Rate, Fee: loo1.Fee, ValidSince: loo1.ValidSince},
+		&settlement.Order{Amount: amount, Rate: rate, Fee: s.Fee2.Big(), ValidSince: s.ValidSince2},
+	)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
 	}
 
-	if amount2.Cmp(orderAmount2) < 0 { //left-over loo1 at order 2
-		fee2 = new(big.Int).Div(new(big.Int).Mul(s.Fee2.Big(), amount2), orderAmount2)
+	if fill2.IsPartial() { //left-over loo1 at order 2
 		loo2 = &LeftOverOrder{
 			AccountID:   s.AccountID2,
 			SrcToken:    loo1.DestToken,
 			DestToken:   loo1.SrcToken,
-			Amount:      new(big.Int).Sub(orderAmount2, amount2),
-			Rate:        orderRate2,
-			Fee:         new(big.Int).Sub(s.Fee2.Big(), fee2),
+			Amount:      fill2.RemainingAmount,
+			Rate:        rate,
+			Fee:         fill2.RemainingFee,
 			ValidSince:  s.ValidSince2,
 			ValidPeriod: s.ValidPeriod2,
 		}
-	} else {
-		fee2 = s.Fee2.Big()
 	}
 
-	loo1.Amount = orderAmount1.Sub(orderAmount1, amount1)
-	loo1.Fee = loo1.Fee.Sub(loo1.Fee, fee1)
-
-	return
+	loo1.Amount = fill1.RemainingAmount
+	loo1.Fee = fill1.RemainingFee
+	return fill1.Amount, fill2.Amount, fill1.Fee, fill2.Fee, loo2, nil
 }
 
 type Settlement3 struct {
@@ -286,6 +262,7 @@ type Settlement3 struct {
 }
 
 func (s *Settlement3) ToBytes() []byte {
+	mustValidate(s)
 	var out []byte
 	// the first 6 bytes, 4 bit opType, 44 bits LeftOverID1
 	head := uint64(0)
@@ -305,6 +282,7 @@ type DepositOp struct {
 }
 
 func (d *DepositOp) ToBytes() []byte {
+	mustValidate(d)
 	head := uint64(0)
 	head = head | (uint64(Deposit) << 44)
 	head = head | (d.DepositID)
@@ -320,6 +298,7 @@ type DepositToNewOp struct {
 }
 
 func (d *DepositToNewOp) ToBytes() []byte {
+	mustValidate(d)
 	head := uint64(0)
 	head = head | (uint64(DepositToNew) << 44)
 	head = head | (d.DepositID)
@@ -337,6 +316,7 @@ type WithdrawOp struct {
 }
 
 func (w *WithdrawOp) ToBytes() []byte {
+	mustValidate(w)
 	var (
 		data = uint16(0)
 		out  []byte
@@ -358,6 +338,7 @@ type ExitOp struct {
 }
 
 func (exit *ExitOp) ToBytes() []byte {
+	mustValidate(exit)
 	var out []byte
 	var data = uint8(Exit) << 4
 	out = append(out, data)
@@ -3,9 +3,12 @@ package types
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/common"
 )
 
 func TestFee_MarshalText(t *testing.T) {
@@ -67,3 +70,50 @@ func TestSettlementOp1(t *testing.T) {
 	t.Log(string(b))
 	t.Log(hex.EncodeToString(op.ToBytes()))
 }
+
+func TestSettlement1_GetSettlementValueOrder2Maker(t *testing.T) {
+	op := Settlement1{
+		OpType:      SettlementOp11,
+		Amount1:     PackedAmount{Mantisa: 5, Exp: 2},
+		Rate1:       PackedAmount{Mantisa: 4, Exp: 18},
+		Fee1:        PackedFee{Mantisa: 1, Exp: 2},
+		Amount2:     PackedAmount{Mantisa: 15, Exp: 2},
+		Rate2:       PackedAmount{Mantisa: 5, Exp: 17},
+		Fee2:        PackedFee{Mantisa: 3, Exp: 1},
+		ValidSince1: 2,
+		ValidSince2: 1,
+	}
+	amount1, amount2, fee1, fee2, loo, err := op.GetSettlementValue()
+	require.NoError(t, err)
+	// order 2 is the maker, all of order 1 is sold at rate2
+	require.Equal(t, "500", amount1.String())
+	require.Equal(t, "1000", amount2.String())
+	require.Equal(t, "100", fee1.String())
+	require.Equal(t, "20", fee2.String())
+	require.Equal(t, "500", loo.Amount.String())
+	require.Equal(t, "10", loo.Fee.String())
+	require.Equal(t, op.Account2, loo.AccountID)
+}
+
+func TestPackedAmount_Uint256(t *testing.T) {
+	var arithmeticErr *common.ArithmeticError
+	value, err := PackedAmount{Mantisa: 11579, Exp: 73}.Uint256()
+	require.NoError(t, err)
+	require.Equal(t, PackedAmount{Mantisa: 11579, Exp: 73}.Big(), value)
+
+	// 10**78 overflows even with a zero mantissa, like the contract
+	_, err = PackedAmount{Mantisa: 0, Exp: 78}.Uint256()
+	require.True(t, errors.As(err, &arithmeticErr))
+	_, err = PackedAmount{Mantisa: 11580, Exp: 73}.Uint256()
+	require.True(t, errors.As(err, &arithmeticErr))
+
+	op := Settlement1{
+		OpType:  SettlementOp11,
+		Amount1: PackedAmount{Mantisa: 1, Exp: 255},
+		Rate1:   PackedAmount{Mantisa: 1, Exp: 18},
+		Amount2: PackedAmount{Mantisa: 1, Exp: 18},
+		Rate2:   PackedAmount{Mantisa: 1, Exp: 18},
+	}
+	_, _, _, _, _, err = op.GetSettlementValue()
+	require.True(t, errors.As(err, &arithmeticErr))
+}
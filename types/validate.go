@@ -0,0 +1,114 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// bit widths of the fields of the pubdata
+const (
+	TokenIDBits     = 10
+	LooIDBits       = 44
+	DepositIDBits   = 44
+	ValidPeriodBits = 28
+	// PubKeyLength is the length in bytes of a compressed public key
+	PubKeyLength = 32
+)
+
+func checkBits(name string, value uint64, bits uint) error {
+	if value >= 1<<bits {
+		return fmt.Errorf("%s %d: %w %d bits", name, value, ErrOverflow, bits)
+	}
+	return nil
+}
+
+func checkUint256(name string, value *big.Int) error {
+	if value == nil {
+		return fmt.Errorf("%s is nil", name)
+	}
+	if value.Sign() < 0 {
+		return fmt.Errorf("%s %s: %w", name, value.String(), ErrNegative)
+	}
+	if value.BitLen() > 256 {
+		return fmt.Errorf("%s %s: %w 256 bits", name, value.String(), ErrOverflow)
+	}
+	return nil
+}
+
+// firstError returns the first non nil error
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mustValidate(tx Transaction) {
+	if err := tx.Validate(); err != nil {
+		panic(err)
+	}
+}
+
+func (s *Settlement1) Validate() error {
+	if s.OpType < SettlementOp11 || s.OpType > SettlementOp13 {
+		return fmt.Errorf("invalid settlement1 op type %d", s.OpType)
+	}
+	return firstError(
+		checkBits("token1", uint64(s.Token1), TokenIDBits),
+		checkBits("token2", uint64(s.Token2), TokenIDBits),
+		s.Fee1.Validate(),
+		s.Fee2.Validate(),
+		checkBits("validPeriod1", uint64(s.ValidPeriod1), ValidPeriodBits),
+		checkBits("validPeriod2", uint64(s.ValidPeriod2), ValidPeriodBits),
+	)
+}
+
+func (s *Settlement2) Validate() error {
+	if s.OpType != SettlementOp21 && s.OpType != SettlementOp22 {
+		return fmt.Errorf("invalid settlement2 op type %d", s.OpType)
+	}
+	return firstError(
+		checkBits("looID1", s.LooID1, LooIDBits),
+		s.Fee2.Validate(),
+		checkBits("validPeriod2", uint64(s.ValidPeriod2), ValidPeriodBits),
+	)
+}
+
+func (s *Settlement3) Validate() error {
+	return firstError(
+		checkBits("looID1", s.LooID1, LooIDBits),
+		checkBits("looID2", s.LooID2, LooIDBits),
+	)
+}
+
+func (d *DepositOp) Validate() error {
+	return firstError(
+		checkBits("depositID", d.DepositID, DepositIDBits),
+		checkBits("tokenID", uint64(d.TokenID), TokenIDBits),
+		checkUint256("amount", d.Amount),
+	)
+}
+
+func (d *DepositToNewOp) Validate() error {
+	if len(d.PubKey) != PubKeyLength {
+		return fmt.Errorf("invalid pubkey length %d", len(d.PubKey))
+	}
+	return firstError(
+		checkBits("depositID", d.DepositID, DepositIDBits),
+		checkBits("tokenID", uint64(d.TokenID), TokenIDBits),
+		checkUint256("amount", d.Amount),
+	)
+}
+
+func (w *WithdrawOp) Validate() error {
+	return firstError(
+		checkBits("tokenID", uint64(w.TokenID), TokenIDBits),
+		w.Fee.Validate(),
+	)
+}
+
+func (exit *ExitOp) Validate() error {
+	return nil
+}
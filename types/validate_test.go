@@ -0,0 +1,30 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction_Validate(t *testing.T) {
+	withdraw := &WithdrawOp{TokenID: 1023, Fee: PackedFee{Mantisa: 1, Exp: 2}}
+	require.NoError(t, withdraw.Validate())
+	require.Equal(t, []byte{0x9f, 0xfc}, withdraw.ToBytes()[:2])
+	// token 1024 would overwrite the op type
+	withdraw.TokenID = 1024
+	require.True(t, errors.Is(withdraw.Validate(), ErrOverflow))
+	require.Panics(t, func() { withdraw.ToBytes() })
+
+	settlement := &Settlement1{OpType: SettlementOp11, ValidPeriod1: 1<<ValidPeriodBits - 1}
+	require.NoError(t, settlement.Validate())
+	settlement.ValidPeriod1++
+	require.True(t, errors.Is(settlement.Validate(), ErrOverflow))
+	settlement = &Settlement1{OpType: SettlementOp21}
+	require.Error(t, settlement.Validate())
+
+	require.True(t, errors.Is((&Settlement2{OpType: SettlementOp21, LooID1: 1 << LooIDBits}).Validate(), ErrOverflow))
+	require.True(t, errors.Is((&DepositOp{Amount: big.NewInt(-1)}).Validate(), ErrNegative))
+	require.Error(t, (&DepositOp{}).Validate())
+}
@@ -0,0 +1,139 @@
+package watchtower
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+// ScanDir handles the calls to the contract saved in dir, a file of hex calldata per call, in file name order.
+// done holds the names of the files already handled, ScanDir adds the files it handles
+// so that a directory filled as the calls are made can be scanned again. A bad block, see BadBlockError, is logged.
+func (w *Watchtower) ScanDir(dir string, done map[string]bool) ([]test.Step, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && !done[file.Name()] && !strings.HasPrefix(file.Name(), ".") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	var steps []test.Step
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return steps, err
+		}
+		calldata, err := hexutil.Decode(strings.TrimSpace(string(data)))
+		if err != nil {
+			return steps, fmt.Errorf("%s: %w", name, err)
+		}
+		step, err := w.HandleCall(calldata)
+		if err := badBlock(err); err != nil {
+			return steps, fmt.Errorf("%s: %w", name, err)
+		}
+		done[name] = true
+		if step != nil {
+			steps = append(steps, *step)
+		}
+	}
+	return steps, nil
+}
+
+// Backend is the part of an ethclient.Client or a backends.SimulatedBackend the watchtower follows the contract with
+type Backend interface {
+	SubscribeNewHead(ctx context.Context, ch chan<- *ethTypes.Header) (ethereum.Subscription, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*ethTypes.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*ethTypes.Receipt, error)
+}
+
+// Watch handles the successful calls to the contract at address made from the L1 block from until ctx is done,
+// and sends the accusations of the bad blocks to steps, a bad block which can not be accused is logged
+func (w *Watchtower) Watch(ctx context.Context, backend Backend, address common.Address, from uint64, steps chan<- test.Step) error {
+	heads := make(chan *ethTypes.Header)
+	sub, err := backend.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	// catch up with the blocks mined before the subscription
+	head, err := backend.BlockByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	next := from
+	for {
+		for ; next <= head.NumberU64(); next++ {
+			if err := w.handleBlock(ctx, backend, address, next, steps); err != nil {
+				return fmt.Errorf("L1 block %d: %w", next, err)
+			}
+		}
+		select {
+		case header := <-heads:
+			head = ethTypes.NewBlockWithHeader(header)
+		case err := <-sub.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *Watchtower) handleBlock(ctx context.Context, backend Backend, address common.Address, number uint64, steps chan<- test.Step) error {
+	blk, err := backend.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return err
+	}
+	for _, tx := range blk.Transactions() {
+		if tx.To() == nil || *tx.To() != address {
+			continue
+		}
+		receipt, err := backend.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return fmt.Errorf("tx %s: %w", tx.Hash().Hex(), err)
+		}
+		if receipt.Status != ethTypes.ReceiptStatusSuccessful {
+			continue
+		}
+		step, err := w.HandleCall(tx.Data())
+		if err := badBlock(err); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.Hash().Hex(), err)
+		}
+		if step == nil {
+			continue
+		}
+		select {
+		case steps <- *step:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// badBlock logs a *BadBlockError, the watchtower keeps watching after it, and returns any other error
+func badBlock(err error) error {
+	var badBlockErr *BadBlockError
+	if errors.As(err, &badBlockErr) {
+		log.Printf("%v", badBlockErr)
+		return nil
+	}
+	return err
+}
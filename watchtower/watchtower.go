@@ -0,0 +1,255 @@
+// Package watchtower follows the blocks submitted to the contract as a verifier node does: it re-executes every miniblock
+// and builds the accusation of the first one whose state hash or commitment differs from the one submitted.
+package watchtower
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/common/proof"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+type Watchtower struct {
+	r        *blockchain.Rollup
+	contract *abi.ABI
+	// deposits are the L1 deposits by DepositID
+	deposits map[uint64]types.Transaction
+	// numDeposit is the DepositID of the next deposit added
+	numDeposit uint64
+	// badBlock is the number of the last block accused, the blocks submitted after it are dropped with it
+	badBlock uint32
+}
+
+// New returns a watchtower of the contract deployed with the genesis bc, contract is only needed by HandleCall
+func New(bc *blockchain.Blockchain, contract *abi.ABI) *Watchtower {
+	return &Watchtower{
+		r:          blockchain.NewRollup(bc),
+		contract:   contract,
+		deposits:   make(map[uint64]types.Transaction),
+		numDeposit: bc.NumDeposit(),
+	}
+}
+
+// BadBlockError reports a submitted block whose miniblock MiniBlockIndex can not be executed, e.g. a tx overdraws
+// a balance or overflows uint256. Such a miniblock has no execution proof to accuse it with, see cmd/overflowCases:
+// the watchtower drops it as a bad block and keeps watching.
+type BadBlockError struct {
+	BlockNumber    uint32
+	MiniBlockIndex int
+	Err            error
+}
+
+func (e *BadBlockError) Error() string {
+	return fmt.Sprintf("bad block %d: miniblock %d: %v", e.BlockNumber, e.MiniBlockIndex, e.Err)
+}
+
+func (e *BadBlockError) Unwrap() error {
+	return e.Err
+}
+
+// Rollup returns the valid blocks submitted so far
+func (w *Watchtower) Rollup() *blockchain.Rollup {
+	return w.r
+}
+
+// AddDeposit records a DepositOp or a DepositToNewOp submitted to the contract. Deposits are numbered in submission order,
+// from the number of deposits of the genesis or after the last one loaded by LoadDeposits.
+func (w *Watchtower) AddDeposit(deposit types.Transaction) {
+	depositID := w.numDeposit
+	switch deposit := deposit.(type) {
+	case *types.DepositOp:
+		deposit.DepositID = depositID
+	case *types.DepositToNewOp:
+		deposit.DepositID = depositID
+	default:
+		panic(fmt.Sprintf("%T is not a deposit", deposit))
+	}
+	w.deposits[depositID] = deposit
+	w.numDeposit++
+}
+
+// LoadDeposits records by their DepositID the deposits submitted before the watchtower follows the contract,
+// e.g. saved to a file. The next deposit added is numbered after the last of them.
+func (w *Watchtower) LoadDeposits(deposits map[uint64]types.Transaction) {
+	for depositID, deposit := range deposits {
+		w.deposits[depositID] = deposit
+		if depositID >= w.numDeposit {
+			w.numDeposit = depositID + 1
+		}
+	}
+}
+
+// AddBlock re-executes a block submitted to the contract. A valid block extends the rollup and AddBlock returns nil.
+// Otherwise it returns the step accusing the first bad miniblock, and drops the block as the contract does once accused.
+// A block with a miniblock which can not be executed is dropped too, with a *BadBlockError if no earlier miniblock is accused.
+// A block submitted at a number already reached replaces it and every later block,
+// a block submitted on top of a bad block is ignored.
+// It fails if the block can not be executed, e.g. it uses an unknown deposit.
+func (w *Watchtower) AddBlock(submitted *blockchain.SubmittedBlock) (*test.Step, error) {
+	blockNumber := submitted.BlockNumber
+	if w.badBlock != 0 && blockNumber > w.badBlock {
+		return nil, nil
+	}
+	if blockNumber == 0 || blockNumber > w.r.BlockNumber()+1 {
+		return nil, fmt.Errorf("block %d submitted after block %d", blockNumber, w.r.BlockNumber())
+	}
+	if err := w.r.RevertTo(blockNumber - 1); err != nil {
+		return nil, fmt.Errorf("block %d: %w", blockNumber, err)
+	}
+	w.badBlock = 0
+
+	// claimed are the miniblocks as submitted, executed are re-executed
+	var claimed, executed []*types.MiniBlock
+	numDeposit := w.r.Blockchain().NumDeposit()
+	for i, data := range submitted.MiniBlocks {
+		var next uint64
+		for _, miniBlocks := range []*[]*types.MiniBlock{&claimed, &executed} {
+			miniBlock, err := types.DecodeMiniBlock(data)
+			if err != nil {
+				return nil, fmt.Errorf("block %d miniblock %d: %w", blockNumber, i, err)
+			}
+			if next, err = blockchain.FillDeposits(miniBlock, w.deposits, numDeposit); err != nil {
+				return nil, fmt.Errorf("block %d miniblock %d: %w", blockNumber, i, err)
+			}
+			*miniBlocks = append(*miniBlocks, miniBlock)
+		}
+		numDeposit = next
+	}
+	blk, err := w.r.AddBlock(executed, submitted.Timestamp)
+	var miniBlockErr *blockchain.MiniBlockError
+	if errors.As(err, &miniBlockErr) {
+		// a miniblock before the failing one is accused first
+		if index := miniBlockErr.Index; index > 0 {
+			if blk, err = w.r.AddBlock(executed[:index], submitted.Timestamp); err != nil {
+				return nil, fmt.Errorf("block %d: %w", blockNumber, err)
+			}
+			if step, err := w.accuse(submitted, claimed, blk); step != nil || err != nil {
+				return step, err
+			}
+		}
+		if err := w.reject(blockNumber); err != nil {
+			return nil, err
+		}
+		return nil, &BadBlockError{BlockNumber: blockNumber, MiniBlockIndex: miniBlockErr.Index, Err: miniBlockErr.Err}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", blockNumber, err)
+	}
+	return w.accuse(submitted, claimed, blk)
+}
+
+// accuse returns the step accusing the first miniblock of blk whose state hash or commitment differs from the one claimed
+// by the submitted block, and rejects the block. blk holds the miniblocks executed so far, it returns nil if they are valid.
+func (w *Watchtower) accuse(submitted *blockchain.SubmittedBlock, claimed []*types.MiniBlock, blk *blockchain.Block) (*test.Step, error) {
+	blockNumber := submitted.BlockNumber
+	for i, executed := range blk.MiniBlocks {
+		index := uint(i)
+		switch {
+		case executed.StateHash != claimed[i].StateHash:
+			prevStateHashProof, err := w.prevStateHashProof(blockNumber, claimed, index)
+			if err != nil {
+				return nil, err
+			}
+			step := test.AccuseBlockFraudProofStep{
+				BlockNumber:        uint(blockNumber),
+				MiniBlockNumber:    index,
+				MiniBlock:          claimed[i],
+				PrevStateData:      blk.PrevStateData[i],
+				MiniBlockProof:     proof.BuildMiniBlockProof(claimed, index, submitted.Timestamp),
+				PrevStateHashProof: prevStateHashProof,
+				ExecutionProof:     blk.ExecutionProofs[i],
+			}
+			if err := w.reject(blockNumber); err != nil {
+				return nil, err
+			}
+			return &test.Step{Action: test.AccuseBlockFraudProof, Data: step}, nil
+		case executed.Commitment != claimed[i].Commitment:
+			state, err := w.r.StateAfterMiniBlock(blockNumber, index)
+			if err != nil {
+				return nil, err
+			}
+			step := test.AccuseCommitmentFraudProofStep{
+				BlockNumber:      uint(blockNumber),
+				MiniBlockNumber:  index,
+				MiniBlock:        claimed[i],
+				PostStateData:    state.GetStateData(),
+				MiniBlockProof:   proof.BuildMiniBlockProof(claimed, index, submitted.Timestamp),
+				CommitmentProofs: []hexutil.Bytes{state.BuildCommitmentProof(claimed[i])},
+			}
+			if err := w.reject(blockNumber); err != nil {
+				return nil, err
+			}
+			return &test.Step{Action: test.AccuseCommitmentFraudProof, Data: step}, nil
+		}
+	}
+	return nil, nil
+}
+
+// reject drops the bad block blockNumber and ignores the blocks submitted after it
+func (w *Watchtower) reject(blockNumber uint32) error {
+	if err := w.r.RevertTo(blockNumber - 1); err != nil {
+		return fmt.Errorf("reject block %d: %w", blockNumber, err)
+	}
+	w.badBlock = blockNumber
+	return nil
+}
+
+// prevStateHashProof proves the state hash before miniblock index of the block blockNumber made of claimed,
+// see blockchain.Rollup.PrevStateHashProof
+func (w *Watchtower) prevStateHashProof(blockNumber uint32, claimed []*types.MiniBlock, index uint) (hexutil.Bytes, error) {
+	if index > 0 {
+		return proof.BuildPrevStateHashMiniBlockProof(claimed, index-1), nil
+	}
+	if blockNumber == 1 {
+		return hexutil.Bytes{}, nil
+	}
+	prevBlk, err := w.r.Block(blockNumber - 1)
+	if err != nil {
+		return nil, err
+	}
+	return proof.BuildFinalStateHashProof(prevBlk.MiniBlocks, prevBlk.Header.Timestamp), nil
+}
+
+// HandleCall handles the calldata of a successful call to the contract: it records deposits, checks blocks
+// and returns the accusation of a bad block or a *BadBlockError, see AddBlock. An accepted accusation drops the accused block and every later block.
+func (w *Watchtower) HandleCall(calldata []byte) (*test.Step, error) {
+	action, ok := test.CallAction(w.contract, calldata)
+	if !ok {
+		return nil, nil
+	}
+	switch action {
+	case test.SubmitDeposit:
+		var deposit types.DepositOp
+		if err := test.UnpackCall(w.contract, action, calldata, &deposit); err != nil {
+			return nil, err
+		}
+		w.AddDeposit(&deposit)
+	case test.SubmitDepositToNew:
+		var deposit types.DepositToNewOp
+		if err := test.UnpackCall(w.contract, action, calldata, &deposit); err != nil {
+			return nil, err
+		}
+		w.AddDeposit(&deposit)
+	case test.SubmitBlock:
+		blk, err := test.UnpackSubmitBlock(w.contract, calldata)
+		if err != nil {
+			return nil, err
+		}
+		return w.AddBlock(blk)
+	case test.AccuseBlockFraudProof, test.AccuseCommitmentFraudProof:
+		var accusation struct{ BlockNumber uint32 }
+		if err := test.UnpackCall(w.contract, action, calldata, &accusation); err != nil {
+			return nil, err
+		}
+		if accusation.BlockNumber >= 1 && accusation.BlockNumber <= w.r.BlockNumber() {
+			return nil, w.reject(accusation.BlockNumber)
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,279 @@
+package watchtower
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/KyberNetwork/l2-contract-test-suite/runner"
+	"github.com/KyberNetwork/l2-contract-test-suite/types"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/blockchain"
+	"github.com/KyberNetwork/l2-contract-test-suite/types/test"
+)
+
+var genesis = &blockchain.Genesis{
+	AccountAlloc: map[uint32]blockchain.GenesisAccount{
+		0: {Tokens: map[uint16]*big.Int{}, Address: common.HexToAddress("0xdC70a72AbF352A0E3f75d737430EB896BA9Bf9Ea")},
+		8: {Tokens: map[uint16]*big.Int{0: big.NewInt(1000)}, Address: common.HexToAddress("0x99aF5AF1f1a61FE1678e030916f79331a28A57E8")},
+	},
+	AccountMax: 100,
+}
+
+// buildRollup returns 3 valid blocks using the deposit of id 0, submitted as they are executed by the returned rollup
+func buildRollup(t *testing.T, deposit *types.DepositOp) (*blockchain.Rollup, []*blockchain.SubmittedBlock) {
+	r := blockchain.NewRollup(blockchain.NewBlockchain(genesis, nil))
+	var blocks []*blockchain.SubmittedBlock
+	for i, miniBlocks := range [][]*types.MiniBlock{
+		{{Txs: []types.Transaction{deposit}}},
+		{
+			{Txs: []types.Transaction{&types.WithdrawOp{AccountID: 8, TokenID: 2, Amount: types.PackedAmount{Mantisa: 4, Exp: 7}, Fee: types.PackedFee{Mantisa: 1, Exp: 2}}}},
+			{Txs: []types.Transaction{&types.ExitOp{AccountID: 0}}},
+		},
+		{{Txs: []types.Transaction{&types.WithdrawOp{AccountID: 8, TokenID: 2, Amount: types.PackedAmount{Mantisa: 5, Exp: 3}, Fee: types.PackedFee{Mantisa: 1, Exp: 2}}}}},
+	} {
+		blk, err := r.AddBlock(miniBlocks, uint32(1600661872+i))
+		require.NoError(t, err)
+		submitted := &blockchain.SubmittedBlock{BlockNumber: blk.Header.BlockNumber, Timestamp: blk.Header.Timestamp}
+		for _, miniBlock := range miniBlocks {
+			submitted.MiniBlocks = append(submitted.MiniBlocks, miniBlock.Bytes())
+		}
+		blocks = append(blocks, submitted)
+	}
+	return r, blocks
+}
+
+// corrupt returns blk with a byte of the header of a miniblock flipped: the commitment at offset 0, the state hash at common.HashLength
+func corrupt(blk *blockchain.SubmittedBlock, miniBlockIndex int, offset int) *blockchain.SubmittedBlock {
+	bad := &blockchain.SubmittedBlock{BlockNumber: blk.BlockNumber, Timestamp: blk.Timestamp}
+	for _, data := range blk.MiniBlocks {
+		bad.MiniBlocks = append(bad.MiniBlocks, append(hexutil.Bytes{}, data...))
+	}
+	bad.MiniBlocks[miniBlockIndex][offset] ^= 1
+	return bad
+}
+
+func TestWatchtower_AddBlock(t *testing.T) {
+	deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}
+	r, blocks := buildRollup(t, deposit)
+	badStateHash := corrupt(blocks[1], 1, common.HashLength)
+	badCommitment := corrupt(blocks[2], 0, 0)
+
+	w := New(blockchain.NewBlockchain(genesis, nil), nil)
+	_, err := w.AddBlock(blocks[0])
+	require.Error(t, err, "unknown deposit")
+	w.AddDeposit(&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)})
+	step, err := w.AddBlock(blocks[0])
+	require.NoError(t, err)
+	require.Nil(t, step)
+
+	step, err = w.AddBlock(badStateHash)
+	require.NoError(t, err)
+	require.NotNil(t, step)
+	require.Equal(t, test.AccuseBlockFraudProof, step.Action)
+	accusation := step.Data.(test.AccuseBlockFraudProofStep)
+	require.Equal(t, []byte(badStateHash.MiniBlocks[1]), accusation.MiniBlock.Bytes())
+	// the same accusation built from the rollup executing the bad block
+	blk, err := r.Block(2)
+	require.NoError(t, err)
+	miniBlock := blk.MiniBlocks[1]
+	stateHash := miniBlock.StateHash
+	miniBlock.StateHash[0] ^= 1
+	expected, err := test.NewAccuseBlockFraudProofStep(r, 2, 1)
+	require.NoError(t, err)
+	miniBlock.StateHash = stateHash
+	accusation.MiniBlock = expected.MiniBlock
+	require.Equal(t, expected, accusation)
+	require.Equal(t, uint32(1), w.Rollup().BlockNumber())
+
+	// a block on top of the bad one is ignored until the bad one is replaced
+	step, err = w.AddBlock(blocks[2])
+	require.NoError(t, err)
+	require.Nil(t, step)
+	require.Equal(t, uint32(1), w.Rollup().BlockNumber())
+	step, err = w.AddBlock(blocks[1])
+	require.NoError(t, err)
+	require.Nil(t, step)
+
+	step, err = w.AddBlock(badCommitment)
+	require.NoError(t, err)
+	require.NotNil(t, step)
+	require.Equal(t, test.AccuseCommitmentFraudProof, step.Action)
+	commitmentAccusation := step.Data.(test.AccuseCommitmentFraudProofStep)
+	require.Equal(t, []byte(badCommitment.MiniBlocks[0]), commitmentAccusation.MiniBlock.Bytes())
+	blk, err = r.Block(3)
+	require.NoError(t, err)
+	miniBlock = blk.MiniBlocks[0]
+	miniBlock.Commitment[0] ^= 1
+	expectedCommitment, err := test.NewAccuseCommitmentFraudProofStep(r, 3, 0)
+	require.NoError(t, err)
+	miniBlock.Commitment[0] ^= 1
+	commitmentAccusation.MiniBlock = expectedCommitment.MiniBlock
+	require.Equal(t, expectedCommitment, commitmentAccusation)
+
+	step, err = w.AddBlock(blocks[2])
+	require.NoError(t, err)
+	require.Nil(t, step)
+	for i := uint32(1); i <= r.BlockNumber(); i++ {
+		require.Equal(t, r.BlockRoot(i), w.Rollup().BlockRoot(i))
+	}
+	_, err = w.AddBlock(&blockchain.SubmittedBlock{BlockNumber: 5, MiniBlocks: blocks[2].MiniBlocks})
+	require.Error(t, err)
+}
+
+func TestWatchtower_AddDeposit(t *testing.T) {
+	// deposits are numbered after the ones of the genesis
+	bc := blockchain.NewBlockchain(genesis, nil)
+	_, err := bc.AddMiniBlock(&types.MiniBlock{Txs: []types.Transaction{&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(1)}}})
+	require.NoError(t, err)
+	w := New(bc, nil)
+	deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(2)}
+	w.AddDeposit(deposit)
+	require.Equal(t, uint64(1), deposit.DepositID)
+
+	w.LoadDeposits(map[uint64]types.Transaction{5: &types.DepositOp{DepositID: 5, AccountID: 8, TokenID: 2, Amount: big.NewInt(3)}})
+	deposit = &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(4)}
+	w.AddDeposit(deposit)
+	require.Equal(t, uint64(6), deposit.DepositID)
+	require.Len(t, w.deposits, 3)
+}
+
+func TestWatchtower_BadBlock(t *testing.T) {
+	deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}
+	_, blocks := buildRollup(t, deposit)
+	overdraw := &types.MiniBlock{Txs: []types.Transaction{
+		&types.WithdrawOp{AccountID: 8, TokenID: 2, Amount: types.PackedAmount{Mantisa: 1, Exp: 10}},
+	}}
+
+	w := New(blockchain.NewBlockchain(genesis, nil), nil)
+	w.AddDeposit(&types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)})
+	step, err := w.AddBlock(blocks[0])
+	require.NoError(t, err)
+	require.Nil(t, step)
+
+	// the block is dropped and the watchtower takes the next one
+	failing := &blockchain.SubmittedBlock{BlockNumber: 2, Timestamp: blocks[1].Timestamp, MiniBlocks: []hexutil.Bytes{
+		blocks[1].MiniBlocks[0], overdraw.Bytes(),
+	}}
+	step, err = w.AddBlock(failing)
+	require.Nil(t, step)
+	var badBlockErr *BadBlockError
+	require.True(t, errors.As(err, &badBlockErr))
+	require.Equal(t, uint32(2), badBlockErr.BlockNumber)
+	require.Equal(t, 1, badBlockErr.MiniBlockIndex)
+	require.Equal(t, uint32(1), w.Rollup().BlockNumber())
+	step, err = w.AddBlock(blocks[1])
+	require.NoError(t, err)
+	require.Nil(t, step)
+
+	// a bad miniblock before the failing one is accused
+	bad := corrupt(blocks[2], 0, common.HashLength)
+	bad.MiniBlocks = append(bad.MiniBlocks, overdraw.Bytes())
+	step, err = w.AddBlock(bad)
+	require.NoError(t, err)
+	require.NotNil(t, step)
+	require.Equal(t, test.AccuseBlockFraudProof, step.Action)
+	require.Equal(t, uint(0), step.Data.(test.AccuseBlockFraudProofStep).MiniBlockNumber)
+	require.Equal(t, uint32(2), w.Rollup().BlockNumber())
+}
+
+func TestWatchtower_ScanDir(t *testing.T) {
+	artifact, err := runner.LoadArtifact("../runner/testdata", "Accept")
+	require.NoError(t, err)
+	deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}
+	_, blocks := buildRollup(t, deposit)
+
+	dir, err := ioutil.TempDir("", "watchtower")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for i, step := range []test.Step{
+		{Action: test.SubmitDeposit, Data: deposit},
+		{Action: test.SubmitBlock, Data: blocks[0]},
+		{Action: test.SubmitBlock, Data: corrupt(blocks[1], 0, common.HashLength)},
+	} {
+		calldata, err := step.Pack(&artifact.ABI)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d", i)), []byte(hexutil.Encode(calldata)), 0644))
+	}
+
+	w := New(blockchain.NewBlockchain(genesis, nil), &artifact.ABI)
+	done := make(map[string]bool)
+	steps, err := w.ScanDir(dir, done)
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	require.Equal(t, test.AccuseBlockFraudProof, steps[0].Action)
+	require.Equal(t, uint(2), steps[0].Data.(test.AccuseBlockFraudProofStep).BlockNumber)
+	require.Len(t, done, 3)
+
+	calldata, err := steps[0].Pack(&artifact.ABI)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "003"), []byte(hexutil.Encode(calldata)), 0644))
+	steps, err = w.ScanDir(dir, done)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+	require.Equal(t, uint32(1), w.Rollup().BlockNumber())
+}
+
+func TestWatchtower_Watch(t *testing.T) {
+	artifact, err := runner.LoadArtifact("../runner/testdata", "Accept")
+	require.NoError(t, err)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	auth := bind.NewKeyedTransactor(key)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)},
+	}, 24000000)
+	defer backend.Close()
+
+	bc := blockchain.NewBlockchain(genesis, nil)
+	address, _, contract, err := bind.DeployContract(auth, artifact.ABI, artifact.Bytecode, backend,
+		bc.GetStateData().Hash(), genesis.AccountMax)
+	require.NoError(t, err)
+	backend.Commit()
+
+	deposit := &types.DepositOp{AccountID: 8, TokenID: 2, Amount: big.NewInt(45242000)}
+	_, blocks := buildRollup(t, deposit)
+	_, err = contract.Transact(auth, "submitDeposit", deposit.AccountID, deposit.TokenID, deposit.Amount)
+	require.NoError(t, err)
+	backend.Commit()
+
+	w := New(bc, &artifact.ABI)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	steps := make(chan test.Step)
+	errs := make(chan error, 1)
+	go func() { errs <- w.Watch(ctx, backend, address, 0, steps) }()
+
+	for _, blk := range []*blockchain.SubmittedBlock{blocks[0], corrupt(blocks[1], 1, common.HashLength)} {
+		_, err = contract.Transact(auth, "submitBlock", blk.BlockNumber, blk.MiniBlocks, blk.Timestamp)
+		require.NoError(t, err)
+		backend.Commit()
+	}
+	select {
+	case step := <-steps:
+		require.Equal(t, test.AccuseBlockFraudProof, step.Action)
+		accusation := step.Data.(test.AccuseBlockFraudProofStep)
+		require.Equal(t, uint(2), accusation.BlockNumber)
+		require.Equal(t, uint(1), accusation.MiniBlockNumber)
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no accusation")
+	}
+	cancel()
+	require.Equal(t, context.Canceled, <-errs)
+	require.Equal(t, uint32(1), w.Rollup().BlockNumber())
+}